@@ -16,6 +16,7 @@
 package ansimage
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"image"
@@ -26,8 +27,10 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/disintegration/imaging"
+	"github.com/kettek/apng"
 	"github.com/lucasb-eyer/go-colorful"
 )
 
@@ -35,6 +38,15 @@ import (
 // INFO: https://en.wikipedia.org/wiki/Block_Elements
 const lowerHalfBlock = "\u2584"
 
+// Unicode Block Element character used to represent an upper pixel over a
+// transparent lower one.
+const upperHalfBlock = "\u2580"
+
+// Escape sequences drawing transparent ANSI-pixels: the default background
+// color of the terminal, and a cursor movement skipping a cell.
+const defaultBackground = "\033[49m"
+const cursorForward = "\033[C"
+
 // Unicode Block Element characters used to represent dithering in terminal row.
 // INFO: https://en.wikipedia.org/wiki/Block_Elements
 const fullBlock = "\u2588"
@@ -45,21 +57,41 @@ const lightShadeBlock = "\u2591"
 // ANSImage scale modes:
 // resize (full scaled to area),
 // fill (resize and crop the image with a center anchor point to fill area),
-// fit (resize the image to fit area, preserving the aspect ratio).
+// fit (resize the image to fit area, preserving the aspect ratio),
+// letterbox (fit, then pad to the full area with the background color or Gradient).
+// More can be added with RegisterScaler.
 const (
 	ScaleModeResize = ScaleMode(iota)
 	ScaleModeFill
 	ScaleModeFit
+	ScaleModeLetterbox
 )
 
+// AutoCrop can be combined with a scale mode (e.g. ScaleModeFit|AutoCrop) to trim
+// uniform borders found on every frame, like letterboxing, before scaling.
+const AutoCrop = ScaleMode(0x80)
+
+// autoCropTolerance is the per-channel difference still considered part of a uniform
+// border, so compression noise doesn't defeat the detection.
+const autoCropTolerance = 16
+
 // ANSImage dithering modes:
 // no dithering (classic mode: half block based),
 // chars (use characters to represent brightness),
-// blocks (use character blocks to represent brightness).
+// blocks (use character blocks to represent brightness),
+// braille (use the 2x4 dots of Braille patterns to represent brightness),
+// quadrants (use quadrant blocks in two colors to represent 2x2 pixels),
+// sextants (use sextant blocks in two colors to represent 3x2 pixels),
+// edges (use | - / \ along the edges found by a Sobel filter, and character
+// blocks elsewhere).
 const (
 	NoDithering = DitheringMode(iota)
 	DitheringWithBlocks
 	DitheringWithChars
+	DitheringWithBraille
+	DitheringWithQuadrants
+	DitheringWithSextants
+	DitheringWithEdges
 )
 
 // ANSImage block size in pixels (dithering mode)
@@ -68,6 +100,42 @@ const (
 	BlockSizeX = 4
 )
 
+// ANSImage block size in pixels (braille dithering mode): one pixel per dot.
+const (
+	BrailleBlockSizeY = 4
+	BrailleBlockSizeX = 2
+)
+
+// ANSImage block size in pixels (quadrants dithering mode): one pixel per quadrant.
+const (
+	QuadrantBlockSizeY = 2
+	QuadrantBlockSizeX = 2
+)
+
+// ANSImage block size in pixels (sextants dithering mode): one pixel per sextant.
+const (
+	SextantBlockSizeY = 3
+	SextantBlockSizeX = 2
+)
+
+// BlockSize returns the size in pixels of the image area drawn in a terminal
+// cell in dithering mode dm (rows, columns): 8x4 when dithering with blocks,
+// chars or edges, 4x2 with braille, 2x2 with quadrants, 3x2 with sextants, and 2x1
+// without dithering (two half blocks).
+func BlockSize(dm DitheringMode) (int, int) {
+	switch dm {
+	case NoDithering:
+		return 2, 1
+	case DitheringWithBraille:
+		return BrailleBlockSizeY, BrailleBlockSizeX
+	case DitheringWithQuadrants:
+		return QuadrantBlockSizeY, QuadrantBlockSizeX
+	case DitheringWithSextants:
+		return SextantBlockSizeY, SextantBlockSizeX
+	}
+	return BlockSizeY, BlockSizeX
+}
+
 var (
 	// ErrImageDownloadFailed occurs in the attempt to download an image and the status code of the response is not "200 OK".
 	ErrImageDownloadFailed = errors.New("ANSImage: image download failed")
@@ -78,6 +146,12 @@ var (
 	// ErrInvalidBoundsMoT occurs when ANSImage height or width are invalid values (Multiple of Two).
 	ErrInvalidBoundsMoT = errors.New("ANSImage: height or width must be >=2")
 
+	// ErrNoFrames occurs when an ANSImage is created from no frame.
+	ErrNoFrames = errors.New("ANSImage: no frames")
+
+	// ErrFrameDelayMismatch occurs when frames and their delays differ in number.
+	ErrFrameDelayMismatch = errors.New("ANSImage: frames and delays must have the same length")
+
 	// ErrOutOfBounds occurs when ANSI-pixel coordinates are out of ANSImage bounds.
 	ErrOutOfBounds = errors.New("ANSImage: out of bounds")
 
@@ -94,12 +168,21 @@ type ScaleMode uint8
 // DitheringMode type is used for image scale dithering mode constants.
 type DitheringMode uint8
 
+// ColorFunc maps an ANSI-pixel color to the color actually rendered.
+// It is used to adjust colors at render time without modifying the ANSImage.
+type ColorFunc func(r, g, b uint8) (uint8, uint8, uint8)
+
 // ANSIpixel represents a pixel of an ANSImage.
 type ANSIpixel struct {
-	Brightness uint8
-	R, G, B    uint8
-	upper      bool
-	source     *ANSImage
+	Brightness    uint8
+	R, G, B       uint8
+	upper         bool
+	dots          uint8  // raised dots of the Braille pattern, or sub-cells in the foreground color
+	bgR, bgG, bgB uint8  // cell background in dithering mode
+	text          string // overlay text drawn instead of the block (see OverlayText)
+	transparent   bool   // left undrawn, see WithTransparency
+	clearBg       bool   // dithered cell on the default background of the terminal
+	source        *ANSImage
 }
 
 // ANSIframe represents an gif frame.
@@ -113,14 +196,21 @@ type ANSImage struct {
 	bgG       uint8
 	bgB       uint8
 	dithering DitheringMode
+	blockY    int // pixels per terminal cell, see BlockSize
+	blockX    int
+	charRamp  []rune
+	loopCount int // as gif.GIF.LoopCount
+
+	renderHook RenderHook
 
 	frame []ANSIframe
 	delay []int
 }
 
 type gifProxy struct {
-	image []image.Image
-	delay []int
+	image     []image.Image
+	delay     []int
+	loopCount int
 }
 
 // Render returns the ANSI-compatible string form of ANSI-pixel.
@@ -131,90 +221,27 @@ func (ap *ANSIpixel) Render() string {
 // RenderExt returns the ANSI-compatible string form of ANSI-pixel.
 // Can specify if background color will be disabled in dithering mode.
 func (ap *ANSIpixel) RenderExt(disableBgColor bool) string {
-	backslash033 := "\033"
-
-	// WITHOUT DITHERING
-	if ap.source.dithering == NoDithering {
-		var renderStr string
-		if ap.upper {
-			renderStr = fmt.Sprintf(
-				"%s[48;2;%d;%d;%dm",
-				backslash033,
-				ap.R, ap.G, ap.B,
-			)
-		} else {
-			renderStr = fmt.Sprintf(
-				"%s[38;2;%d;%d;%dm%s",
-				backslash033,
-				ap.R, ap.G, ap.B,
-				lowerHalfBlock,
-			)
-		}
-		return renderStr
-	}
-
-	// WITH DITHERING
-	block := " "
-	if ap.source.dithering == DitheringWithBlocks {
-		switch bri := ap.Brightness; {
-		case bri > 204:
-			block = fullBlock
-		case bri > 152:
-			block = darkShadeBlock
-		case bri > 100:
-			block = mediumShadeBlock
-		case bri > 48:
-			block = lightShadeBlock
-		}
-	} else if ap.source.dithering == DitheringWithChars {
-		switch bri := ap.Brightness; {
-		case bri > 230:
-			block = "#"
-		case bri > 207:
-			block = "&"
-		case bri > 184:
-			block = "$"
-		case bri > 161:
-			block = "X"
-		case bri > 138:
-			block = "x"
-		case bri > 115:
-			block = "="
-		case bri > 92:
-			block = "+"
-		case bri > 69:
-			block = ";"
-		case bri > 46:
-			block = ":"
-		case bri > 23:
-			block = "."
-		}
-	} else {
-		panic(errUnknownDitheringMode)
-	}
+	return ap.renderFiltered(disableBgColor, nil)
+}
 
-	bgColorStr := fmt.Sprintf(
-		"%s[48;2;%d;%d;%dm",
-		backslash033,
-		ap.source.bgR, ap.source.bgG, ap.source.bgB,
-	)
-	if disableBgColor {
-		bgColorStr = ""
-	}
-	return fmt.Sprintf(
-		"%s%s[38;2;%d;%d;%dm%s",
-		bgColorStr,
-		backslash033,
-		ap.R, ap.G, ap.B,
-		block,
-	)
+// renderFiltered returns the ANSI-compatible string form of ANSI-pixel,
+// passing its colors through cf when cf is not nil.
+func (ap *ANSIpixel) renderFiltered(disableBgColor bool, cf ColorFunc) string {
+	return TrueColorRenderer{DisableBgColor: disableBgColor, ColorFunc: cf}.RenderPixel(ap)
 }
 
-// LoopCount gets GIF frame count.
+// FrameCount gets GIF frame count.
 func (ai *ANSImage) FrameCount() int {
 	return len(ai.frame)
 }
 
+// LoopCount gets how many times the animation loops, from the GIF file, with
+// the meaning of gif.GIF.LoopCount: 0 loops forever, -1 shows the frames once,
+// and n > 0 shows them n+1 times. It's 0 for images not loaded from a GIF.
+func (ai *ANSImage) LoopCount() int {
+	return ai.loopCount
+}
+
 // FrameDelay gets the successive delay times for frame, in 100ths of a second.
 func (ai *ANSImage) FrameDelay(frame int) int {
 	return ai.delay[frame]
@@ -235,12 +262,27 @@ func (ai *ANSImage) DitheringMode() DitheringMode {
 	return ai.dithering
 }
 
+// BlockSize gets the size in pixels of the image area drawn in a terminal cell
+// (rows, columns): the package BlockSize of the dithering mode, unless set
+// with WithBlockSize.
+func (ai *ANSImage) BlockSize() (int, int) {
+	return ai.blockY, ai.blockX
+}
+
 // SetMaxProcs sets the maximum number of parallel goroutines to render the ANSImage
 // (user should manually sets `runtime.GOMAXPROCS(max)` before to this change takes effect).
 func (ai *ANSImage) SetMaxProcs(max int) {
 	ai.maxprocs = max
 }
 
+// SetCharRamp sets the characters drawing brightness in chars dithering mode,
+// from the darkest to the brightest (e.g. " .:-=+*#%@"; reverse it for dark
+// text on a light terminal). Characters must be a single column wide.
+// A nil or empty ramp restores the default one.
+func (ai *ANSImage) SetCharRamp(ramp []rune) {
+	ai.charRamp = append([]rune(nil), ramp...)
+}
+
 // GetMaxProcs gets the maximum number of parallels goroutines to render the ANSImage.
 func (ai *ANSImage) GetMaxProcs() int {
 	return ai.maxprocs
@@ -263,12 +305,19 @@ func (ai *ANSImage) SetAt(frame, y, x int, r, g, b, brightness uint8) error {
 func (ai *ANSImage) GetAt(frame, y, x int) (*ANSIpixel, error) {
 	if y >= 0 && y < ai.h && x >= 0 && x < ai.w {
 		return &ANSIpixel{
-				R:          ai.frame[frame][y][x].R,
-				G:          ai.frame[frame][y][x].G,
-				B:          ai.frame[frame][y][x].B,
-				Brightness: ai.frame[frame][y][x].Brightness,
-				upper:      ai.frame[frame][y][x].upper,
-				source:     ai.frame[frame][y][x].source,
+				R:           ai.frame[frame][y][x].R,
+				G:           ai.frame[frame][y][x].G,
+				B:           ai.frame[frame][y][x].B,
+				Brightness:  ai.frame[frame][y][x].Brightness,
+				upper:       ai.frame[frame][y][x].upper,
+				dots:        ai.frame[frame][y][x].dots,
+				bgR:         ai.frame[frame][y][x].bgR,
+				bgG:         ai.frame[frame][y][x].bgG,
+				bgB:         ai.frame[frame][y][x].bgB,
+				text:        ai.frame[frame][y][x].text,
+				transparent: ai.frame[frame][y][x].transparent,
+				clearBg:     ai.frame[frame][y][x].clearBg,
+				source:      ai.frame[frame][y][x].source,
 			},
 			nil
 	}
@@ -284,6 +333,18 @@ func (ai *ANSImage) Render() string {
 // Can specify if background color will be disabled in dithering mode.
 // (Nice info for ANSI True Colour - https://gist.github.com/XVilka/8346728)
 func (ai *ANSImage) RenderExt(frame int, disableBgColor bool) string {
+	return ai.RenderFiltered(frame, disableBgColor, nil)
+}
+
+// RenderFiltered returns the ANSI-compatible string form of ANSImage like RenderExt,
+// passing every color through cf first (nil renders the colors unchanged).
+func (ai *ANSImage) RenderFiltered(frame int, disableBgColor bool, cf ColorFunc) (render string) {
+	if ai.renderHook != nil {
+		start := time.Now()
+		defer func() {
+			ai.observe(frame, len(render), start)
+		}()
+	}
 	type renderData struct {
 		row    int
 		render string
@@ -300,9 +361,9 @@ func (ai *ANSImage) RenderExt(frame int, disableBgColor bool) string {
 			for n, r := 0, y+1; (n <= ai.maxprocs) && (2*r+1 < ai.h); n, r = n+1, y+n+1 {
 				go func(r, y int) {
 					var str string
+					hr := HalfBlockRenderer{ColorFunc: cf}
 					for x := 0; x < ai.w; x++ {
-						str += ai.frame[frame][y][x].RenderExt(disableBgColor)   // upper pixel
-						str += ai.frame[frame][y+1][x].RenderExt(disableBgColor) // lower pixel
+						str += hr.RenderCell(ai.frame[frame][y][x], ai.frame[frame][y+1][x]) // upper and lower pixels
 					}
 					str += fmt.Sprintf("%s[0m%s", backslash033, backslashN) // reset ansi style
 					ch <- renderData{row: r, render: str}
@@ -330,7 +391,7 @@ func (ai *ANSImage) RenderExt(frame int, disableBgColor bool) string {
 			go func(y int) {
 				var str string
 				for x := 0; x < ai.w; x++ {
-					str += ai.frame[frame][y][x].RenderExt(disableBgColor)
+					str += ai.frame[frame][y][x].renderFiltered(disableBgColor, cf)
 				}
 				str += fmt.Sprintf("%s[0m%s", backslash033, backslashN) // reset ansi style
 				ch <- renderData{row: y, render: str}
@@ -344,6 +405,18 @@ func (ai *ANSImage) RenderExt(frame int, disableBgColor bool) string {
 	return strings.Join(rows, "")
 }
 
+// RenderTo writes the ANSI-compatible form of frame to w, row by row, without
+// building the whole frame in memory first. The output is the same as RenderExt.
+func (ai *ANSImage) RenderTo(frame int, w io.Writer, disableBgColor bool) error {
+	return ai.RenderFilteredTo(frame, w, disableBgColor, nil)
+}
+
+// RenderFilteredTo writes frame to w like RenderTo, passing every color through cf
+// first (nil renders the colors unchanged).
+func (ai *ANSImage) RenderFilteredTo(frame int, w io.Writer, disableBgColor bool, cf ColorFunc) error {
+	return ai.RenderWith(frame, w, TrueColorRenderer{DisableBgColor: disableBgColor, ColorFunc: cf})
+}
+
 // Draw writes the ANSImage to standard output (terminal).
 func (ai *ANSImage) Draw() {
 	ai.DrawExt(0, false)
@@ -388,9 +461,16 @@ func New(h, w, frameCount int, bg color.Color, dm DitheringMode) (*ANSImage, err
 					G:          0,
 					B:          0,
 					Brightness: 0,
+					bgR:        uint8(r),
+					bgG:        uint8(g),
+					bgB:        uint8(b),
 					source:     ansimage,
 					upper:      ((dm == NoDithering) && (y%2 == 0)),
 				}
+				if gradient, ok := bg.(Gradient); ok {
+					c := gradient.At(y, x, h, w)
+					v[y][x].bgR, v[y][x].bgG, v[y][x].bgB = c.R, c.G, c.B
+				}
 			}
 		}
 		return v
@@ -400,95 +480,310 @@ func New(h, w, frameCount int, bg color.Color, dm DitheringMode) (*ANSImage, err
 		ansimage.frame[i] = newFrame()
 	}
 
+	ansimage.blockY, ansimage.blockX = BlockSize(dm)
+
 	return ansimage, nil
 }
 
-// NewFromReader creates a new ANSImage from an io.Reader.
-// Background color is used to fill when image has transparency or dithering mode is enabled.
-// Dithering mode is used to specify the way that ANSImage render ANSI-pixels (char/block elements).
-func NewFromReader(reader io.Reader, bg color.Color, dm DitheringMode) (*ANSImage, error) {
-	gifImage, err := gif.DecodeAll(reader)
+// Load creates a new ANSImage from a GIF read from r, set up with options:
+// WithSize (unscaled by default), WithScaleMode, WithDithering,
+// WithBackground, WithMaxProcs and all the others. Animated PNGs are loaded
+// like GIFs, and static PNG, JPEG and WebP images as a single frame shown
+// once (see loadPNG and loadStill).
+//
+//	ai, err := ansimage.Load(r,
+//		ansimage.WithSize(ansimage.ScaledSize(24, 80, ansimage.NoDithering)),
+//		ansimage.WithBackground(color.White))
+func Load(r io.Reader, opts ...Option) (*ANSImage, error) {
+	br := bufio.NewReader(r)
+	if isPNG(br) {
+		a, err := apng.DecodeAll(br)
+		if err != nil {
+			return nil, err
+		}
+		return loadPNG(a, newLoadConfig(opts))
+	}
+	if !isGIF(br) {
+		img, _, err := image.Decode(br)
+		if err != nil {
+			return nil, err
+		}
+		return loadStill(img, newLoadConfig(opts))
+	}
+
+	gifImage, err := gif.DecodeAll(br)
 	if err != nil {
 		return nil, err
 	}
+	return loadGIF(gifImage, newLoadConfig(opts))
+}
+
+// loadGIF creates a new ANSImage from a decoded GIF with cfg.
+func loadGIF(gifImage *gif.GIF, cfg *loadConfig) (*ANSImage, error) {
+	if cfg.sizeY > 0 {
+		return newScaledFromGIF(gifImage, cfg)
+	}
+	return newFromGIF(gifImage, cfg)
+}
+
+// NewFromReader creates a new ANSImage from an io.Reader.
+// Background color is used to fill when image has transparency or dithering mode is enabled.
+// Dithering mode is used to specify the way that ANSImage render ANSI-pixels (char/block elements).
+// Options customize the loading (e.g. WithChromaKey). See Load.
+func NewFromReader(reader io.Reader, bg color.Color, dm DitheringMode, opts ...Option) (*ANSImage, error) {
+	return Load(reader, withArgs(opts, WithSize(0, 0), WithBackground(bg), WithDithering(dm))...)
+}
 
+// NewFromGIF creates a new ANSImage from a decoded GIF, for callers that decode
+// or generate GIFs themselves. See NewFromReader.
+func NewFromGIF(gifImage *gif.GIF, bg color.Color, dm DitheringMode, opts ...Option) (*ANSImage, error) {
+	return loadGIF(gifImage, newLoadConfig(withArgs(opts, WithSize(0, 0), WithBackground(bg), WithDithering(dm))))
+}
+
+// newFromGIF creates an unscaled ANSImage from a decoded GIF with cfg.
+func newFromGIF(gifImage *gif.GIF, cfg *loadConfig) (*ANSImage, error) {
 	proxy := gifProxy{
-		image: make([]image.Image, len(gifImage.Image)),
-		delay: make([]int, len(gifImage.Delay)),
+		image:     make([]image.Image, len(gifImage.Image)),
+		delay:     make([]int, len(gifImage.Delay)),
+		loopCount: gifImage.LoopCount,
 	}
 
-	bounds := gifImage.Image[0].Bounds()
+	bounds := gifCanvas(gifImage)
 	img := image.NewRGBA(bounds)
 
 	for frame, palettedImg := range gifImage.Image {
 		proxy.delay[frame] = gifImage.Delay[frame]
 
-		draw.Draw(img, bounds, palettedImg, image.ZP, draw.Over)
-		proxy.image[frame] = img
+		dispose := drawGIFFrame(img, palettedImg, gifDisposal(gifImage, frame))
+		proxy.image[frame] = cfg.transformFrame(cfg.prepareFrame(img))
+		dispose()
 	}
 
-	return createANSImage(&proxy, bg, dm)
+	return cfg.apply(createANSImage(&proxy, cfg.bg, cfg.dithering, cfg))
 }
 
 // NewScaledFromReader creates a new scaled ANSImage from an io.Reader.
 // Background color is used to fill when image has transparency or dithering mode is enabled.
 // Dithering mode is used to specify the way that ANSImage render ANSI-pixels (char/block elements).
-func NewScaledFromReader(reader io.Reader, y, x int, bg color.Color, sm ScaleMode, dm DitheringMode) (*ANSImage, error) {
-	gifImage, err := gif.DecodeAll(reader)
-	if err != nil {
-		return nil, err
-	}
+// Options customize the loading (e.g. WithChromaKey). See Load.
+func NewScaledFromReader(reader io.Reader, y, x int, bg color.Color, sm ScaleMode, dm DitheringMode, opts ...Option) (*ANSImage, error) {
+	return Load(reader, withArgs(opts, WithSize(y, x), WithScaleMode(sm), WithBackground(bg), WithDithering(dm))...)
+}
+
+// NewScaledFromGIF creates a new scaled ANSImage from a decoded GIF, for
+// callers that decode or generate GIFs themselves. See NewScaledFromReader.
+func NewScaledFromGIF(gifImage *gif.GIF, y, x int, bg color.Color, sm ScaleMode, dm DitheringMode, opts ...Option) (*ANSImage, error) {
+	return loadGIF(gifImage, newLoadConfig(withArgs(opts, WithSize(y, x), WithScaleMode(sm), WithBackground(bg), WithDithering(dm))))
+}
 
+// newScaledFromGIF creates an ANSImage from a decoded GIF scaled to the size of cfg.
+func newScaledFromGIF(gifImage *gif.GIF, cfg *loadConfig) (*ANSImage, error) {
 	proxy := gifProxy{
-		image: make([]image.Image, len(gifImage.Image)),
-		delay: make([]int, len(gifImage.Delay)),
+		image:     make([]image.Image, len(gifImage.Image)),
+		delay:     make([]int, len(gifImage.Delay)),
+		loopCount: gifImage.LoopCount,
 	}
 
-	bounds := gifImage.Image[0].Bounds()
+	bounds := gifCanvas(gifImage)
 	img := image.NewRGBA(bounds)
 
+	sm := cfg.scaleMode
+	crop := bounds
+	if sm&AutoCrop != 0 {
+		crop = autoCropBounds(gifImage, bounds)
+		sm &^= AutoCrop
+	}
+
+	scale, ok := scaler(sm)
+	if !ok {
+		panic(errUnknownScaleMode)
+	}
+
 	for frame, palettedImg := range gifImage.Image {
 		proxy.delay[frame] = gifImage.Delay[frame]
 
-		draw.Draw(img, bounds, palettedImg, image.ZP, draw.Over)
+		dispose := drawGIFFrame(img, palettedImg, gifDisposal(gifImage, frame))
+		var src image.Image = img.SubImage(crop)
+		if cfg.filtered() {
+			src = cfg.prepareFrame(img).SubImage(crop)
+		}
+		proxy.image[frame] = cfg.scaleFrame(scale, cfg.transformFrame(src))
+		dispose()
+	}
+	cfg.smooth(&proxy)
+
+	return cfg.apply(createANSImage(&proxy, cfg.bg, cfg.dithering, cfg))
+}
 
-		switch sm {
-		case ScaleModeResize:
-			proxy.image[frame] = imaging.Resize(img, x, y, imaging.Lanczos)
-		case ScaleModeFill:
-			proxy.image[frame] = imaging.Fill(img, x, y, imaging.Center, imaging.Lanczos)
-		case ScaleModeFit:
-			proxy.image[frame] = imaging.Fit(img, x, y, imaging.Lanczos)
-		default:
+// NewFromFrames creates a new ANSImage from frames of the same size, shown
+// for delays (in 100ths of a second), for procedural animations and video
+// decoders: every frame is a whole picture, unlike the frames of GIFs.
+// Background color, dithering mode and options are used like in NewFromReader;
+// WithSize scales the frames like in Load.
+func NewFromFrames(frames []image.Image, delays []int, bg color.Color, dm DitheringMode, opts ...Option) (*ANSImage, error) {
+	if len(frames) == 0 {
+		return nil, ErrNoFrames
+	}
+	if len(delays) != len(frames) {
+		return nil, ErrFrameDelayMismatch
+	}
+	cfg := newLoadConfig(withArgs(opts, WithDithering(dm)))
+	var scale Scaler
+	if cfg.sizeY > 0 {
+		var ok bool
+		if scale, ok = scaler(cfg.scaleMode &^ AutoCrop); !ok {
 			panic(errUnknownScaleMode)
 		}
 	}
 
-	return createANSImage(&proxy, bg, dm)
+	proxy := gifProxy{
+		image: make([]image.Image, len(frames)),
+		delay: append([]int(nil), delays...),
+	}
+	bounds := frames[0].Bounds()
+	for i, frame := range frames {
+		img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(img, img.Bounds(), frame, bounds.Min, draw.Src)
+		if cfg.filtered() {
+			img = cfg.prepareFrame(img)
+		}
+		proxy.image[i] = cfg.transformFrame(img)
+		if scale != nil {
+			proxy.image[i] = cfg.scaleFrame(scale, proxy.image[i])
+		}
+	}
+
+	return cfg.apply(createANSImage(&proxy, bg, dm, cfg))
+}
+
+// scaleFrame scales src to the size of cfg with scale. The scaled pixels are
+// stretched to fill the cells of the dithering mode of cfg.
+func (cfg *loadConfig) scaleFrame(scale Scaler, src image.Image) image.Image {
+	y, x := cfg.sizeY, cfg.sizeX
+	blockY, blockX := cfg.blockSize(cfg.dithering)
+	if blockY != 2*blockX {
+		// the pixels of dm aren't square (e.g. quadrants are twice as tall
+		// as wide): scale to square pixels, then stretch to the cells
+		scaled := scale.Scale(src, y, x*blockY/(2*blockX))
+		b := scaled.Bounds()
+		return imaging.Resize(scaled, b.Dx()*2*blockX/blockY, b.Dy(), imaging.Lanczos)
+	}
+	return scale.Scale(src, y, x)
+}
+
+// gifCanvas returns the logical screen of g, from the GIF header, or the
+// bounds of its first frame when the header has no size.
+func gifCanvas(g *gif.GIF) image.Rectangle {
+	if g.Config.Width > 0 && g.Config.Height > 0 {
+		return image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	}
+	return g.Image[0].Bounds()
+}
+
+// drawGIFFrame composites frame over canvas at the frame's own bounds, as
+// frames often cover only the part of the screen that changed. It returns
+// the func disposing of the frame as disposal asks (see gif.GIF.Disposal),
+// to call once the frame was used and before the next one is drawn, like
+// the dispose ops of APNG frames.
+func drawGIFFrame(canvas *image.RGBA, frame *image.Paletted, disposal byte) func() {
+	area := frame.Bounds().Intersect(canvas.Bounds())
+	var previous *image.RGBA
+	if disposal == gif.DisposalPrevious {
+		previous = image.NewRGBA(area)
+		draw.Draw(previous, area, canvas, area.Min, draw.Src)
+	}
+
+	draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+	return func() {
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, area, image.Transparent, image.ZP, draw.Src)
+		case gif.DisposalPrevious:
+			draw.Draw(canvas, area, previous, area.Min, draw.Src)
+		}
+	}
+}
+
+// gifDisposal returns the disposal method of frame of g, none if g has no
+// disposal methods (like GIFs made by programs rather than decoded).
+func gifDisposal(g *gif.GIF, frame int) byte {
+	if frame < len(g.Disposal) {
+		return g.Disposal[frame]
+	}
+	return 0
+}
+
+// autoCropBounds returns the part of the GIF canvas left after trimming the borders
+// keeping the color of the top-left pixel on every frame.
+func autoCropBounds(g *gif.GIF, bounds image.Rectangle) image.Rectangle {
+	canvas := image.NewRGBA(bounds)
+	var ref color.RGBA
+
+	near := func(a, b uint8) bool {
+		return int(a)-int(b) <= autoCropTolerance && int(b)-int(a) <= autoCropTolerance
+	}
+
+	minX, minY, maxX, maxY := bounds.Max.X, bounds.Max.Y, bounds.Min.X, bounds.Min.Y
+	for frame, palettedImg := range g.Image {
+		dispose := drawGIFFrame(canvas, palettedImg, gifDisposal(g, frame))
+		if frame == 0 {
+			ref = canvas.RGBAAt(bounds.Min.X, bounds.Min.Y)
+		}
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := canvas.RGBAAt(x, y)
+				if near(c.R, ref.R) && near(c.G, ref.G) && near(c.B, ref.B) && near(c.A, ref.A) {
+					continue
+				}
+				if x < minX {
+					minX = x
+				}
+				if x >= maxX {
+					maxX = x + 1
+				}
+				if y < minY {
+					minY = y
+				}
+				if y >= maxY {
+					maxY = y + 1
+				}
+			}
+		}
+		dispose()
+	}
+
+	if minX >= maxX || minY >= maxY {
+		logf("AutoCrop: uniform image, keeping the whole canvas")
+		return bounds // uniform image: nothing to keep but the whole canvas
+	}
+	return image.Rect(minX, minY, maxX, maxY)
 }
 
 // NewFromFile creates a new ANSImage from a file.
 // Background color is used to fill when image has transparency or dithering mode is enabled.
 // Dithering mode is used to specify the way that ANSImage render ANSI-pixels (char/block elements).
-func NewFromFile(name string, bg color.Color, dm DitheringMode) (*ANSImage, error) {
+// Options customize the loading (e.g. WithChromaKey).
+func NewFromFile(name string, bg color.Color, dm DitheringMode, opts ...Option) (*ANSImage, error) {
 	reader, err := os.Open(name)
 	if err != nil {
 		return nil, err
 	}
 	defer reader.Close()
-	return NewFromReader(reader, bg, dm)
+	return NewFromReader(reader, bg, dm, opts...)
 }
 
 // NewScaledFromFile creates a new scaled ANSImage from a file.
 // Background color is used to fill when image has transparency or dithering mode is enabled.
 // Dithering mode is used to specify the way that ANSImage render ANSI-pixels (char/block elements).
-func NewScaledFromFile(name string, y, x int, bg color.Color, sm ScaleMode, dm DitheringMode) (*ANSImage, error) {
+// Options customize the loading (e.g. WithChromaKey).
+func NewScaledFromFile(name string, y, x int, bg color.Color, sm ScaleMode, dm DitheringMode, opts ...Option) (*ANSImage, error) {
 	reader, err := os.Open(name)
 	if err != nil {
 		return nil, err
 	}
 	defer reader.Close()
-	return NewScaledFromReader(reader, y, x, bg, sm, dm)
+	return NewScaledFromReader(reader, y, x, bg, sm, dm, opts...)
 }
 
 // ClearTerminal clears current terminal buffer using ANSI escape code.
@@ -500,17 +795,29 @@ func ClearTerminal() {
 // createANSImage loads data from an image and returns an ANSImage.
 // Background color is used to fill when image has transparency or dithering mode is enabled.
 // Dithering mode is used to specify the way that ANSImage render ANSI-pixels (char/block elements).
-func createANSImage(g *gifProxy, bg color.Color, dm DitheringMode) (*ANSImage, error) {
+// The block size comes from cfg (see WithBlockSize).
+func createANSImage(g *gifProxy, bg color.Color, dm DitheringMode, cfg *loadConfig) (*ANSImage, error) {
 	var rgbaOut *image.RGBA
 	bounds := g.image[0].Bounds()
 
 	yMin, xMin := bounds.Min.Y, bounds.Min.X
 	yMax, xMax := bounds.Max.Y, bounds.Max.X
 
+	blockY, blockX := cfg.blockSize(dm)
+	if dm == NoDithering {
+		// always sets an even number of ANSIPixel rows...
+		yMax = yMax - yMax%2 // one for upper pixel and another for lower pixel --> without dithering
+	} else {
+		yMax = yMax / blockY // always sets 1 ANSIPixel block...
+		xMax = xMax / blockX // per 8x4 (or BlockSize) real pixels --> with dithering
+	}
+
 	ansimage, err := New(yMax, xMax, len(g.image), bg, dm)
 	if err != nil {
 		return nil, err
 	}
+	ansimage.blockY, ansimage.blockX = blockY, blockX
+	ansimage.loopCount = g.loopCount
 
 	// Create ANSIframe for each gif frame.
 	for frame, img := range g.image {
@@ -519,9 +826,9 @@ func createANSImage(g *gifProxy, bg color.Color, dm DitheringMode) (*ANSImage, e
 
 		// do compositing only if background color has no transparency (thank you @disq for the idea!)
 		// (info - https://stackoverflow.com/questions/36595687/transparent-pixel-color-go-lang-image)
-		if _, _, _, a := bg.RGBA(); a >= 0xffff {
+		if _, _, _, a := bg.RGBA(); a >= 0xffff && !cfg.transparent {
 			rgbaOut = image.NewRGBA(bounds)
-			draw.Draw(rgbaOut, bounds, image.NewUniform(bg), image.ZP, draw.Src)
+			draw.Draw(rgbaOut, bounds, backgroundImage(bg, bounds), bounds.Min, draw.Src)
 			draw.Draw(rgbaOut, bounds, img, image.ZP, draw.Over)
 		} else {
 			if v, ok := img.(*image.RGBA); ok {
@@ -532,50 +839,84 @@ func createANSImage(g *gifProxy, bg color.Color, dm DitheringMode) (*ANSImage, e
 			}
 		}
 
-		if dm == NoDithering {
-			// always sets an even number of ANSIPixel rows...
-			yMax = yMax - yMax%2 // one for upper pixel and another for lower pixel --> without dithering
-		} else {
-			yMax = yMax / BlockSizeY // always sets 1 ANSIPixel block...
-			xMax = xMax / BlockSizeX // per 8x4 real pixels --> with dithering
-		}
-
 		if dm == NoDithering {
 			for y := yMin; y < yMax; y++ {
 				for x := xMin; x < xMax; x++ {
 					v := rgbaOut.RGBAAt(x, y)
+					if cfg.transparent {
+						v.R, v.G, v.B = unpremultiply(v)
+					}
 					if err := ansimage.SetAt(frame, y, x, v.R, v.G, v.B, 0); err != nil {
 						return nil, err
 					}
+					ansimage.frame[frame][y][x].transparent = cfg.skipped(v.A)
 				}
 			}
 		} else {
-			pixelCount := BlockSizeY * BlockSizeX
+			pixelCount := blockY * blockX
+			subCells := make([]color.RGBA, 0, pixelCount) // pixels of a quadrants or sextants block
+			var edges *edgeField
+			if dm == DitheringWithEdges {
+				edges = newEdgeField(rgbaOut)
+			}
 
 			for y := yMin; y < yMax; y++ {
 				for x := xMin; x < xMax; x++ {
 
 					var sumR, sumG, sumB, sumBri float64
-					for dy := 0; dy < BlockSizeY; dy++ {
-						py := BlockSizeY*y + dy
-
-						for dx := 0; dx < BlockSizeX; dx++ {
-							px := BlockSizeX*x + dx
-
-							pixel := rgbaOut.At(px, py)
+					var opaque int // pixels averaged, all but the transparent ones with WithTransparency
+					var dots uint8
+					subCells = subCells[:0]
+					for dy := 0; dy < blockY; dy++ {
+						py := blockY*y + dy
+
+						for dx := 0; dx < blockX; dx++ {
+							px := blockX*x + dx
+
+							pixel := rgbaOut.RGBAAt(px, py)
+							if dm == DitheringWithQuadrants || dm == DitheringWithSextants {
+								subCells = append(subCells, pixel)
+							}
+							if cfg.skipped(pixel.A) {
+								continue
+							}
 							color, _ := colorful.MakeColor(pixel)
 							_, _, v := color.Hsv()
 							sumR += color.R
 							sumG += color.G
 							sumB += color.B
 							sumBri += v
+							opaque++
+							if dm == DitheringWithBraille && v > brailleThreshold {
+								dots |= brailleDots[dy][dx]
+							}
 						}
 					}
 
-					r := uint8(sumR/float64(pixelCount)*255.0 + 0.5)
-					g := uint8(sumG/float64(pixelCount)*255.0 + 0.5)
-					b := uint8(sumB/float64(pixelCount)*255.0 + 0.5)
-					brightness := uint8(sumBri/float64(pixelCount)*255.0 + 0.5)
+					ap := ansimage.frame[frame][y][x]
+					ap.transparent = opaque == 0
+					if opaque == 0 {
+						opaque = 1 // undrawn, black
+					}
+					r := uint8(sumR/float64(opaque)*255.0 + 0.5)
+					g := uint8(sumG/float64(opaque)*255.0 + 0.5)
+					b := uint8(sumB/float64(opaque)*255.0 + 0.5)
+					brightness := uint8(sumBri/float64(opaque)*255.0 + 0.5)
+
+					ap.dots = dots
+					ap.clearBg = cfg.transparent
+					if len(subCells) > 0 && opaque < pixelCount {
+						ap.dots = cfg.opaqueDots(subCells) // drawn in their average color
+					} else if len(subCells) > 0 {
+						var fg, bg color.RGBA
+						ap.dots, fg, bg = splitColors(subCells)
+						r, g, b = fg.R, fg.G, fg.B
+						ap.bgR, ap.bgG, ap.bgB = bg.R, bg.G, bg.B
+						ap.clearBg = false
+					}
+					if edges != nil {
+						ap.dots = edges.cell(blockY*y, blockX*x, blockY, blockX)
+					}
 
 					if err := ansimage.SetAt(frame, y, x, r, g, b, brightness); err != nil {
 						return nil, err
@@ -587,3 +928,25 @@ func createANSImage(g *gifProxy, bg color.Color, dm DitheringMode) (*ANSImage, e
 
 	return ansimage, nil
 }
+
+// opaqueDots returns the dots of a quadrants or sextants block made of the
+// pixels cells: the ones drawn with the alpha threshold of cfg.
+func (cfg *loadConfig) opaqueDots(cells []color.RGBA) uint8 {
+	var dots uint8
+	for i, c := range cells {
+		if !cfg.skipped(c.A) {
+			dots |= 1 << uint(i)
+		}
+	}
+	return dots
+}
+
+// unpremultiply returns the color of the pixel c, whose channels are
+// premultiplied by its alpha, as if it were opaque.
+func unpremultiply(c color.RGBA) (uint8, uint8, uint8) {
+	if c.A == 0 || c.A == 0xff {
+		return c.R, c.G, c.B
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return n.R, n.G, n.B
+}
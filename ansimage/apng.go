@@ -0,0 +1,88 @@
+package ansimage
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/kettek/apng"
+)
+
+// pngMagic starts every PNG file, animated or not.
+const pngMagic = "\x89PNG\r\n\x1a\n"
+
+// isPNG reports whether the data buffered in r starts like a PNG file.
+func isPNG(r *bufio.Reader) bool {
+	magic, _ := r.Peek(len(pngMagic))
+	return bytes.Equal(magic, []byte(pngMagic))
+}
+
+// loadPNG creates a new ANSImage from a PNG file with cfg: an animation with
+// the frames and delays of an APNG, or a still for a plain PNG.
+func loadPNG(a apng.APNG, cfg *loadConfig) (*ANSImage, error) {
+	frames := a.Frames
+	if len(frames) > 1 && frames[0].IsDefault {
+		frames = frames[1:] // the image shown by viewers without APNG support
+	}
+	if len(frames) < 2 {
+		return loadStill(frames[0].Image, cfg)
+	}
+
+	proxy := gifProxy{
+		image: make([]image.Image, len(frames)),
+		delay: make([]int, len(frames)),
+	}
+	// APNG plays LoopCount times (0 forever), GIF loops LoopCount more times
+	switch {
+	case a.LoopCount == 1:
+		proxy.loopCount = -1
+	case a.LoopCount > 1:
+		proxy.loopCount = int(a.LoopCount) - 1
+	}
+
+	var scale Scaler
+	if cfg.sizeY > 0 {
+		var ok bool
+		if scale, ok = scaler(cfg.scaleMode &^ AutoCrop); !ok {
+			panic(errUnknownScaleMode)
+		}
+	}
+
+	canvas := image.NewRGBA(a.Frames[0].Image.Bounds())
+	for i, frame := range frames {
+		proxy.delay[i] = int(math.Round(frame.GetDelay() * 100))
+
+		fb := frame.Image.Bounds()
+		area := image.Rect(0, 0, fb.Dx(), fb.Dy()).Add(image.Pt(frame.XOffset, frame.YOffset)).Add(canvas.Bounds().Min)
+		var previous *image.RGBA
+		if frame.DisposeOp == apng.DISPOSE_OP_PREVIOUS {
+			previous = image.NewRGBA(area)
+			draw.Draw(previous, area, canvas, area.Min, draw.Src)
+		}
+
+		op := draw.Over
+		if frame.BlendOp == apng.BLEND_OP_SOURCE {
+			op = draw.Src
+		}
+		draw.Draw(canvas, area, frame.Image, fb.Min, op)
+
+		img := cfg.transformFrame(cfg.prepareFrame(canvas))
+		if scale != nil {
+			proxy.image[i] = cfg.scaleFrame(scale, img)
+		} else {
+			proxy.image[i] = img
+		}
+
+		switch frame.DisposeOp {
+		case apng.DISPOSE_OP_BACKGROUND:
+			draw.Draw(canvas, area, image.Transparent, image.ZP, draw.Src)
+		case apng.DISPOSE_OP_PREVIOUS:
+			draw.Draw(canvas, area, previous, area.Min, draw.Src)
+		}
+	}
+	cfg.smooth(&proxy)
+
+	return cfg.apply(createANSImage(&proxy, cfg.bg, cfg.dithering, cfg))
+}
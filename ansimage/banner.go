@@ -0,0 +1,93 @@
+package ansimage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// bannerFace is the bundled bitmap font used to draw text (7x13 pixels per character).
+var bannerFace = basicfont.Face7x13
+
+// bannerLineHeight is the height of a line of text in pixels.
+const bannerLineHeight = 13
+
+// errInvalidSpeed occurs when a scrolling speed is not positive.
+var errInvalidSpeed = errors.New("ANSImage: speed must be positive")
+
+// NewBanner creates a single-frame ANSImage filling rows×cols terminal cells with
+// text drawn in a bundled bitmap font, for intro cards, error screens or captions.
+// Lines are separated by "\n" and centered; whatever doesn't fit is clipped.
+func NewBanner(rows, cols int, text string, fg, bg color.Color) (*ANSImage, error) {
+	h, w := 2*rows, cols // half blocks: two pixels per cell
+	lines := strings.Split(SanitizeText(text), "\n")
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), backgroundImage(bg, img.Bounds()), image.ZP, draw.Src)
+	drawTextLines(img, lines, (h-len(lines)*bannerLineHeight)/2, fg)
+
+	proxy := gifProxy{
+		image: []image.Image{img},
+		delay: []int{0},
+	}
+	return createANSImage(&proxy, bg, NoDithering, newLoadConfig(nil))
+}
+
+// drawTextLines draws lines horizontally centered on img, the first one with its top at y.
+func drawTextLines(img draw.Image, lines []string, y int, fg color.Color) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(fg),
+		Face: bannerFace,
+	}
+	w := img.Bounds().Dx()
+
+	for i, line := range lines {
+		top := y + i*bannerLineHeight
+		if top+bannerLineHeight <= 0 || top >= img.Bounds().Dy() {
+			continue // off image
+		}
+		width := d.MeasureString(line).Round()
+		d.Dot = fixed.P((w-width)/2, top+bannerFace.Ascent)
+		d.DrawString(line)
+	}
+}
+
+// NewCredits creates an ANSImage filling rows×cols terminal cells in which text
+// scrolls up like film credits, at speed terminal rows per second. The text
+// enters from the bottom edge and the animation ends once it has left the top.
+func NewCredits(rows, cols int, text string, fg, bg color.Color, speed float64) (*ANSImage, error) {
+	if speed <= 0 {
+		return nil, errInvalidSpeed
+	}
+
+	h, w := 2*rows, cols // half blocks: two pixels per cell
+	lines := strings.Split(SanitizeText(text), "\n")
+
+	// one pixel (half a row) per frame
+	delay := int(100/(2*speed) + 0.5)
+	if delay < 1 {
+		delay = 1
+	}
+
+	frameCount := h + len(lines)*bannerLineHeight
+	proxy := gifProxy{
+		image: make([]image.Image, frameCount),
+		delay: make([]int, frameCount),
+	}
+	for frame := 0; frame < frameCount; frame++ {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(img, img.Bounds(), backgroundImage(bg, img.Bounds()), image.ZP, draw.Src)
+		drawTextLines(img, lines, h-frame, fg)
+
+		proxy.image[frame] = img
+		proxy.delay[frame] = delay
+	}
+	return createANSImage(&proxy, bg, NoDithering, newLoadConfig(nil))
+}
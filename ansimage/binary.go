@@ -0,0 +1,162 @@
+package ansimage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// binaryMagic starts the binary form of an ANSImage, with its format version.
+const binaryMagic = "ANSImage3"
+
+// errBadBinary occurs when data isn't the binary form of an ANSImage.
+var errBadBinary = errors.New("ANSImage: invalid binary data")
+
+// binaryFits reports whether frames frames of h×w pixels, of at least 10
+// bytes each, can be read from n bytes, so that corrupt sizes don't make
+// UnmarshalBinary allocate more than the data could fill.
+func binaryFits(h, w, frames, n int) bool {
+	if h <= 0 || w <= 0 || frames < 0 {
+		return false
+	}
+	return frames <= n/10/h/w
+}
+
+// MarshalBinary returns the decoded frames and settings of ANSImage in a
+// compact form, to share them between processes without decoding the GIF
+// again (see UnmarshalBinary). The render hook isn't included.
+func (ai *ANSImage) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(binaryMagic)
+	zw, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	w := bufio.NewWriter(zw)
+
+	varint := func(v int) {
+		var b [binary.MaxVarintLen64]byte
+		w.Write(b[:binary.PutVarint(b[:], int64(v))])
+	}
+	varint(ai.h)
+	varint(ai.w)
+	varint(ai.maxprocs)
+	w.Write([]byte{ai.bgR, ai.bgG, ai.bgB, byte(ai.dithering)})
+	varint(ai.blockY)
+	varint(ai.blockX)
+	varint(ai.loopCount)
+	varint(len(ai.charRamp))
+	for _, r := range ai.charRamp {
+		varint(int(r))
+	}
+	varint(len(ai.frame))
+	for i, frame := range ai.frame {
+		varint(ai.delay[i])
+		for _, row := range frame {
+			for _, ap := range row {
+				flags := byte(0)
+				if ap.upper {
+					flags |= 1
+				}
+				if ap.transparent {
+					flags |= 2
+				}
+				if ap.clearBg {
+					flags |= 4
+				}
+				w.Write([]byte{ap.Brightness, ap.R, ap.G, ap.B, flags, ap.dots, ap.bgR, ap.bgG, ap.bgB})
+				varint(len(ap.text))
+				w.WriteString(ap.text)
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the frames and settings of ANSImage with the ones
+// returned by MarshalBinary.
+func (ai *ANSImage) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(binaryMagic)) {
+		return errBadBinary
+	}
+	raw, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(data[len(binaryMagic):])))
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(raw)
+
+	var failed bool
+	varint := func() int {
+		v, err := binary.ReadVarint(r)
+		if err != nil {
+			failed = true
+		}
+		return int(v)
+	}
+	var out ANSImage
+	out.h, out.w, out.maxprocs = varint(), varint(), varint()
+	var bg [4]byte
+	if _, err := io.ReadFull(r, bg[:]); err != nil {
+		return errBadBinary
+	}
+	out.bgR, out.bgG, out.bgB, out.dithering = bg[0], bg[1], bg[2], DitheringMode(bg[3])
+	out.blockY, out.blockX, out.loopCount = varint(), varint(), varint()
+	if n := varint(); n > 0 && n <= r.Len() {
+		out.charRamp = make([]rune, n)
+		for i := range out.charRamp {
+			out.charRamp[i] = rune(varint())
+		}
+	}
+
+	frames := varint()
+	if failed || !binaryFits(out.h, out.w, frames, r.Len()) {
+		return errBadBinary
+	}
+	out.frame = make([]ANSIframe, frames)
+	out.delay = make([]int, frames)
+	var px [9]byte
+	for i := range out.frame {
+		out.delay[i] = varint()
+		frame := make(ANSIframe, out.h)
+		for y := range frame {
+			frame[y] = make([]*ANSIpixel, out.w)
+			for x := range frame[y] {
+				if _, err := io.ReadFull(r, px[:]); err != nil {
+					return errBadBinary
+				}
+				frame[y][x] = &ANSIpixel{
+					Brightness: px[0],
+					R:          px[1], G: px[2], B: px[3],
+					upper:       px[4]&1 != 0,
+					transparent: px[4]&2 != 0,
+					clearBg:     px[4]&4 != 0,
+					dots:        px[5],
+					bgR:         px[6], bgG: px[7], bgB: px[8],
+					source: ai,
+				}
+				if n := varint(); n > 0 && n <= r.Len() {
+					text := make([]byte, n)
+					r.Read(text)
+					frame[y][x].text = string(text)
+				} else if n != 0 {
+					return errBadBinary
+				}
+			}
+		}
+		out.frame[i] = frame
+	}
+	if failed {
+		return errBadBinary
+	}
+
+	*ai = out
+	return nil
+}
@@ -0,0 +1,118 @@
+package ansimage
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	ai, err := New(4, 6, 2, color.RGBA{10, 20, 30, 0xff}, NoDithering)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ai.delay = []int{5, 9}
+	ai.loopCount = 3
+	ai.SetCharRamp([]rune(" .:#"))
+	ai.SetAt(1, 2, 3, 200, 100, 50, 150)
+	ai.frame[0][1][1].transparent = true
+	if err := ai.OverlayText(0, 0, 0, "hi", color.White, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ai.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out ANSImage
+	if err := out.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Height() != 4 || out.Width() != 6 || out.FrameCount() != 2 || out.LoopCount() != 3 {
+		t.Fatalf("decoded %dx%d, %d frames, %d loops", out.Height(), out.Width(), out.FrameCount(), out.LoopCount())
+	}
+	if out.FrameDelay(0) != 5 || out.FrameDelay(1) != 9 || string(out.charRamp) != " .:#" {
+		t.Errorf("decoded delays %v, ramp %q", out.delay, string(out.charRamp))
+	}
+	if out.bgR != 10 || out.bgG != 20 || out.bgB != 30 {
+		t.Errorf("decoded background %d,%d,%d", out.bgR, out.bgG, out.bgB)
+	}
+	for frame := range ai.frame {
+		for y := 0; y < ai.h; y++ {
+			for x := 0; x < ai.w; x++ {
+				want, got := ai.frame[frame][y][x], out.frame[frame][y][x]
+				if got.R != want.R || got.G != want.G || got.B != want.B || got.Brightness != want.Brightness ||
+					got.upper != want.upper || got.transparent != want.transparent || got.text != want.text {
+					t.Errorf("frame %d pixel (%d,%d) = %+v, want %+v", frame, y, x, got, want)
+				}
+			}
+		}
+	}
+}
+
+// testBinary returns the binary form of an ANSImage of h×w pixels and frames
+// frames, followed by rest.
+func testBinary(h, w, frames int, rest []byte) []byte {
+	var raw []byte
+	varint := func(v int) {
+		var b [binary.MaxVarintLen64]byte
+		raw = append(raw, b[:binary.PutVarint(b[:], int64(v))]...)
+	}
+	varint(h)
+	varint(w)
+	varint(1)
+	raw = append(raw, 0, 0, 0, 0)
+	varint(0)
+	varint(0)
+	varint(0)
+	varint(0)
+	varint(frames)
+	raw = append(raw, rest...)
+
+	var buf bytes.Buffer
+	buf.WriteString(binaryMagic)
+	zw, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	zw.Write(raw)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	valid, err := func() ([]byte, error) {
+		ai, err := New(2, 2, 1, color.Black, NoDithering)
+		if err != nil {
+			return nil, err
+		}
+		return ai.MarshalBinary()
+	}()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", []byte("ANSImage2 and some data")},
+		{"garbage", append([]byte(binaryMagic), "not deflated"...)},
+		{"truncated", valid[:len(valid)-4]},
+		{"truncated frames", testBinary(2, 2, 1, []byte{0, 1, 2, 3})},
+		{"negative size", testBinary(-2, 2, 1, nil)},
+		{"empty size", testBinary(0, 2, 1000000, nil)},
+		{"huge size", testBinary(1<<40, 1<<40, 1, nil)},
+		{"huge frame count", testBinary(2, 2, 1<<60, nil)},
+		{"overflowing size", testBinary(1<<31, 1<<31, 1<<2, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ai ANSImage
+			if err := ai.UnmarshalBinary(tt.data); err == nil {
+				t.Error("UnmarshalBinary() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package ansimage
+
+import "errors"
+
+// errBitmapColorMode occurs when a Bitmap is asked for in a color mode other than Mono or Gray4.
+var errBitmapColorMode = errors.New("ANSImage: bitmaps are Mono or Gray4")
+
+// Bitmap is a frame packed for displays with a few gray levels, like e-ink
+// badges and serial LCDs, which take raw pixels rather than escape sequences.
+type Bitmap struct {
+	Width, Height int
+	Depth         int // bits per pixel: 1 (Mono) or 2 (Gray4)
+	Stride        int // bytes per row, rows start on a byte
+
+	// Pix holds the pixels row by row, the leftmost one in the most
+	// significant bits of a byte. A pixel is its gray level, from 0 (black)
+	// to 1 (Mono) or 3 (Gray4) (white).
+	Pix []byte
+}
+
+// Level returns the gray level of the pixel at y, x.
+func (b *Bitmap) Level(y, x int) uint8 {
+	bit := x * b.Depth
+	shift := uint(8 - b.Depth - bit%8)
+	return b.Pix[y*b.Stride+bit/8] >> shift & (1<<uint(b.Depth) - 1)
+}
+
+// Bitmap returns frame as it looks in a terminal (see Rasterize, with a
+// scale of 1) in the grays of color mode cm, Mono or Gray4, approximated with
+// cd. Every pixel is a gray level: write Pix as it is for displays taking raw
+// 1 or 2 bit per pixel images.
+func (ai *ANSImage) Bitmap(frame int, cm ColorMode, cd ColorDithering) (*Bitmap, error) {
+	var depth int
+	switch cm {
+	case Mono:
+		depth = 1
+	case Gray4:
+		depth = 2
+	default:
+		return nil, errBitmapColorMode
+	}
+	if frame < 0 || frame >= len(ai.frame) {
+		return nil, ErrOutOfBounds
+	}
+
+	img := ai.Rasterize(frame, 1)
+	h, w := img.Bounds().Dy(), img.Bounds().Dx()
+	at := func(y, x int) [3]*uint8 {
+		p := img.Pix[img.PixOffset(x, y):]
+		return [3]*uint8{&p[0], &p[1], &p[2]}
+	}
+	if cd == FloydSteinberg {
+		floydSteinberg(h, w, cm, at)
+	} else if n := cd.bayerSize(); n > 0 {
+		orderedDither(h, w, cm, bayerMatrix(n), at)
+	}
+
+	b := &Bitmap{Width: w, Height: h, Depth: depth, Stride: (w*depth + 7) / 8}
+	b.Pix = make([]byte, h*b.Stride)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			px := at(y, x)
+			bit := x * depth
+			level := cm.grayLevel(*px[0], *px[1], *px[2])
+			b.Pix[y*b.Stride+bit/8] |= uint8(level) << uint(8-depth-bit%8)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,43 @@
+package ansimage
+
+import "io"
+
+// brailleThreshold is the brightness (HSV value, 0 to 1) above which a pixel raises its dot.
+const brailleThreshold = 0.5
+
+// brailleDots maps the pixels of a 4x2 block to the dots of a Braille pattern
+// (U+2800 + the sum of the raised dots).
+var brailleDots = [BrailleBlockSizeY][BrailleBlockSizeX]uint8{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// BrailleRenderer renders ANSImages dithered with braille: every terminal cell
+// is a Braille pattern with a dot raised for each bright pixel of its 4x2 block.
+type BrailleRenderer struct {
+	// DisableBgColor leaves the background color unset.
+	DisableBgColor bool
+
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+
+	// ColorMode selects the color sequences, true color by default.
+	ColorMode ColorMode
+}
+
+// RenderPixel returns the color sequences and Braille pattern of ap.
+func (br BrailleRenderer) RenderPixel(ap *ANSIpixel) string {
+	return ditheredCell(ap, string(rune(0x2800+int(ap.dots))), br.DisableBgColor, br.ColorFunc, br.ColorMode)
+}
+
+// RenderRow writes the cells of a terminal row, then resets the style.
+func (br BrailleRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	return renderRow(w, ai, frame, row, br.RenderPixel)
+}
+
+// RenderFrame writes all the terminal rows of frame, through a buffer.
+func (br BrailleRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	return renderFrame(w, ai, frame, br)
+}
@@ -0,0 +1,180 @@
+package ansimage
+
+import "errors"
+
+// ANSImage blend modes, combining the colors of an ANSImage drawn with
+// Composite (over) with the colors below (under):
+// normal (over replaces under),
+// multiply (darkens),
+// screen (lightens),
+// lighten (the lighter of both, per channel),
+// darken (the darker of both, per channel),
+// difference (the absolute difference, per channel).
+const (
+	BlendNormal = BlendMode(iota)
+	BlendMultiply
+	BlendScreen
+	BlendLighten
+	BlendDarken
+	BlendDifference
+)
+
+// BlendMode type is used for Composite blend mode constants.
+type BlendMode uint8
+
+// errUnknownBlendMode occurs when blend mode is invalid.
+var errUnknownBlendMode = errors.New("ANSImage: unknown blend mode")
+
+// blendFuncs combine a color channel under with the channel over drawn on it, by BlendMode.
+var blendFuncs = map[BlendMode]func(under, over uint8) uint8{
+	BlendNormal: func(under, over uint8) uint8 {
+		return over
+	},
+	BlendMultiply: func(under, over uint8) uint8 {
+		return uint8((int(under)*int(over) + 127) / 255)
+	},
+	BlendScreen: func(under, over uint8) uint8 {
+		return 255 - uint8(((255-int(under))*(255-int(over))+127)/255)
+	},
+	BlendLighten: func(under, over uint8) uint8 {
+		if over > under {
+			return over
+		}
+		return under
+	},
+	BlendDarken: func(under, over uint8) uint8 {
+		if over < under {
+			return over
+		}
+		return under
+	},
+	BlendDifference: func(under, over uint8) uint8 {
+		if over > under {
+			return over - under
+		}
+		return under - over
+	},
+}
+
+// Composite returns a new ANSImage with other drawn over ai, its top-left
+// ANSI-pixel at (y,x), and combined with the pixels below by mode, for
+// picture-in-picture, logos or split screens. Parts falling outside of ai are
+// clipped. Both must use the same dithering mode; in dithering mode the
+// cells of other keep their shape (dots, characters) and blend their colors.
+//
+// The animations play together: the result lasts as long as the longer one,
+// the shorter one looping meanwhile, and has a frame wherever either of them
+// changes. Animations without delays (like still images) are paired frame by
+// frame instead. The loop count is the one of ai.
+func (ai *ANSImage) Composite(other *ANSImage, y, x int, mode BlendMode) (*ANSImage, error) {
+	if other.dithering != ai.dithering {
+		return nil, ErrLayerMismatch
+	}
+	blend, ok := blendFuncs[mode]
+	if !ok {
+		return nil, errUnknownBlendMode
+	}
+	if y < 0 || x < 0 || y+other.h > ai.h || x+other.w > ai.w {
+		logf("Composite: image clipped")
+	}
+
+	pairs, delays := compositeTimeline(ai.delay, other.delay)
+	out, err := New(ai.h, ai.w, len(pairs), ai.background(), ai.dithering)
+	if err != nil {
+		return nil, err
+	}
+	out.maxprocs = ai.maxprocs
+	out.blockY, out.blockX = ai.blockY, ai.blockX
+	out.charRamp = ai.charRamp
+	out.loopCount = ai.loopCount
+	out.renderHook = ai.renderHook
+	for i, pair := range pairs {
+		copyFrame(out.frame[i], ai.frame[pair[0]])
+		out.delay[i] = delays[i]
+		compositeFrame(out.frame[i], other.frame[pair[1]], y, x, blend)
+	}
+	return out, nil
+}
+
+// compositeFrame blends the pixels of src onto dst, the top-left one at (y,x).
+func compositeFrame(dst, src ANSIframe, y, x int, blend func(under, over uint8) uint8) {
+	for sy, row := range src {
+		dy := y + sy
+		if dy < 0 || dy >= len(dst) {
+			continue
+		}
+		for sx, s := range row {
+			dx := x + sx
+			if dx < 0 || dx >= len(dst[dy]) {
+				continue
+			}
+			if s.transparent {
+				continue // dst shows through
+			}
+			d := dst[dy][dx]
+			d.R, d.G, d.B = blend(d.R, s.R), blend(d.G, s.G), blend(d.B, s.B)
+			d.bgR, d.bgG, d.bgB = blend(d.bgR, s.bgR), blend(d.bgG, s.bgG), blend(d.bgB, s.bgB)
+			d.Brightness = blend(d.Brightness, s.Brightness)
+			d.dots = s.dots
+			d.text = s.text
+			d.transparent, d.clearBg = false, s.clearBg
+		}
+	}
+}
+
+// compositeTimeline returns the frames shown when two animations with the
+// delays a and b play together, as pairs of their frame indexes, and the
+// delays of those pairs (see Composite).
+func compositeTimeline(a, b []int) ([][2]int, []int) {
+	totalA, totalB := 0, 0
+	for _, delay := range a {
+		totalA += delay
+	}
+	for _, delay := range b {
+		totalB += delay
+	}
+
+	var pairs [][2]int
+	var delays []int
+	if totalA == 0 || totalB == 0 {
+		n := len(a)
+		if len(b) > n {
+			n = len(b)
+		}
+		for i := 0; i < n; i++ {
+			pairs = append(pairs, [2]int{i % len(a), i % len(b)})
+			delays = append(delays, a[i%len(a)]+b[i%len(b)]) // one of them is 0
+		}
+		return pairs, delays
+	}
+
+	total := totalA
+	if totalB > total {
+		total = totalB
+	}
+	fa, fb := 0, 0           // frames shown
+	endA, endB := a[0], b[0] // until
+	for now := 0; now < total; {
+		next := endA
+		if endB < next {
+			next = endB
+		}
+		if total < next {
+			next = total
+		}
+		if next > now {
+			pairs = append(pairs, [2]int{fa, fb})
+			delays = append(delays, next-now)
+		}
+		if endA == next {
+			fa = (fa + 1) % len(a)
+			endA += a[fa]
+		}
+		if endB == next {
+			fb = (fb + 1) % len(b)
+			endB += b[fb]
+		}
+		now = next
+	}
+	return pairs, delays
+}
@@ -0,0 +1,48 @@
+package ansimage
+
+import "image"
+
+// Crop returns a new ANSImage made of the region rect (in ANSI-pixels, see
+// Height and Width) of every frame of ai, for zoomed views or for stripping
+// letterbox bars without loading the image again. rect is clipped to ai;
+// without dithering its top and bottom are widened to even rows, as the
+// height must be even. Wide characters of overlay text cut in half by the
+// region become spaces.
+func (ai *ANSImage) Crop(rect image.Rectangle) (*ANSImage, error) {
+	rect = rect.Intersect(image.Rect(0, 0, ai.w, ai.h))
+	if ai.dithering == NoDithering {
+		rect.Min.Y -= rect.Min.Y % 2
+		rect.Max.Y += rect.Max.Y % 2
+	}
+	if rect.Empty() {
+		return nil, ErrOutOfBounds
+	}
+
+	out, err := New(rect.Dy(), rect.Dx(), len(ai.frame), ai.background(), ai.dithering)
+	if err != nil {
+		return nil, err
+	}
+	out.maxprocs = ai.maxprocs
+	out.blockY, out.blockX = ai.blockY, ai.blockX
+	out.charRamp = ai.charRamp
+	out.loopCount = ai.loopCount
+	out.renderHook = ai.renderHook
+	copy(out.delay, ai.delay)
+	for i, frame := range ai.frame {
+		region := make(ANSIframe, rect.Dy())
+		for y := range region {
+			region[y] = frame[rect.Min.Y+y][rect.Min.X:rect.Max.X]
+		}
+		copyFrame(out.frame[i], region)
+
+		for y, row := range out.frame[i] {
+			if row[0].text == wideTail {
+				row[0].text = " "
+			}
+			if rect.Max.X < ai.w && frame[rect.Min.Y+y][rect.Max.X].text == wideTail {
+				row[len(row)-1].text = " "
+			}
+		}
+	}
+	return out, nil
+}
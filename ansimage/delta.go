@@ -0,0 +1,94 @@
+package ansimage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// samePixel reports whether two ANSI-pixels render the same.
+func samePixel(a, b *ANSIpixel) bool {
+	return a.R == b.R && a.G == b.G && a.B == b.B && a.Brightness == b.Brightness && a.dots == b.dots &&
+		a.bgR == b.bgR && a.bgG == b.bgG && a.bgB == b.bgB && a.text == b.text &&
+		a.transparent == b.transparent && a.clearBg == b.clearBg
+}
+
+// RenderDeltaTo writes frame to w as an update of prev, which must already be
+// on screen with its top-left corner at the cursor home position: only the
+// cells that differ are redrawn, each run of them after a cursor positioning
+// sequence. The whole frame is written instead (from the home position) when
+// prev is negative or most cells changed. Either way the cursor is left on
+// the row below the image, like after RenderTo. Cells turning transparent
+// are erased to the default background, as skipping them would leave prev.
+func (ai *ANSImage) RenderDeltaTo(frame, prev int, w io.Writer, disableBgColor bool, cf ColorFunc) error {
+	tr := TrueColorRenderer{DisableBgColor: disableBgColor, ColorFunc: cf}
+	hr := HalfBlockRenderer{ColorFunc: cf}
+	changed := func(pixelRows []int, x int) bool {
+		for _, y := range pixelRows {
+			if !samePixel(ai.frame[frame][y][x], ai.frame[prev][y][x]) {
+				return true
+			}
+		}
+		return false
+	}
+	transparent := func(pixelRows []int, x int) bool {
+		for _, y := range pixelRows {
+			if !ai.frame[frame][y][x].transparent {
+				return false
+			}
+		}
+		return true
+	}
+
+	if prev >= 0 {
+		count := 0
+		for row := 0; row < ai.Rows(); row++ {
+			for x := 0; x < ai.w; x++ {
+				if changed(ai.PixelRows(row), x) {
+					count++
+				}
+			}
+		}
+		if 2*count > ai.Rows()*ai.w {
+			prev = -1
+		}
+	}
+
+	if prev < 0 {
+		if _, err := io.WriteString(w, "\033[H"); err != nil {
+			return err
+		}
+		return ai.RenderWith(frame, w, tr)
+	}
+
+	start := time.Now()
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for row := 0; row < ai.Rows(); row++ {
+		pixelRows := ai.PixelRows(row)
+		inRun := false
+		for x := 0; x < ai.w; x++ {
+			if !changed(pixelRows, x) {
+				inRun = false
+				continue
+			}
+			if !inRun {
+				fmt.Fprintf(bw, "\033[%d;%dH", row+1, x+1)
+				inRun = true
+			}
+			switch {
+			case transparent(pixelRows, x):
+				bw.WriteString(defaultBackground + " ")
+			case ai.dithering == NoDithering:
+				bw.WriteString(hr.RenderCell(ai.frame[frame][pixelRows[0]][x], ai.frame[frame][pixelRows[1]][x]))
+			default:
+				bw.WriteString(tr.RenderPixel(ai.frame[frame][pixelRows[0]][x]))
+			}
+		}
+	}
+	fmt.Fprintf(bw, "\033[0m\033[%d;1H", ai.Rows()+1) // reset ansi style
+	err := bw.Flush()
+	ai.observe(frame, cw.n, start)
+	return err
+}
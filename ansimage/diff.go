@@ -0,0 +1,61 @@
+package ansimage
+
+// diffDim divides the brightness of the unchanged ANSI-pixels of a Diff.
+const diffDim = 3
+
+// Diff returns a new ANSImage showing what changed from ai to other, for
+// comparing edits of the same animation: the ANSI-pixels of other that differ
+// from ai by more than tolerance (per color channel) are shown as they are,
+// and the others in dim gray. The animations play together like with
+// Composite. Both must have the same size and dithering mode.
+func (ai *ANSImage) Diff(other *ANSImage, tolerance uint8) (*ANSImage, error) {
+	if other.h != ai.h || other.w != ai.w || other.dithering != ai.dithering {
+		return nil, ErrSizeMismatch
+	}
+
+	pairs, delays := compositeTimeline(ai.delay, other.delay)
+	out, err := New(ai.h, ai.w, len(pairs), ai.background(), ai.dithering)
+	if err != nil {
+		return nil, err
+	}
+	out.maxprocs = ai.maxprocs
+	out.blockY, out.blockX = ai.blockY, ai.blockX
+	out.charRamp = ai.charRamp
+	out.loopCount = other.loopCount
+	out.renderHook = ai.renderHook
+	for i, pair := range pairs {
+		copyFrame(out.frame[i], other.frame[pair[1]])
+		out.delay[i] = delays[i]
+		for y, row := range out.frame[i] {
+			for x, p := range row {
+				if !changedPixel(ai.frame[pair[0]][y][x], p, tolerance) {
+					p.R, p.G, p.B = dimGray(p.R, p.G, p.B)
+					p.bgR, p.bgG, p.bgB = dimGray(p.bgR, p.bgG, p.bgB)
+					p.Brightness /= diffDim
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// changedPixel reports whether b differs from a by more than tolerance in a
+// color channel, or in its cell shape (dots, overlay text).
+func changedPixel(a, b *ANSIpixel, tolerance uint8) bool {
+	differs := func(u, v uint8) bool {
+		if u > v {
+			return u-v > tolerance
+		}
+		return v-u > tolerance
+	}
+	return differs(a.R, b.R) || differs(a.G, b.G) || differs(a.B, b.B) ||
+		differs(a.bgR, b.bgR) || differs(a.bgG, b.bgG) || differs(a.bgB, b.bgB) ||
+		a.dots != b.dots || a.text != b.text
+}
+
+// dimGray returns the color r, g, b as a gray (see Grayscale) dimmed by diffDim.
+func dimGray(r, g, b uint8) (uint8, uint8, uint8) {
+	y, _, _ := Grayscale(r, g, b)
+	y /= diffDim
+	return y, y, y
+}
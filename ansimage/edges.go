@@ -0,0 +1,126 @@
+package ansimage
+
+import (
+	"image"
+	"io"
+	"math"
+)
+
+// edgeThreshold is the mean Sobel gradient magnitude (brightness from 0 to 1
+// per pixel) above which a cell draws an edge rather than a shade block.
+const edgeThreshold = 0.12
+
+// edgeChars are the characters drawing the edges of cells dithered with
+// edges, by the dots of the cell: none (shade block), then edges running
+// vertically, rising, horizontally and falling.
+var edgeChars = [5]string{"", "|", "/", "-", "\\"}
+
+// edgeField holds the Sobel gradients of the brightness of a frame.
+type edgeField struct {
+	w      int
+	gx, gy []float64
+}
+
+// newEdgeField runs a Sobel filter over the brightness (Rec. 709 luminance)
+// of img, clamped at the borders.
+func newEdgeField(img *image.RGBA) *edgeField {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	lum := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := img.RGBAAt(b.Min.X+x, b.Min.Y+y)
+			l, _, _ := Grayscale(p.R, p.G, p.B)
+			lum[y*w+x] = float64(l) / 255
+		}
+	}
+	at := func(y, x int) float64 {
+		if y < 0 {
+			y = 0
+		} else if y >= h {
+			y = h - 1
+		}
+		if x < 0 {
+			x = 0
+		} else if x >= w {
+			x = w - 1
+		}
+		return lum[y*w+x]
+	}
+
+	f := &edgeField{w: w, gx: make([]float64, w*h), gy: make([]float64, w*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			f.gx[y*w+x] = (at(y-1, x+1) + 2*at(y, x+1) + at(y+1, x+1) -
+				at(y-1, x-1) - 2*at(y, x-1) - at(y+1, x-1)) / 4
+			f.gy[y*w+x] = (at(y+1, x-1) + 2*at(y+1, x) + at(y+1, x+1) -
+				at(y-1, x-1) - 2*at(y-1, x) - at(y-1, x+1)) / 4
+		}
+	}
+	return f
+}
+
+// cell returns the dots of the cell of blockY x blockX pixels with its
+// top-left pixel at py, px: the index in edgeChars of its edge, or 0 if the
+// gradients are too weak. The direction is the dominant orientation of the
+// gradients (from their structure tensor), so the opposite gradients on both
+// sides of a thin line add up rather than cancel out.
+func (f *edgeField) cell(py, px, blockY, blockX int) uint8 {
+	var sxx, syy, sxy, magnitude float64
+	for y := py; y < py+blockY; y++ {
+		for x := px; x < px+blockX; x++ {
+			gx, gy := f.gx[y*f.w+x], f.gy[y*f.w+x]
+			sxx += gx * gx
+			syy += gy * gy
+			sxy += gx * gy
+			magnitude += math.Hypot(gx, gy)
+		}
+	}
+	if magnitude/float64(blockY*blockX) < edgeThreshold {
+		return 0
+	}
+
+	// angle of the gradients, from 0 to 180 degrees (y pointing down); the
+	// edge runs across them
+	angle := 0.5 * math.Atan2(2*sxy, sxx-syy) * 180 / math.Pi
+	if angle < 0 {
+		angle += 180
+	}
+	return uint8(int(angle+22.5)/45%4) + 1
+}
+
+// EdgeRenderer renders ANSImages dithered with edges: cells on an edge draw
+// it with | - / or \ by its direction, the others a shade block by brightness.
+type EdgeRenderer struct {
+	// DisableBgColor leaves the background color unset.
+	DisableBgColor bool
+
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+
+	// ColorMode selects the color sequences, true color by default.
+	ColorMode ColorMode
+}
+
+// RenderPixel returns the color sequences and edge or block of ap.
+func (er EdgeRenderer) RenderPixel(ap *ANSIpixel) string {
+	return ditheredCell(ap, edgeGlyph(ap), er.DisableBgColor, er.ColorFunc, er.ColorMode)
+}
+
+// edgeGlyph returns the edge character of ap, or its shade block if it has no edge.
+func edgeGlyph(ap *ANSIpixel) string {
+	if ap.dots > 0 && int(ap.dots) < len(edgeChars) {
+		return edgeChars[ap.dots]
+	}
+	return shadeBlock(ap.Brightness)
+}
+
+// RenderRow writes the ANSI-pixels of a terminal row, then resets the style.
+func (er EdgeRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	return renderRow(w, ai, frame, row, er.RenderPixel)
+}
+
+// RenderFrame writes all the terminal rows of frame, through a buffer.
+func (er EdgeRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	return renderFrame(w, ai, frame, er)
+}
@@ -0,0 +1,82 @@
+package ansimage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// SaveFrames writes every rendered frame of ANSImage to its own text file in dir,
+// named by formatting the frame index with pattern (e.g. "frame%03d.txt"),
+// along with a ManifestName file so LoadTextAnimation can reassemble them.
+// The directory is created if needed.
+func (ai *ANSImage) SaveFrames(dir, pattern string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var manifest bytes.Buffer
+	for frame := range ai.frame {
+		name := fmt.Sprintf(pattern, frame)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(ai.RenderExt(frame, false)), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(&manifest, "%s %d\n", name, ai.delay[frame])
+	}
+	return ioutil.WriteFile(filepath.Join(dir, ManifestName), manifest.Bytes(), 0644)
+}
+
+// SaveANS writes every frame of ANSImage to its own ANSI art file in dir,
+// named frame000.ans, frame001.ans..., as returned by FrameBytes, to archive
+// the art or post it to BBS-style galleries. The directory is created if needed.
+func (ai *ANSImage) SaveANS(dir string, cp437 bool) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for frame := range ai.frame {
+		data, err := ai.FrameBytes(frame, cp437)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("frame%03d.ans", frame)), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FrameBytes returns frame as the contents of an ANSI art (.ans) file, with
+// lines ending with "\r\n": the frame as rendered for terminals, in UTF-8,
+// or if cp437 is set, in the 16 colors and code page 437 characters ANSI art
+// viewers expect. Characters missing from code page 437 become "?", so
+// Braille, quadrants and sextants are better saved in UTF-8.
+func (ai *ANSImage) FrameBytes(frame int, cp437 bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if !cp437 {
+		if err := ai.RenderWith(frame, &buf, TrueColorRenderer{}); err != nil {
+			return nil, err
+		}
+		return bytes.Replace(buf.Bytes(), []byte("\n"), []byte("\r\n"), -1), nil
+	}
+
+	r := TrueColorRenderer{ColorMode: Color16, ColorDithering: FloydSteinberg}
+	if err := ai.RenderWith(frame, &buf, r); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, buf.Len())
+	for _, c := range buf.String() {
+		if c == '\n' {
+			out = append(out, '\r')
+		}
+		b, ok := charmap.CodePage437.EncodeRune(c)
+		if !ok {
+			b = '?'
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
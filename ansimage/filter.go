@@ -0,0 +1,108 @@
+package ansimage
+
+import (
+	"math"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// PixelFilter maps the color of an ANSI-pixel of frame to its new color. Unlike
+// a ColorFunc, which adjusts colors as they are rendered, it changes the
+// ANSImage (see ANSImage.ApplyFilter), and may vary from frame to frame.
+type PixelFilter func(frame int, r, g, b uint8) (uint8, uint8, uint8)
+
+// ColorFilter returns a PixelFilter applying cf to every frame, so the color
+// funcs (Invert, Sepia, Saturation, HueShift, Grayscale...) can change
+// ANSImages as well as adjust them at render time.
+func ColorFilter(cf ColorFunc) PixelFilter {
+	return func(frame int, r, g, b uint8) (uint8, uint8, uint8) {
+		return cf(r, g, b)
+	}
+}
+
+// ChainFilters returns a PixelFilter applying filters in order.
+func ChainFilters(filters ...PixelFilter) PixelFilter {
+	return func(frame int, r, g, b uint8) (uint8, uint8, uint8) {
+		for _, f := range filters {
+			r, g, b = f(frame, r, g, b)
+		}
+		return r, g, b
+	}
+}
+
+// ApplyFilter passes the colors (and, in dithering mode, the backgrounds) of
+// every ANSI-pixel of every frame of ai through f. The brightness of dithered
+// cells follows their new color, so shade blocks and characters match it.
+func (ai *ANSImage) ApplyFilter(f PixelFilter) {
+	for i, frame := range ai.frame {
+		for _, row := range frame {
+			for _, p := range row {
+				before := maxChannel(p.R, p.G, p.B)
+				p.R, p.G, p.B = f(i, p.R, p.G, p.B)
+				if ai.dithering == NoDithering {
+					continue
+				}
+				p.bgR, p.bgG, p.bgB = f(i, p.bgR, p.bgG, p.bgB)
+				after := maxChannel(p.R, p.G, p.B)
+				if before == 0 {
+					p.Brightness = after
+				} else {
+					p.Brightness = uint8(math.Min(255, float64(p.Brightness)*float64(after)/float64(before)+0.5))
+				}
+			}
+		}
+	}
+}
+
+// maxChannel returns the largest of r, g, b: the value of the color in HSV.
+func maxChannel(r, g, b uint8) uint8 {
+	if g > r {
+		r = g
+	}
+	if b > r {
+		r = b
+	}
+	return r
+}
+
+// Invert is a ColorFunc replacing every color with its negative.
+func Invert(r, g, b uint8) (uint8, uint8, uint8) {
+	return 255 - r, 255 - g, 255 - b
+}
+
+// Sepia is a ColorFunc toning every color in brown, like an old photograph.
+func Sepia(r, g, b uint8) (uint8, uint8, uint8) {
+	fr, fg, fb := float64(r), float64(g), float64(b)
+	return clampChannel(0.393*fr + 0.769*fg + 0.189*fb),
+		clampChannel(0.349*fr + 0.686*fg + 0.168*fb),
+		clampChannel(0.272*fr + 0.534*fg + 0.131*fb)
+}
+
+// Saturation returns a ColorFunc scaling the saturation of every color by
+// factor, away from its gray (see Grayscale): 0 makes it gray, 1 keeps it,
+// above 1 makes it more vivid.
+func Saturation(factor float64) ColorFunc {
+	return func(r, g, b uint8) (uint8, uint8, uint8) {
+		y, _, _ := Grayscale(r, g, b)
+		scale := func(c uint8) uint8 {
+			return clampChannel(float64(y) + (float64(c)-float64(y))*factor)
+		}
+		return scale(r), scale(g), scale(b)
+	}
+}
+
+// HueShift returns a ColorFunc rotating the hue of every color by degrees
+// around the color wheel (120 turns red into green), keeping its
+// saturation and value.
+func HueShift(degrees float64) ColorFunc {
+	return func(r, g, b uint8) (uint8, uint8, uint8) {
+		h, s, v := colorful.Color{R: float64(r) / 255, G: float64(g) / 255, B: float64(b) / 255}.Hsv()
+		c := colorful.Hsv(math.Mod(math.Mod(h+degrees, 360)+360, 360), s, v)
+		return clampChannel(c.R * 255), clampChannel(c.G * 255), clampChannel(c.B * 255)
+	}
+}
+
+// clampChannel rounds v to a color channel, from 0 to 255.
+func clampChannel(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, v+0.5)))
+}
@@ -0,0 +1,70 @@
+package ansimage
+
+import (
+	"bytes"
+	"time"
+)
+
+// FrameIterator steps through the rendered frames of an ANSImage, like a
+// bufio.Scanner: call Next until it returns false, then check Err.
+//
+//	it := ai.Frames()
+//	for it.Next() {
+//		os.Stdout.Write(it.Bytes())
+//		time.Sleep(it.Delay())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type FrameIterator struct {
+	ai    *ANSImage
+	r     Renderer
+	frame int
+	buf   bytes.Buffer
+	err   error
+}
+
+// Frames returns an iterator over the frames of ai rendered in 24-bit color
+// text, from the first to the last one.
+func (ai *ANSImage) Frames() *FrameIterator {
+	return ai.FramesWith(TrueColorRenderer{})
+}
+
+// FramesWith returns an iterator over the frames of ai rendered with r.
+func (ai *ANSImage) FramesWith(r Renderer) *FrameIterator {
+	return &FrameIterator{ai: ai, r: r, frame: -1}
+}
+
+// Next renders the next frame, reporting false after the last one or on error.
+func (it *FrameIterator) Next() bool {
+	if it.err != nil || it.frame+1 >= len(it.ai.frame) {
+		return false
+	}
+	it.frame++
+	it.buf.Reset()
+	if it.err = it.ai.RenderWith(it.frame, &it.buf, it.r); it.err != nil {
+		return false
+	}
+	return true
+}
+
+// Index returns the index of the current frame.
+func (it *FrameIterator) Index() int {
+	return it.frame
+}
+
+// Bytes returns the current frame as rendered. The slice is only valid until
+// the next call of Next.
+func (it *FrameIterator) Bytes() []byte {
+	return it.buf.Bytes()
+}
+
+// Delay returns how long the current frame is shown.
+func (it *FrameIterator) Delay() time.Duration {
+	return time.Duration(it.ai.delay[it.frame]) * 10 * time.Millisecond
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *FrameIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,69 @@
+package ansimage
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+)
+
+// GIFScale is the size in pixels of an ANSI-pixel in the GIFs written by
+// EncodeGIF (see Rasterize).
+const GIFScale = 4
+
+// EncodeGIF writes ANSImage to w as an animated GIF, every frame rasterized
+// as it looks in a terminal (see Rasterize), with the delays and loop count
+// of the ANSImage. Frames use their exact colors when they all fit in a
+// single 256 colors palette, and are dithered to the Plan 9 palette otherwise.
+func (ai *ANSImage) EncodeGIF(w io.Writer) error {
+	frames := make([]*image.RGBA, len(ai.frame))
+	for i := range ai.frame {
+		frames[i] = ai.Rasterize(i, GIFScale)
+	}
+
+	pal, exact := framesPalette(frames)
+	if !exact {
+		pal = palette.Plan9
+	}
+	g := &gif.GIF{
+		Image:     make([]*image.Paletted, len(frames)),
+		Delay:     append([]int(nil), ai.delay...),
+		LoopCount: ai.loopCount,
+	}
+	for i, frame := range frames {
+		paletted := image.NewPaletted(frame.Bounds(), pal)
+		if exact {
+			draw.Draw(paletted, frame.Bounds(), frame, image.ZP, draw.Src)
+		} else {
+			draw.FloydSteinberg.Draw(paletted, frame.Bounds(), frame, image.ZP)
+		}
+		g.Image[i] = paletted
+	}
+	return gif.EncodeAll(w, g)
+}
+
+// framesPalette returns the colors of frames, reporting false if there are
+// more than 256 of them.
+func framesPalette(frames []*image.RGBA) (color.Palette, bool) {
+	seen := make(map[color.RGBA]bool)
+	var pal color.Palette
+	for _, frame := range frames {
+		for i := 0; i+3 < len(frame.Pix); i += 4 {
+			c := color.RGBA{frame.Pix[i], frame.Pix[i+1], frame.Pix[i+2], frame.Pix[i+3]}
+			if seen[c] {
+				continue
+			}
+			if len(pal) == 256 {
+				return nil, false
+			}
+			seen[c] = true
+			pal = append(pal, c)
+		}
+	}
+	if len(pal) == 0 {
+		pal = color.Palette{color.Black}
+	}
+	return pal, true
+}
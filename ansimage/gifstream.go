@@ -0,0 +1,194 @@
+package ansimage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"io"
+)
+
+// GIF block introducers and the label of graphic control extensions.
+const (
+	gifExtension      = 0x21
+	gifImageSeparator = 0x2c
+	gifTrailer        = 0x3b
+	gifGraphicControl = 0xf9
+)
+
+// maxGIFExtensions is the total size of the extension blocks (comments,
+// application data...) a GIFStream reads, as they aren't bounded by the canvas.
+const maxGIFExtensions = 1 << 20
+
+var (
+	// errNotGIF occurs when a GIFStream doesn't start with a GIF header.
+	errNotGIF = errors.New("ANSImage: not a GIF")
+
+	// errGIFBlock occurs when a GIFStream reads a block that isn't an
+	// extension, a frame or the trailer.
+	errGIFBlock = errors.New("ANSImage: unknown GIF block")
+
+	// errGIFExtensions occurs when the extension blocks of a GIFStream go
+	// over maxGIFExtensions.
+	errGIFExtensions = errors.New("ANSImage: GIF extensions too large")
+)
+
+// GIFStream decodes a GIF frame by frame as it is read, for GIFs still
+// arriving (uploads, pipes): unlike gif.DecodeAll, which returns once the
+// whole file is read, every frame is available as soon as its data is.
+type GIFStream struct {
+	r          *bufio.Reader
+	header     []byte // header, logical screen descriptor and global color table
+	bounds     image.Rectangle
+	canvas     *image.RGBA // allocated by the first Next, once Bounds can be checked
+	dispose    func()      // disposes of the last frame returned
+	extensions int         // what is left of maxGIFExtensions
+}
+
+// NewGIFStream reads the header of the GIF in r, up to its first frame. The
+// canvas isn't allocated yet, so callers can check Bounds against their limits
+// before calling Next.
+func NewGIFStream(r io.Reader) (*GIFStream, error) {
+	s := &GIFStream{r: bufio.NewReader(r), header: make([]byte, 13), extensions: maxGIFExtensions}
+	if _, err := io.ReadFull(s.r, s.header); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(s.header, []byte("GIF87a")) && !bytes.HasPrefix(s.header, []byte("GIF89a")) {
+		return nil, errNotGIF
+	}
+	if flags := s.header[10]; flags&0x80 != 0 {
+		colors := make([]byte, 3<<(flags&0x07+1))
+		if _, err := io.ReadFull(s.r, colors); err != nil {
+			return nil, err
+		}
+		s.header = append(s.header, colors...)
+	}
+
+	w := int(s.header[6]) | int(s.header[7])<<8
+	h := int(s.header[8]) | int(s.header[9])<<8
+	s.bounds = image.Rect(0, 0, w, h)
+	return s, nil
+}
+
+// Bounds returns the bounds of the GIF canvas, known before any frame is read.
+func (s *GIFStream) Bounds() image.Rectangle {
+	return s.bounds
+}
+
+// Next reads the next frame and returns the GIF canvas with it drawn over the
+// previous ones, as left by their disposal methods, and its delay in 100ths
+// of a second. The canvas is reused by the next call. Next returns io.EOF after the last frame.
+func (s *GIFStream) Next() (*image.RGBA, int, error) {
+	var control []byte // graphic control extension of the frame
+	for {
+		introducer, err := s.r.ReadByte()
+		if err == io.EOF {
+			return nil, 0, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, 0, err
+		}
+
+		switch introducer {
+		case gifTrailer:
+			return nil, 0, io.EOF
+
+		case gifExtension:
+			label, err := s.r.ReadByte()
+			if err != nil {
+				return nil, 0, noEOF(err)
+			}
+			block, err := s.readSubBlocks([]byte{gifExtension, label}, s.extensions)
+			if err != nil {
+				return nil, 0, err
+			}
+			s.extensions -= len(block)
+			if label == gifGraphicControl {
+				control = block
+			}
+
+		case gifImageSeparator:
+			frame, err := s.readImage(control)
+			if err != nil {
+				return nil, 0, err
+			}
+			if s.canvas == nil {
+				s.canvas = image.NewRGBA(s.bounds)
+			}
+			if s.dispose != nil {
+				s.dispose()
+			}
+			s.dispose = drawGIFFrame(s.canvas, frame.Image[0], gifDisposal(frame, 0))
+			return s.canvas, frame.Delay[0], nil
+
+		default:
+			return nil, 0, errGIFBlock
+		}
+	}
+}
+
+// readImage reads the image descriptor, local color table and data of a frame,
+// and decodes them with control as a GIF of its own.
+func (s *GIFStream) readImage(control []byte) (*gif.GIF, error) {
+	desc := make([]byte, 10)
+	desc[0] = gifImageSeparator
+	if _, err := io.ReadFull(s.r, desc[1:]); err != nil {
+		return nil, noEOF(err)
+	}
+	if flags := desc[9]; flags&0x80 != 0 {
+		colors := make([]byte, 3<<(flags&0x07+1))
+		if _, err := io.ReadFull(s.r, colors); err != nil {
+			return nil, noEOF(err)
+		}
+		desc = append(desc, colors...)
+	}
+	codeSize, err := s.r.ReadByte()
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	desc = append(desc, codeSize)
+	block, err := s.readSubBlocks(desc, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(s.header)
+	buf.Write(control)
+	buf.Write(block)
+	buf.WriteByte(gifTrailer)
+	return gif.DecodeAll(&buf)
+}
+
+// readSubBlocks appends the data sub-blocks read up to their terminator to
+// block, with their sizes and the terminator. Blocks longer than max, unless
+// it is negative, are refused with errGIFExtensions.
+func (s *GIFStream) readSubBlocks(block []byte, max int) ([]byte, error) {
+	for {
+		if max >= 0 && len(block) > max {
+			return nil, errGIFExtensions
+		}
+		size, err := s.r.ReadByte()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		block = append(block, size)
+		if size == 0 {
+			return block, nil
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(s.r, data); err != nil {
+			return nil, noEOF(err)
+		}
+		block = append(block, data...)
+	}
+}
+
+// noEOF returns io.ErrUnexpectedEOF for io.EOF, as a GIF may only end after
+// its trailer.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
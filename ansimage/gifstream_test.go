@@ -0,0 +1,144 @@
+package ansimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"testing"
+)
+
+var (
+	testRed  = color.RGBA{0xff, 0, 0, 0xff}
+	testBlue = color.RGBA{0, 0, 0xff, 0xff}
+
+	testPalette = color.Palette{color.RGBA{}, testRed, testBlue}
+)
+
+// testGIF encodes a 4x4 GIF of a red frame, disposed of with disposal, then
+// a blue pixel at (3,3).
+func testGIF(t *testing.T, disposal byte) []byte {
+	red := image.NewPaletted(image.Rect(0, 0, 4, 4), testPalette)
+	for i := range red.Pix {
+		red.Pix[i] = 1
+	}
+	blue := image.NewPaletted(image.Rect(3, 3, 4, 4), testPalette)
+	blue.Pix[0] = 2
+
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
+		Image:    []*image.Paletted{red, blue},
+		Delay:    []int{5, 7},
+		Disposal: []byte{disposal, gif.DisposalNone},
+		Config:   image.Config{ColorModel: testPalette, Width: 4, Height: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGIFStreamNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		disposal byte
+		corner   color.RGBA // pixel (0,0) of the second frame
+	}{
+		{"none", gif.DisposalNone, testRed},
+		{"background", gif.DisposalBackground, color.RGBA{}},
+		{"previous", gif.DisposalPrevious, color.RGBA{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewGIFStream(bytes.NewReader(testGIF(t, tt.disposal)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := s.Bounds(); b != image.Rect(0, 0, 4, 4) {
+				t.Fatalf("Bounds() = %v", b)
+			}
+
+			canvas, delay, err := s.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if delay != 5 || canvas.RGBAAt(0, 0) != testRed || canvas.RGBAAt(3, 3) != testRed {
+				t.Errorf("first frame: delay %d, pixels %v %v", delay, canvas.RGBAAt(0, 0), canvas.RGBAAt(3, 3))
+			}
+
+			canvas, delay, err = s.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if delay != 7 || canvas.RGBAAt(0, 0) != tt.corner || canvas.RGBAAt(3, 3) != testBlue {
+				t.Errorf("second frame: delay %d, pixels %v %v", delay, canvas.RGBAAt(0, 0), canvas.RGBAAt(3, 3))
+			}
+
+			if _, _, err := s.Next(); err != io.EOF {
+				t.Errorf("Next() after the last frame = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestGIFStreamErrors(t *testing.T) {
+	data := testGIF(t, gif.DisposalNone)
+	unknown := append(append([]byte{}, data[:len(data)-1]...), 0x42)
+
+	// comment extensions of 255 bytes before the first frame, up to 2 MB
+	comments := append([]byte{}, data[:13+12]...)
+	for len(comments) < 2<<20 {
+		comments = append(comments, gifExtension, 0xfe)
+		for i := 0; i < 16; i++ {
+			comments = append(comments, 255)
+			comments = append(comments, bytes.Repeat([]byte{'x'}, 255)...)
+		}
+		comments = append(comments, 0)
+	}
+	comments = append(comments, data[13+12:]...)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error // of NewGIFStream, else of the last Next
+	}{
+		{"empty", nil, io.EOF},
+		{"short header", data[:8], io.ErrUnexpectedEOF},
+		{"not a GIF", []byte("PNG89a\x04\x00\x04\x00\x00\x00\x00"), errNotGIF},
+		{"truncated color table", data[:20], io.ErrUnexpectedEOF},
+		{"truncated frame", data[:len(data)/2], io.ErrUnexpectedEOF},
+		{"no trailer", data[:len(data)-1], io.ErrUnexpectedEOF},
+		{"unknown block", unknown, errGIFBlock},
+		{"huge extensions", comments, errGIFExtensions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewGIFStream(bytes.NewReader(tt.data))
+			for err == nil {
+				_, _, err = s.Next()
+			}
+			if err != tt.err {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestGIFStreamHugeCanvas(t *testing.T) {
+	// 65535x65535 canvas without a color table, then the trailer
+	data := []byte("GIF89a\xff\xff\xff\xff\x00\x00\x00\x3b")
+	s, err := NewGIFStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := s.Bounds(); b != image.Rect(0, 0, 65535, 65535) {
+		t.Errorf("Bounds() = %v", b)
+	}
+	if _, _, err := s.Next(); err != io.EOF {
+		t.Errorf("Next() = %v, want io.EOF", err)
+	}
+	if s.canvas != nil {
+		t.Error("canvas allocated without a frame")
+	}
+}
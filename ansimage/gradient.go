@@ -0,0 +1,57 @@
+package ansimage
+
+import (
+	"image"
+	"image/color"
+)
+
+// Gradient is a background varying linearly from From to To, top to bottom,
+// or left to right when Horizontal is set. It can be passed wherever a
+// background color is expected: transparent areas, letterbox bars and
+// dithering cell backgrounds are then filled with the gradient instead
+// of a single color. Used as a plain color.Color, it is From.
+type Gradient struct {
+	From, To   color.Color
+	Horizontal bool
+}
+
+// RGBA implements color.Color with the From color.
+func (g Gradient) RGBA() (r, gr, b, a uint32) {
+	return g.From.RGBA()
+}
+
+// At returns the gradient color at (y, x) of an area h pixels high and w pixels wide.
+func (g Gradient) At(y, x, h, w int) color.RGBA {
+	pos, span := y, h
+	if g.Horizontal {
+		pos, span = x, w
+	}
+	t := 0.0
+	if span > 1 {
+		t = float64(pos) / float64(span-1)
+	}
+
+	fr, fg, fb, fa := g.From.RGBA()
+	tr, tg, tb, ta := g.To.RGBA()
+	lerp := func(from, to uint32) uint8 {
+		return uint8((float64(from)*(1-t)+float64(to)*t)/257 + 0.5)
+	}
+	return color.RGBA{lerp(fr, tr), lerp(fg, tg), lerp(fb, tb), lerp(fa, ta)}
+}
+
+// backgroundImage returns an image filling bounds with bg, which may be a Gradient.
+func backgroundImage(bg color.Color, bounds image.Rectangle) image.Image {
+	g, ok := bg.(Gradient)
+	if !ok {
+		return image.NewUniform(bg)
+	}
+
+	img := image.NewRGBA(bounds)
+	h, w := bounds.Dy(), bounds.Dx()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(bounds.Min.X+x, bounds.Min.Y+y, g.At(y, x, h, w))
+		}
+	}
+	return img
+}
@@ -0,0 +1,9 @@
+package ansimage
+
+// Grayscale is a ColorFunc replacing every color with its luminance (Rec. 709),
+// for monochrome displays, e-ink terminals and log captures. With the Color256
+// color mode, the grays are written with the gray ramp of the xterm palette.
+func Grayscale(r, g, b uint8) (uint8, uint8, uint8) {
+	y := uint8((2126*int(r) + 7152*int(g) + 722*int(b) + 5000) / 10000)
+	return y, y, y
+}
@@ -0,0 +1,135 @@
+package ansimage
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// HTMLRenderer is a Renderer writing frames as HTML, for browser previews
+// drawn from the same ANSI-pixels as the terminal output: a frame is a
+// <pre> element, and every cell a <span> with its colors as inline CSS.
+// Use a font with the block characters, and a line height of 1.
+type HTMLRenderer struct {
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+}
+
+// RenderPixel returns the span of the cell showing ap. A pixel of an ANSImage
+// without dithering fills the whole cell.
+func (hr HTMLRenderer) RenderPixel(ap *ANSIpixel) string {
+	if ap.source.dithering == NoDithering {
+		return hr.cell(ap.glyph(fullBlock), ap.R, ap.G, ap.B, ap.R, ap.G, ap.B)
+	}
+	return hr.cell(ap.glyph(ditheredGlyph(ap)), ap.R, ap.G, ap.B, ap.bgR, ap.bgG, ap.bgB)
+}
+
+// cell returns the span of glyph in color fr, fg, fb over the background color br, bg, bb.
+func (hr HTMLRenderer) cell(glyph string, fr, fg, fb, br, bg, bb uint8) string {
+	fr, fg, fb = applyColorFunc(hr.ColorFunc, fr, fg, fb)
+	br, bg, bb = applyColorFunc(hr.ColorFunc, br, bg, bb)
+	return fmt.Sprintf(`<span style="color:#%02x%02x%02x;background:#%02x%02x%02x">%s</span>`,
+		fr, fg, fb, br, bg, bb, html.EscapeString(glyph))
+}
+
+// RenderRow writes the cells of a terminal row, then a newline.
+func (hr HTMLRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	pixelRows := ai.PixelRows(row)
+	for x := 0; x < ai.w; x++ {
+		var cell string
+		if ai.dithering == NoDithering {
+			upper, lower := ai.frame[frame][pixelRows[0]][x], ai.frame[frame][pixelRows[1]][x]
+			cell = hr.cell(lower.glyph(lowerHalfBlock), lower.R, lower.G, lower.B, upper.R, upper.G, upper.B)
+		} else {
+			cell = hr.RenderPixel(ai.frame[frame][pixelRows[0]][x])
+		}
+		if _, err := io.WriteString(w, cell); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// RenderFrame writes frame as a <pre> element, through a buffer.
+func (hr HTMLRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<pre class="ansimage">`)
+	for row := 0; row < ai.Rows(); row++ {
+		if err := hr.RenderRow(bw, ai, frame, row); err != nil {
+			return err
+		}
+	}
+	bw.WriteString("</pre>\n")
+	return bw.Flush()
+}
+
+// ditheredGlyph returns the character drawing ap in the dithering mode of its ANSImage.
+func ditheredGlyph(ap *ANSIpixel) string {
+	switch ap.source.dithering {
+	case DitheringWithBlocks:
+		return shadeBlock(ap.Brightness)
+	case DitheringWithChars:
+		return rampChar(ap, nil)
+	case DitheringWithBraille:
+		return string(rune(0x2800 + int(ap.dots)))
+	case DitheringWithQuadrants:
+		return quadrantBlocks[ap.dots&0xf]
+	case DitheringWithSextants:
+		return sextantBlock(ap.dots)
+	case DitheringWithEdges:
+		return edgeGlyph(ap)
+	}
+	panic(errUnknownDitheringMode)
+}
+
+// WriteHTMLAnimation writes all the frames of ANSImage as an HTML element
+// playing them with a CSS animation, with the delays and loop count of the
+// ANSImage: the frames (see HTMLRenderer) are stacked in a grid, and each one
+// is only visible during its delay. The last frame stays once the loops are
+// done. Class names are prefixed with prefix, a CSS identifier telling the
+// animations of a page apart.
+func (ai *ANSImage) WriteHTMLAnimation(w io.Writer, prefix string) error {
+	total := 0
+	for _, delay := range ai.delay {
+		total += delay
+	}
+	if total == 0 {
+		total = len(ai.delay) // frames without delays are shown evenly
+	}
+	iterations := "infinite"
+	switch {
+	case ai.loopCount < 0:
+		iterations = "1"
+	case ai.loopCount > 0:
+		iterations = fmt.Sprint(ai.loopCount + 1)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<style>\n.%s { display: grid; }\n.%s pre { grid-area: 1 / 1; margin: 0; line-height: 1; }\n", prefix, prefix)
+	start := 0
+	for i, delay := range ai.delay {
+		if total == len(ai.delay) {
+			delay = 1
+		}
+		from := 100 * float64(start) / float64(total)
+		to := 100 * float64(start+delay) / float64(total)
+		start += delay
+		fmt.Fprintf(bw, "@keyframes %s-%d { 0%% { opacity: 0; } %.3f%% { opacity: 1; } %.3f%% { opacity: 0; } }\n", prefix, i, from, to)
+		fmt.Fprintf(bw, ".%s pre:nth-child(%d) { animation: %s-%d %.2fs step-end %s; }\n", prefix, i+1, prefix, i, float64(total)/100, iterations)
+	}
+	fmt.Fprintf(bw, "</style>\n<div class=\"%s\">\n", prefix)
+
+	var frame strings.Builder
+	for i := range ai.frame {
+		frame.Reset()
+		if err := ai.RenderWith(i, &frame, HTMLRenderer{}); err != nil {
+			return err
+		}
+		bw.WriteString(frame.String())
+	}
+	bw.WriteString("</div>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,54 @@
+package ansimage
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"io"
+)
+
+// DEFAULT_ITERM2_SCALE is the ITerm2Renderer scale used when none is set.
+const DEFAULT_ITERM2_SCALE = 4
+
+// ITerm2Renderer is a Renderer writing frames as iTerm2 inline images
+// (OSC 1337 with a base64 PNG), also understood by WezTerm and mintty.
+// The terminal stretches each image over the cells the text rendering would take.
+type ITerm2Renderer struct {
+	// Scale is the size in pixels of an ANSI-pixel, see ANSImage.Rasterize
+	// (0 for DEFAULT_ITERM2_SCALE).
+	Scale int
+
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+}
+
+// RenderPixel is not supported: pixels are only sent as part of a frame.
+func (ir ITerm2Renderer) RenderPixel(ap *ANSIpixel) string {
+	return ""
+}
+
+// RenderRow is not supported: inline images are sent whole.
+func (ir ITerm2Renderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	return ErrUnsupported
+}
+
+// RenderFrame writes frame as an inline image at the cursor.
+func (ir ITerm2Renderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	scale := ir.Scale
+	if scale <= 0 {
+		scale = DEFAULT_ITERM2_SCALE
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\033]1337;File=inline=1;width=%d;height=%d;preserveAspectRatio=0:", ai.w, ai.Rows())
+	enc := base64.NewEncoder(base64.StdEncoding, bw)
+	if err := png.Encode(enc, ai.rasterize(frame, scale, ir.ColorFunc)); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	bw.WriteString("\a")
+	return bw.Flush()
+}
@@ -0,0 +1,82 @@
+package ansimage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrUnsupported occurs when a Renderer can't render part of an image on its own,
+// like a single row of a graphics protocol image.
+var ErrUnsupported = errors.New("ANSImage: not supported by this renderer")
+
+// kittyChunkSize is the maximum payload size of a kitty graphics escape sequence.
+const kittyChunkSize = 4096
+
+// KittyRenderer is a Renderer writing frames with the kitty terminal graphics
+// protocol (also understood by WezTerm and Konsole): each frame is sent as a
+// compressed RGB image of the ANSI-pixels, scaled by the terminal to the cells
+// the text rendering would take. Every frame replaces the image and placement
+// of the previous one, so an animation plays in place.
+type KittyRenderer struct {
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+}
+
+// RenderPixel is not supported: pixels are only sent as part of a frame.
+func (kr KittyRenderer) RenderPixel(ap *ANSIpixel) string {
+	return ""
+}
+
+// RenderRow is not supported: the protocol places whole images.
+func (kr KittyRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	return ErrUnsupported
+}
+
+// RenderFrame transmits frame as image 1 and displays it at the cursor,
+// leaving the cursor on the row below the image.
+func (kr KittyRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	var height int
+	var pixels bytes.Buffer
+	zw := zlib.NewWriter(&pixels)
+	for row := 0; row < ai.Rows(); row++ {
+		for _, y := range ai.PixelRows(row) {
+			for x := 0; x < ai.w; x++ {
+				c := ai.frame[frame][y][x].shownColor()
+				r, g, b := c.R, c.G, c.B
+				if kr.ColorFunc != nil {
+					r, g, b = kr.ColorFunc(r, g, b)
+				}
+				zw.Write([]byte{r, g, b})
+			}
+			height++
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	payload := base64.StdEncoding.EncodeToString(pixels.Bytes())
+
+	bw := bufio.NewWriter(w)
+	for i := 0; i == 0 || i < len(payload); i += kittyChunkSize {
+		end := i + kittyChunkSize
+		more := 1
+		if end >= len(payload) {
+			end, more = len(payload), 0
+		}
+		if i == 0 {
+			// a=T: transmit and display, f=24: RGB, o=z: zlib, q=2: no replies
+			fmt.Fprintf(bw, "\033_Ga=T,i=1,p=1,q=2,f=24,o=z,s=%d,v=%d,c=%d,r=%d,C=1,m=%d;%s\033\\",
+				ai.w, height, ai.w, ai.Rows(), more, payload[i:end])
+		} else {
+			fmt.Fprintf(bw, "\033_Gm=%d;%s\033\\", more, payload[i:end])
+		}
+	}
+	bw.WriteString(strings.Repeat("\n", ai.Rows())) // C=1 kept the cursor in place
+	return bw.Flush()
+}
@@ -0,0 +1,183 @@
+package ansimage
+
+import (
+	"errors"
+	"image/color"
+)
+
+var (
+	// ErrLayerMismatch occurs when layered ANSImages use different dithering modes.
+	ErrLayerMismatch = errors.New("ANSImage: layers must use the same dithering mode")
+
+	// ErrSizeMismatch occurs when appended ANSImages differ in size or dithering mode.
+	ErrSizeMismatch = errors.New("ANSImage: images must have the same size and dithering mode")
+)
+
+// Layer is an ANSImage placed over another ANSImage by Flatten.
+type Layer struct {
+	// Image is the layer content. Its frames follow their own delays, looping
+	// independently of the image below.
+	Image *ANSImage
+
+	// Y, X position the top-left ANSI-pixel of the layer on the image below.
+	// Parts falling outside of it are clipped.
+	Y, X int
+
+	// Transparency blends the layer with the image below
+	// (0 is fully opaque, 1 is invisible).
+	Transparency float64
+
+	// Key, if not nil, is the color of the layer treated as fully transparent,
+	// e.g. the background color the layer was loaded with.
+	Key color.Color
+}
+
+// Flatten returns a new ANSImage with layers drawn over every frame of ai, in order (z-order).
+func (ai *ANSImage) Flatten(layers ...Layer) (*ANSImage, error) {
+	for i, l := range layers {
+		if l.Image.dithering != ai.dithering {
+			return nil, ErrLayerMismatch
+		}
+		if l.Y < 0 || l.X < 0 || l.Y+l.Image.h > ai.h || l.X+l.Image.w > ai.w {
+			logf("Flatten: layer %d clipped to the image", i)
+		}
+	}
+
+	out, err := ai.clone()
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := 0
+	for frame := range out.frame {
+		for _, l := range layers {
+			l.drawOn(out, frame, l.Image.frameAt(elapsed))
+		}
+		elapsed += out.delay[frame]
+	}
+	return out, nil
+}
+
+// drawOn blends frame lf of the layer onto frame of dst.
+func (l *Layer) drawOn(dst *ANSImage, frame, lf int) {
+	var keyR, keyG, keyB uint8
+	if l.Key != nil {
+		r, g, b, _ := l.Key.RGBA()
+		keyR, keyG, keyB = uint8(r>>8), uint8(g>>8), uint8(b>>8)
+	}
+
+	mix := func(under, over uint8) uint8 {
+		return uint8(float64(over)*(1-l.Transparency) + float64(under)*l.Transparency + 0.5)
+	}
+
+	for y := 0; y < l.Image.h; y++ {
+		for x := 0; x < l.Image.w; x++ {
+			dy, dx := l.Y+y, l.X+x
+			if dy < 0 || dy >= dst.h || dx < 0 || dx >= dst.w {
+				continue
+			}
+
+			src := l.Image.frame[lf][y][x]
+			if l.Key != nil && src.R == keyR && src.G == keyG && src.B == keyB {
+				continue
+			}
+
+			p := dst.frame[frame][dy][dx]
+			p.R = mix(p.R, src.R)
+			p.G = mix(p.G, src.G)
+			p.B = mix(p.B, src.B)
+			p.Brightness = mix(p.Brightness, src.Brightness)
+		}
+	}
+}
+
+// frameAt returns the frame shown at elapsed (in 100ths of a second) since
+// playback started, looping over the animation.
+func (ai *ANSImage) frameAt(elapsed int) int {
+	total := 0
+	for _, d := range ai.delay {
+		total += d
+	}
+	if total == 0 {
+		return 0
+	}
+
+	elapsed %= total
+	for frame, d := range ai.delay {
+		if elapsed < d {
+			return frame
+		}
+		elapsed -= d
+	}
+	return 0
+}
+
+// clone returns a deep copy of ai.
+func (ai *ANSImage) clone() (*ANSImage, error) {
+	out, err := New(ai.h, ai.w, len(ai.frame), ai.background(), ai.dithering)
+	if err != nil {
+		return nil, err
+	}
+	out.maxprocs = ai.maxprocs
+	out.blockY, out.blockX = ai.blockY, ai.blockX
+	out.charRamp = ai.charRamp
+	out.loopCount = ai.loopCount
+	out.renderHook = ai.renderHook
+	copy(out.delay, ai.delay)
+	for frame := range ai.frame {
+		copyFrame(out.frame[frame], ai.frame[frame])
+	}
+	return out, nil
+}
+
+// background returns the background color of ai.
+func (ai *ANSImage) background() color.Color {
+	return color.RGBA{ai.bgR, ai.bgG, ai.bgB, 0xff}
+}
+
+// copyFrame copies the colors, backgrounds, brightness and overlay text of the pixels of src
+// into dst, which must have the same size.
+func copyFrame(dst, src ANSIframe) {
+	for y := range src {
+		for x, p := range src[y] {
+			dst[y][x].R, dst[y][x].G, dst[y][x].B = p.R, p.G, p.B
+			dst[y][x].bgR, dst[y][x].bgG, dst[y][x].bgB = p.bgR, p.bgG, p.bgB
+			dst[y][x].Brightness = p.Brightness
+			dst[y][x].dots = p.dots
+			dst[y][x].text = p.text
+			dst[y][x].transparent = p.transparent
+			dst[y][x].clearBg = p.clearBg
+		}
+	}
+}
+
+// Append returns a new ANSImage playing the frames of ai followed by those of others.
+// All images must have the same size and dithering mode.
+func (ai *ANSImage) Append(others ...*ANSImage) (*ANSImage, error) {
+	for _, other := range others {
+		if other.h != ai.h || other.w != ai.w || other.dithering != ai.dithering {
+			return nil, ErrSizeMismatch
+		}
+	}
+
+	out, err := ai.clone()
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range others {
+		tail, err := other.clone()
+		if err != nil {
+			return nil, err
+		}
+		for _, frame := range tail.frame {
+			for _, row := range frame {
+				for _, pixel := range row {
+					pixel.source = out
+				}
+			}
+		}
+		out.frame = append(out.frame, tail.frame...)
+		out.delay = append(out.delay, tail.delay...)
+	}
+	return out, nil
+}
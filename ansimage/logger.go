@@ -0,0 +1,23 @@
+package ansimage
+
+// Logger receives warnings about content the package adjusts silently, like
+// dropped frames, clamped delays and fallback decisions. *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logger is the Logger set with SetLogger, or nil to discard warnings.
+var logger Logger
+
+// SetLogger sets the Logger receiving the package warnings (nil, the default,
+// discards them). It should be called before loading any ANSImage.
+func SetLogger(l Logger) {
+	logger = l
+}
+
+// logf sends a warning to the package Logger, if any.
+func logf(format string, v ...interface{}) {
+	if logger != nil {
+		logger.Printf(format, v...)
+	}
+}
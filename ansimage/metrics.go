@@ -0,0 +1,51 @@
+package ansimage
+
+import (
+	"io"
+	"time"
+)
+
+// RenderStats describes the rendering of a frame, as reported to a RenderHook.
+type RenderStats struct {
+	Frame      int
+	Rows, Cols int // terminal rows and columns
+	MaxProcs   int
+	Bytes      int           // size of the output
+	Duration   time.Duration // including the writes, when rendering to an io.Writer
+}
+
+// RenderHook is called after every frame rendered by an ANSImage, so that
+// render cost can be observed, e.g. when tuning SetMaxProcs.
+type RenderHook func(stats RenderStats)
+
+// SetRenderHook sets the function called after every rendered frame (nil to disable).
+func (ai *ANSImage) SetRenderHook(hook RenderHook) {
+	ai.renderHook = hook
+}
+
+// observe reports a frame rendered since start to the render hook, if any.
+func (ai *ANSImage) observe(frame, bytes int, start time.Time) {
+	if ai.renderHook == nil {
+		return
+	}
+	ai.renderHook(RenderStats{
+		Frame:    frame,
+		Rows:     ai.Rows(),
+		Cols:     ai.w,
+		MaxProcs: ai.maxprocs,
+		Bytes:    bytes,
+		Duration: time.Since(start),
+	})
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += n
+	return n, err
+}
@@ -0,0 +1,316 @@
+package ansimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// Option customizes how an ANSImage is loaded by Load and the New* constructors.
+type Option func(*loadConfig)
+
+// loadConfig holds the settings collected from Options.
+type loadConfig struct {
+	sizeY, sizeX       int // 0 when unscaled
+	scaleMode          ScaleMode
+	dithering          DitheringMode
+	bg                 color.Color
+	maxProcs           int
+	chromaKey          *color.RGBA
+	chromaKeyTolerance uint8
+	denoiseRadius      int
+	smoothing          float64
+	charRamp           []rune
+	blockY, blockX     int
+	gamma              float64 // 0 when unadjusted
+	brightness         float64
+	contrast           float64
+	transforms         []Transform
+	transparent        bool
+	alphaThreshold     uint8
+}
+
+// newLoadConfig applies opts to a default loadConfig: unscaled, fit when
+// scaled, without dithering, on a black background.
+func newLoadConfig(opts []Option) *loadConfig {
+	cfg := &loadConfig{scaleMode: ScaleModeFit, dithering: NoDithering, bg: color.Black}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// withArgs returns opts followed by the options set by the positional
+// arguments of the New* constructors, without modifying opts.
+func withArgs(opts []Option, args ...Option) []Option {
+	return append(append([]Option(nil), opts...), args...)
+}
+
+// WithSize scales the image to y x x pixels, with the scale mode set by
+// WithScaleMode (ScaleModeFit by default). Use ScaledSize to get the size of
+// an image of some terminal cells. Non-positive sizes keep the image unscaled.
+func WithSize(y, x int) Option {
+	return func(cfg *loadConfig) {
+		if y > 0 && x > 0 {
+			cfg.sizeY, cfg.sizeX = y, x
+		} else {
+			cfg.sizeY, cfg.sizeX = 0, 0
+		}
+	}
+}
+
+// WithScaleMode sets how the image is scaled to the size set by WithSize.
+func WithScaleMode(sm ScaleMode) Option {
+	return func(cfg *loadConfig) {
+		cfg.scaleMode = sm
+	}
+}
+
+// WithDithering sets the way the ANSImage renders ANSI-pixels (char/block
+// elements), NoDithering (half blocks) by default.
+func WithDithering(dm DitheringMode) Option {
+	return func(cfg *loadConfig) {
+		cfg.dithering = dm
+	}
+}
+
+// WithBackground sets the color filling the transparent pixels of the image
+// and the space around dithered ones, black by default.
+func WithBackground(bg color.Color) Option {
+	return func(cfg *loadConfig) {
+		cfg.bg = bg
+	}
+}
+
+// WithMaxProcs sets the maximum number of parallel goroutines rendering the
+// ANSImage, see ANSImage.SetMaxProcs.
+func WithMaxProcs(max int) Option {
+	return func(cfg *loadConfig) {
+		cfg.maxProcs = max
+	}
+}
+
+// WithChromaKey treats pixels within tolerance (per RGB channel) of key as
+// transparent, for GIFs exported on a solid background instead of an alpha
+// channel. Keyed pixels show the background color, like transparent ones.
+func WithChromaKey(key color.Color, tolerance uint8) Option {
+	return func(cfg *loadConfig) {
+		c := color.RGBAModel.Convert(key).(color.RGBA)
+		cfg.chromaKey = &c
+		cfg.chromaKeyTolerance = tolerance
+	}
+}
+
+// WithDenoise runs a median filter of the given radius (in source pixels) over
+// every frame before scaling. It cleans up the dithering noise of heavily
+// compressed GIFs, which otherwise flickers at terminal resolution.
+// A radius of 0 disables the filter.
+func WithDenoise(radius int) Option {
+	return func(cfg *loadConfig) {
+		cfg.denoiseRadius = radius
+	}
+}
+
+// WithTemporalSmoothing blends every scaled frame with the previous one, by
+// strength (from 0, disabled, to 1), suppressing single-frame noise that
+// flickers at small sizes. Only the New*Scaled* constructors apply it.
+func WithTemporalSmoothing(strength float64) Option {
+	return func(cfg *loadConfig) {
+		cfg.smoothing = math.Max(0, math.Min(1, strength))
+	}
+}
+
+// WithAdjustment corrects the tone of every frame before scaling: gamma (1
+// keeps it, above 1 brightens the midtones), then brightness and contrast in
+// percent (-100 to 100, 0 keeps them). Terminal colors often look washed out
+// or too dark next to the source GIF; this lets each one be tuned.
+func WithAdjustment(gamma, brightness, contrast float64) Option {
+	return func(cfg *loadConfig) {
+		if gamma <= 0 {
+			gamma = 1
+		}
+		cfg.gamma = gamma
+		cfg.brightness = math.Max(-100, math.Min(100, brightness))
+		cfg.contrast = math.Max(-100, math.Min(100, contrast))
+	}
+}
+
+// adjusted reports whether WithAdjustment changes the colors.
+func (cfg *loadConfig) adjusted() bool {
+	return (cfg.gamma != 0 && cfg.gamma != 1) || cfg.brightness != 0 || cfg.contrast != 0
+}
+
+// WithCharRamp sets the characters drawing brightness in chars dithering mode,
+// see ANSImage.SetCharRamp.
+func WithCharRamp(ramp []rune) Option {
+	return func(cfg *loadConfig) {
+		cfg.charRamp = ramp
+	}
+}
+
+// WithBlockSize sets the size in pixels (rows, columns) of the image area
+// drawn in a terminal cell when dithering with blocks, chars or edges, BlockSizeY x
+// BlockSizeX by default. Smaller blocks (e.g. 4x2) average fewer pixels into a
+// cell, keeping more detail and contrast at the cost of noise; larger ones
+// smooth it out. The other dithering modes have a fixed block size, set by
+// their glyphs. Non-positive sizes keep the default.
+func WithBlockSize(y, x int) Option {
+	return func(cfg *loadConfig) {
+		if y > 0 && x > 0 {
+			cfg.blockY, cfg.blockX = y, x
+		}
+	}
+}
+
+// WithTransparency leaves the transparent pixels of the image (see
+// WithAlphaThreshold) undrawn instead
+// of compositing it onto the background: their cells are skipped with a
+// cursor movement, so the image layers over the background of the terminal
+// (see HalfBlockRenderer.RenderCell). Dithered cells are transparent when all
+// their pixels are; the others draw their opaque pixels on the default
+// background of the terminal, but for quadrants and sextants without
+// transparent pixels, which keep their two colors.
+func WithTransparency() Option {
+	return func(cfg *loadConfig) {
+		cfg.transparent = true
+	}
+}
+
+// WithAlphaThreshold sets the alpha (opacity, from 0 to 255) below which the
+// pixels of an image loaded WithTransparency are left undrawn; the others
+// are drawn opaque, in their own color. The default, 1, only leaves out fully
+// transparent pixels: higher thresholds also drop the faint edges left by
+// antialiasing and scaling. It has no effect without WithTransparency, as
+// the pixels are then composited onto the background.
+func WithAlphaThreshold(alpha uint8) Option {
+	return func(cfg *loadConfig) {
+		cfg.alphaThreshold = alpha
+	}
+}
+
+// skipped reports whether a pixel of opacity alpha is left undrawn with cfg.
+func (cfg *loadConfig) skipped(alpha uint8) bool {
+	return cfg.transparent && (alpha == 0 || alpha < cfg.alphaThreshold)
+}
+
+// blockSize returns the block size of dithering mode dm with cfg.
+func (cfg *loadConfig) blockSize(dm DitheringMode) (int, int) {
+	if cfg.blockY > 0 && (dm == DitheringWithBlocks || dm == DitheringWithChars || dm == DitheringWithEdges) {
+		return cfg.blockY, cfg.blockX
+	}
+	return BlockSize(dm)
+}
+
+// ScaledSize returns the image size in pixels (rows, columns) to pass to the
+// New*Scaled* constructors, with the same dithering mode and options, for an
+// ANSImage of rows x cols terminal cells.
+func ScaledSize(rows, cols int, dm DitheringMode, opts ...Option) (int, int) {
+	blockY, blockX := newLoadConfig(opts).blockSize(dm)
+	return blockY * rows, blockX * cols
+}
+
+// apply sets the options kept by the ANSImage ai, created with err.
+func (cfg *loadConfig) apply(ai *ANSImage, err error) (*ANSImage, error) {
+	if err != nil {
+		return nil, err
+	}
+	if cfg.charRamp != nil {
+		ai.SetCharRamp(cfg.charRamp)
+	}
+	if cfg.maxProcs > 0 {
+		ai.SetMaxProcs(cfg.maxProcs)
+	}
+	return ai, nil
+}
+
+// filtered reports whether prepareFrame alters pixels, rather than only copying them.
+func (cfg *loadConfig) filtered() bool {
+	return cfg.chromaKey != nil || cfg.denoiseRadius > 0 || cfg.adjusted()
+}
+
+// prepareFrame returns a copy of the composited GIF canvas img with the
+// load options applied, so every frame keeps its own pixels.
+func (cfg *loadConfig) prepareFrame(img *image.RGBA) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	copy(out.Pix, img.Pix)
+
+	if cfg.denoiseRadius > 0 {
+		out = median(out, cfg.denoiseRadius)
+	}
+
+	if cfg.chromaKey != nil {
+		key, tol := cfg.chromaKey, int(cfg.chromaKeyTolerance)
+		near := func(a, b uint8) bool {
+			return int(a)-int(b) <= tol && int(b)-int(a) <= tol
+		}
+		for i := 0; i < len(out.Pix); i += 4 {
+			p := out.Pix[i : i+4 : i+4]
+			if near(p[0], key.R) && near(p[1], key.G) && near(p[2], key.B) {
+				p[0], p[1], p[2], p[3] = 0, 0, 0, 0
+			}
+		}
+	}
+
+	if cfg.adjusted() {
+		var adj image.Image = out
+		if cfg.gamma != 0 && cfg.gamma != 1 {
+			adj = imaging.AdjustGamma(adj, cfg.gamma)
+		}
+		if cfg.brightness != 0 {
+			adj = imaging.AdjustBrightness(adj, cfg.brightness)
+		}
+		if cfg.contrast != 0 {
+			adj = imaging.AdjustContrast(adj, cfg.contrast)
+		}
+		// imaging returns images at the origin; keep the bounds of the canvas
+		draw.Draw(out, out.Bounds(), adj, image.ZP, draw.Src)
+	}
+	return out
+}
+
+// median returns img with every channel of every pixel replaced by the median
+// of the (2*radius+1)² neighbourhood around it, clamped at the edges.
+func median(img *image.RGBA, radius int) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	window := make([]int, 0, (2*radius+1)*(2*radius+1))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			o := out.PixOffset(x, y)
+			for ch := 0; ch < 4; ch++ {
+				window = window[:0]
+				for ny := y - radius; ny <= y+radius; ny++ {
+					for nx := x - radius; nx <= x+radius; nx++ {
+						p := image.Pt(nx, ny)
+						if !p.In(b) {
+							continue
+						}
+						window = append(window, int(img.Pix[img.PixOffset(nx, ny)+ch]))
+					}
+				}
+				sort.Ints(window)
+				out.Pix[o+ch] = uint8(window[len(window)/2])
+			}
+		}
+	}
+	return out
+}
+
+// smooth applies temporal smoothing to the scaled frames of proxy. The first
+// frame is blended with the last one, as animations loop.
+func (cfg *loadConfig) smooth(proxy *gifProxy) {
+	n := len(proxy.image)
+	if cfg.smoothing == 0 || n < 2 {
+		return
+	}
+	blended := make([]image.Image, n)
+	for i, cur := range proxy.image {
+		prev := proxy.image[(i+n-1)%n]
+		blended[i] = imaging.Overlay(prev, cur, image.ZP, 1-cfg.smoothing)
+	}
+	proxy.image = blended
+}
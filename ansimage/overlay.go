@@ -0,0 +1,113 @@
+package ansimage
+
+import (
+	"image/color"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// wideTail is the overlay text of the cell covered by the right half of a
+// wide character (CJK, emoji), which draws nothing.
+const wideTail = "\x00"
+
+// textWidths measures characters like the terminals of most viewers:
+// ambiguous ones (East Asian Width A) are narrow.
+var textWidths = &runewidth.Condition{EastAsianWidth: false}
+
+// glyph returns the character drawn for ap: the overlay text of its cell (see
+// ANSImage.OverlayText) if any, or else block.
+func (ap *ANSIpixel) glyph(block string) string {
+	switch ap.text {
+	case "":
+		return block
+	case wideTail:
+		return ""
+	}
+	return ap.text
+}
+
+// OverlayText stamps text onto frame of ANSImage, from the terminal cell at
+// row y, column x (see Rows and PixelRows), for captions, watermarks or
+// timestamps. The characters replace the blocks of their cells, in color fg
+// over bg, or over the colors of the image if bg is nil. Wide characters
+// (CJK, emoji) take two cells, and combining marks join the previous
+// character; text is sanitized (see SanitizeText) and clipped to the image.
+// Renderers drawing pixels rather than characters (sixel, Kitty, iTerm2,
+// Rasterize) only show the colors of the cells.
+func (ai *ANSImage) OverlayText(frame, y, x int, text string, fg, bg color.Color) error {
+	if frame < 0 || frame >= len(ai.frame) || y < 0 || y >= ai.Rows() || x < 0 || x >= ai.w {
+		return ErrOutOfBounds
+	}
+	fR, fG, fB := rgb8(fg)
+	pixelRows := ai.PixelRows(y)
+
+	// cell sets the text and colors of the cell in column x. The other half
+	// of a wide character it replaces becomes a space.
+	cell := func(x int, text string) {
+		if old := ai.cellText(frame, pixelRows, x); old == wideTail && text != wideTail {
+			ai.setCellText(frame, pixelRows, x-1, " ")
+		} else if x+1 < ai.w && ai.cellText(frame, pixelRows, x+1) == wideTail {
+			ai.setCellText(frame, pixelRows, x+1, " ")
+		}
+		if ai.dithering == NoDithering {
+			// the upper pixel is the background, the lower one the character
+			upper, lower := ai.frame[frame][pixelRows[0]][x], ai.frame[frame][pixelRows[1]][x]
+			if bg != nil {
+				upper.R, upper.G, upper.B = rgb8(bg)
+				upper.transparent = false
+			}
+			lower.R, lower.G, lower.B = fR, fG, fB
+			lower.text = text
+			lower.transparent = false
+			return
+		}
+		p := ai.frame[frame][pixelRows[0]][x]
+		if bg != nil {
+			p.bgR, p.bgG, p.bgB = rgb8(bg)
+			p.clearBg = false
+		}
+		p.R, p.G, p.B = fR, fG, fB
+		p.text = text
+		p.transparent = false
+	}
+
+	last := -1 // column of the last character, for combining marks
+	for _, r := range SanitizeText(text) {
+		if r == '\n' {
+			break
+		}
+		width := textWidths.RuneWidth(r)
+		switch {
+		case width == 0 && last >= 0:
+			cell(last, ai.cellText(frame, pixelRows, last)+string(r))
+			continue
+		case width == 0:
+			continue
+		case x+width > ai.w:
+			return nil
+		}
+		cell(x, string(r))
+		if width == 2 {
+			cell(x+1, wideTail)
+		}
+		last = x
+		x += width
+	}
+	return nil
+}
+
+// cellText returns the overlay text of the cell in column x drawn by pixelRows of frame.
+func (ai *ANSImage) cellText(frame int, pixelRows []int, x int) string {
+	return ai.frame[frame][pixelRows[len(pixelRows)-1]][x].text
+}
+
+// setCellText sets the overlay text of the cell in column x drawn by pixelRows of frame.
+func (ai *ANSImage) setCellText(frame int, pixelRows []int, x int, text string) {
+	ai.frame[frame][pixelRows[len(pixelRows)-1]][x].text = text
+}
+
+// rgb8 returns the 8-bit components of c.
+func rgb8(c color.Color) (uint8, uint8, uint8) {
+	r, g, b, _ := c.RGBA()
+	return uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)
+}
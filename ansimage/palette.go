@@ -0,0 +1,169 @@
+package ansimage
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// ColorMode selects the SGR sequences the text renderers write colors with.
+type ColorMode uint8
+
+// ANSImage color modes:
+// true color (24-bit colors, "38;2;R;G;B"),
+// 256 colors (nearest color of the xterm 256-color palette, "38;5;N"),
+// 16 colors (nearest of the 16 ANSI colors, "30" to "37" and "90" to "97"),
+// for terminals without true color support;
+// mono (black or white by luminance, "30" and "97"),
+// 4 grays (black, dark gray, light gray, white of the xterm palette, "38;5;N"),
+// for e-ink badges and serial LCDs (see also ANSImage.Bitmap).
+const (
+	TrueColor = ColorMode(iota)
+	Color256
+	Color16
+	Mono
+	Gray4
+)
+
+// ansi16Palette are the 16 ANSI colors, as shown by xterm by default.
+var ansi16Palette = [16]color.RGBA{
+	{0, 0, 0, 255}, {205, 0, 0, 255}, {0, 205, 0, 255}, {205, 205, 0, 255},
+	{0, 0, 238, 255}, {205, 0, 205, 255}, {0, 205, 205, 255}, {229, 229, 229, 255},
+	{127, 127, 127, 255}, {255, 0, 0, 255}, {0, 255, 0, 255}, {255, 255, 0, 255},
+	{92, 92, 255, 255}, {255, 0, 255, 255}, {0, 255, 255, 255}, {255, 255, 255, 255},
+}
+
+// grayLevels are the palette indexes of the grays of the Mono and Gray4 color
+// modes, from black to white.
+var grayLevels = map[ColorMode][]uint8{
+	Mono:  {0, 15},             // ANSI black and bright white
+	Gray4: {16, 240, 248, 231}, // xterm palette: 0, 88, 168 and 255
+}
+
+// xtermCubeLevels are the channel values of the 6x6x6 color cube of the xterm palette (colors 16 to 231).
+var xtermCubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// sgr returns the sequence setting the foreground (or background) color to r, g, b.
+func (cm ColorMode) sgr(background bool, r, g, b uint8) string {
+	layer := 38
+	if background {
+		layer = 48
+	}
+	switch cm {
+	case Color256, Gray4:
+		return fmt.Sprintf("\033[%d;5;%dm", layer, cm.index(r, g, b))
+	case Color16, Mono:
+		code := 30 + int(cm.index(r, g, b)) // 30-37: colors, 90-97: bright colors
+		if code > 37 {
+			code += 60 - 8
+		}
+		if background {
+			code += 10
+		}
+		return fmt.Sprintf("\033[%dm", code)
+	}
+	return fmt.Sprintf("\033[%d;2;%d;%d;%dm", layer, r, g, b)
+}
+
+// index returns the palette index of the color of limited color mode cm closest to r, g, b.
+func (cm ColorMode) index(r, g, b uint8) uint8 {
+	switch cm {
+	case Color16:
+		return ansi16(r, g, b)
+	case Mono, Gray4:
+		return grayLevels[cm][cm.grayLevel(r, g, b)]
+	}
+	return xterm256(r, g, b)
+}
+
+// grayLevel returns the position in grayLevels of the gray of the Mono or
+// Gray4 color mode cm closest to the luminance of r, g, b.
+func (cm ColorMode) grayLevel(r, g, b uint8) int {
+	y, _, _ := Grayscale(r, g, b)
+	best, bestDist := 0, -1
+	for i, index := range grayLevels[cm] {
+		v, _, _ := cm.paletteColor(index)
+		dist := int(y) - int(v)
+		if dist < 0 {
+			dist = -dist
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// paletteColor returns the color at index i of the palette of limited color mode cm.
+func (cm ColorMode) paletteColor(i uint8) (uint8, uint8, uint8) {
+	switch {
+	case cm == Color16 || i < 16:
+		c := ansi16Palette[i&0xf]
+		return c.R, c.G, c.B
+	case i >= 232:
+		v := uint8(8 + 10*int(i-232))
+		return v, v, v
+	}
+	c := int(i) - 16
+	return uint8(xtermCubeLevels[c/36]), uint8(xtermCubeLevels[c/6%6]), uint8(xtermCubeLevels[c%6])
+}
+
+// ansi16 returns the index of the ANSI color closest to r, g, b.
+func ansi16(r, g, b uint8) uint8 {
+	best, bestDist := 0, -1
+	for i, c := range ansi16Palette {
+		dr, dg, db := int(r)-int(c.R), int(g)-int(c.G), int(b)-int(c.B)
+		if dist := dr*dr + dg*dg + db*db; bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return uint8(best)
+}
+
+// xterm256 returns the color of the xterm palette closest to r, g, b, from
+// the color cube or the gray ramp (colors 232 to 255). The 16 system colors
+// are left out, as terminals change them with their theme. Grays only use
+// the ramp, and the black and white of the cube beyond its ends, so gray
+// images keep its 24 even steps.
+func xterm256(r, g, b uint8) uint8 {
+	if r == g && g == b {
+		switch {
+		case r < 4:
+			return 16
+		case r > 246:
+			return 231
+		case r > 238:
+			return 255
+		}
+		return uint8(232 + (int(r)-3)/10)
+	}
+
+	level := func(v uint8) int {
+		for i := 1; i < len(xtermCubeLevels); i++ {
+			if int(v) < (xtermCubeLevels[i-1]+xtermCubeLevels[i])/2 {
+				return i - 1
+			}
+		}
+		return len(xtermCubeLevels) - 1
+	}
+	dist := func(cr, cg, cb int) int {
+		dr, dg, db := int(r)-cr, int(g)-cg, int(b)-cb
+		return dr*dr + dg*dg + db*db
+	}
+
+	ri, gi, bi := level(r), level(g), level(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDist := dist(xtermCubeLevels[ri], xtermCubeLevels[gi], xtermCubeLevels[bi])
+
+	gray := (int(r)+int(g)+int(b))/3 - 3 // 8 + 10*i at the middle of the steps
+	gray /= 10
+	if gray < 0 {
+		gray = 0
+	} else if gray > 23 {
+		gray = 23
+	}
+	v := 8 + 10*gray
+	if dist(v, v, v) < cubeDist {
+		return uint8(232 + gray)
+	}
+	return uint8(cube)
+}
@@ -0,0 +1,300 @@
+package ansimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Step is a stage of a Pipeline: it returns the ANSImage made from ai, or ai
+// itself after changing it.
+type Step func(ai *ANSImage) (*ANSImage, error)
+
+// StepBuilder makes the Step of a pipeline step from its arguments.
+type StepBuilder func(args []string) (Step, error)
+
+// Pipeline is a declarative sequence of steps presenting an ANSImage (crop,
+// scale, filter, overlay...), followed by the renderer writing it, so complex
+// presentations are configured rather than coded. See ParsePipeline.
+type Pipeline struct {
+	Steps []Step
+
+	// Renderer is the name of the registered renderer (see LookupRenderer)
+	// writing the result, empty to leave the choice to the program.
+	Renderer string
+}
+
+var (
+	// errStepArgs occurs when a pipeline step has the wrong arguments.
+	errStepArgs = errors.New("ANSImage: invalid pipeline step arguments")
+
+	// errRendererStep occurs when the renderer of a pipeline isn't its last step.
+	errRendererStep = errors.New("ANSImage: renderer must be the last pipeline step")
+)
+
+var (
+	stepsMu sync.RWMutex
+	steps   = map[string]StepBuilder{
+		"crop":      cropStep,
+		"scale":     scaleStep,
+		"filter":    filterStep,
+		"transform": transformStep,
+		"overlay":   overlayStep,
+	}
+)
+
+// RegisterStep makes a pipeline step available by name, so that programs can
+// add their own stages to pipelines. Registering an existing name replaces
+// its builder.
+func RegisterStep(name string, build StepBuilder) {
+	stepsMu.Lock()
+	defer stepsMu.Unlock()
+	steps[name] = build
+}
+
+// StepNames returns the registered pipeline step names, sorted.
+func StepNames() []string {
+	stepsMu.RLock()
+	defer stepsMu.RUnlock()
+	names := make([]string, 0, len(steps))
+	for name := range steps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePipeline parses a pipeline written one step per line, a step name
+// followed by its arguments separated by spaces:
+//
+//	crop Y X HEIGHT WIDTH        region in ANSI-pixels (see ANSImage.Crop)
+//	scale ROWS COLS [MODE]       rescale to terminal cells, MODE a scale mode name
+//	filter NAME [ARG]            invert, sepia, grayscale, saturation FACTOR or hue DEGREES
+//	transform NAME               rotate90, rotate180, rotate270, fliph or flipv
+//	overlay ROW COL TEXT         stamp text on every frame, negative ROW and COL
+//	                             counting from the bottom and right
+//	renderer NAME                registered renderer writing the result, last
+//
+// More steps can be added with RegisterStep. Empty lines are skipped.
+func ParsePipeline(lines []string) (*Pipeline, error) {
+	p := &Pipeline{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if p.Renderer != "" {
+			return nil, errRendererStep
+		}
+		name, args := fields[0], fields[1:]
+		if name == "renderer" {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s: %v", line, errStepArgs)
+			}
+			if _, ok := LookupRenderer(args[0]); !ok {
+				return nil, fmt.Errorf("%s: unknown renderer", line)
+			}
+			p.Renderer = args[0]
+			continue
+		}
+
+		stepsMu.RLock()
+		build, ok := steps[name]
+		stepsMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown pipeline step", line)
+		}
+		step, err := build(args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", line, err)
+		}
+		p.Steps = append(p.Steps, step)
+	}
+	return p, nil
+}
+
+// Run passes ai through the steps of p in order and returns the result. ai
+// is left unchanged.
+func (p *Pipeline) Run(ai *ANSImage) (*ANSImage, error) {
+	if len(p.Steps) == 0 {
+		return ai, nil
+	}
+	out, err := ai.clone()
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range p.Steps {
+		if out, err = step(out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// stepInts parses the integer arguments of a pipeline step.
+func stepInts(args []string) ([]int, error) {
+	values := make([]int, len(args))
+	for i, arg := range args {
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, errStepArgs
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// cropStep builds the step "crop Y X HEIGHT WIDTH".
+func cropStep(args []string) (Step, error) {
+	if len(args) != 4 {
+		return nil, errStepArgs
+	}
+	v, err := stepInts(args)
+	if err != nil {
+		return nil, err
+	}
+	rect := image.Rect(v[1], v[0], v[1]+v[3], v[0]+v[2])
+	return func(ai *ANSImage) (*ANSImage, error) {
+		return ai.Crop(rect)
+	}, nil
+}
+
+// scaleStep builds the step "scale ROWS COLS [MODE]". The frames are rescaled
+// as they look in a terminal (see Rasterize), so undrawn pixels and overlay
+// text become colors.
+func scaleStep(args []string) (Step, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, errStepArgs
+	}
+	v, err := stepInts(args[:2])
+	if err != nil {
+		return nil, err
+	}
+	rows, cols := v[0], v[1]
+	if rows <= 0 || cols <= 0 {
+		return nil, errStepArgs
+	}
+	sm := ScaleModeResize
+	if len(args) == 3 {
+		var ok bool
+		if sm, ok = LookupScaleMode(args[2]); !ok {
+			return nil, errUnknownScaleMode
+		}
+	}
+	return func(ai *ANSImage) (*ANSImage, error) {
+		frames := make([]image.Image, len(ai.frame))
+		for i := range ai.frame {
+			frames[i] = ai.Rasterize(i, 1)
+		}
+		opts := []Option{WithScaleMode(sm)}
+		if ai.blockY > 0 {
+			opts = append(opts, WithBlockSize(ai.blockY, ai.blockX))
+		}
+		y, x := ScaledSize(rows, cols, ai.dithering, opts...)
+		opts = append(opts, WithSize(y, x))
+		if ai.charRamp != nil {
+			opts = append(opts, WithCharRamp(ai.charRamp))
+		}
+		out, err := NewFromFrames(frames, ai.delay, ai.background(), ai.dithering, opts...)
+		if err != nil {
+			return nil, err
+		}
+		out.maxprocs = ai.maxprocs
+		out.loopCount = ai.loopCount
+		out.renderHook = ai.renderHook
+		return out, nil
+	}, nil
+}
+
+// filterStep builds the step "filter NAME [ARG]".
+func filterStep(args []string) (Step, error) {
+	if len(args) == 0 {
+		return nil, errStepArgs
+	}
+	var cf ColorFunc
+	switch name := args[0]; {
+	case name == "invert" && len(args) == 1:
+		cf = Invert
+	case name == "sepia" && len(args) == 1:
+		cf = Sepia
+	case name == "grayscale" && len(args) == 1:
+		cf = Grayscale
+	case (name == "saturation" || name == "hue") && len(args) == 2:
+		v, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return nil, errStepArgs
+		}
+		if name == "saturation" {
+			cf = Saturation(v)
+		} else {
+			cf = HueShift(v)
+		}
+	default:
+		return nil, errStepArgs
+	}
+	return func(ai *ANSImage) (*ANSImage, error) {
+		ai.ApplyFilter(ColorFilter(cf))
+		return ai, nil
+	}, nil
+}
+
+// transformNames maps the names of the transform step to geometric transforms.
+var transformNames = map[string]Transform{
+	"rotate90":  TransformRotate90,
+	"rotate180": TransformRotate180,
+	"rotate270": TransformRotate270,
+	"fliph":     TransformFlipH,
+	"flipv":     TransformFlipV,
+}
+
+// transformStep builds the step "transform NAME".
+func transformStep(args []string) (Step, error) {
+	if len(args) != 1 {
+		return nil, errStepArgs
+	}
+	t, ok := transformNames[args[0]]
+	if !ok {
+		return nil, errUnknownTransform
+	}
+	return func(ai *ANSImage) (*ANSImage, error) {
+		return ai.Transform(t)
+	}, nil
+}
+
+// overlayStep builds the step "overlay ROW COL TEXT". The text is white, or
+// black on light backgrounds, over the colors of the image.
+func overlayStep(args []string) (Step, error) {
+	if len(args) < 3 {
+		return nil, errStepArgs
+	}
+	v, err := stepInts(args[:2])
+	if err != nil {
+		return nil, err
+	}
+	row, col := v[0], v[1]
+	text := strings.Join(args[2:], " ")
+	return func(ai *ANSImage) (*ANSImage, error) {
+		y, x := row, col
+		if y < 0 {
+			y += ai.Rows()
+		}
+		if x < 0 {
+			x += ai.w
+		}
+		var fg color.Color = color.White
+		if maxChannel(ai.bgR, ai.bgG, ai.bgB) >= 128 {
+			fg = color.Black
+		}
+		for frame := range ai.frame {
+			if err := ai.OverlayText(frame, y, x, text, fg, nil); err != nil {
+				return nil, err
+			}
+		}
+		return ai, nil
+	}, nil
+}
@@ -0,0 +1,95 @@
+package ansimage
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		steps    int
+		renderer string
+		err      string // in the error, if not empty
+	}{
+		{"empty", nil, 0, "", ""},
+		{"blank lines", []string{"", "  ", "\t"}, 0, "", ""},
+		{"steps", []string{"crop 0 0 4 4", "scale 10 20", "filter invert", "transform fliph"}, 4, "", ""},
+		{"renderer", []string{"filter hue 90", "", "renderer ansi16"}, 1, "ansi16", ""},
+		{"renderer only", []string{"renderer truecolor"}, 0, "truecolor", ""},
+		{"scale mode", []string{"scale 10 20 letterbox"}, 1, "", ""},
+		{"overlay", []string{"overlay -1 0 now playing"}, 1, "", ""},
+		{"unknown step", []string{"blur 3"}, 0, "", "blur 3: unknown pipeline step"},
+		{"renderer not last", []string{"renderer mono", "filter sepia"}, 0, "", errRendererStep.Error()},
+		{"unknown renderer", []string{"renderer vga"}, 0, "", "renderer vga: unknown renderer"},
+		{"renderer without name", []string{"renderer"}, 0, "", errStepArgs.Error()},
+		{"crop arguments", []string{"crop 0 0 4"}, 0, "", errStepArgs.Error()},
+		{"crop numbers", []string{"crop 0 0 four 4"}, 0, "", errStepArgs.Error()},
+		{"scale size", []string{"scale 0 20"}, 0, "", errStepArgs.Error()},
+		{"unknown scale mode", []string{"scale 10 20 squash"}, 0, "", errUnknownScaleMode.Error()},
+		{"filter arguments", []string{"filter saturation"}, 0, "", errStepArgs.Error()},
+		{"filter factor", []string{"filter saturation lots"}, 0, "", errStepArgs.Error()},
+		{"unknown filter", []string{"filter blur"}, 0, "", errStepArgs.Error()},
+		{"unknown transform", []string{"transform rotate45"}, 0, "", errUnknownTransform.Error()},
+		{"overlay without text", []string{"overlay 0 0"}, 0, "", errStepArgs.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePipeline(tt.lines)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("ParsePipeline() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Steps) != tt.steps || p.Renderer != tt.renderer {
+				t.Errorf("ParsePipeline() = %d steps, renderer %q, want %d, %q", len(p.Steps), p.Renderer, tt.steps, tt.renderer)
+			}
+		})
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		h, w int
+	}{
+		{"crop", "crop 2 1 4 3", 4, 3},
+		{"crop clipped", "crop 6 4 10 10", 2, 2},
+		{"scale", "scale 3 5", 6, 5},
+		{"rotate", "transform rotate90", 6, 8},
+		{"filter", "filter grayscale", 8, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ai, err := New(8, 6, 2, color.Black, NoDithering)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ai.SetAt(0, 0, 0, 200, 100, 50, 150)
+			p, err := ParsePipeline([]string{tt.line})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := p.Run(ai)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.Height() != tt.h || out.Width() != tt.w || out.FrameCount() != 2 {
+				t.Errorf("Run() = %dx%d, %d frames, want %dx%d, 2 frames", out.Height(), out.Width(), out.FrameCount(), tt.h, tt.w)
+			}
+			if ai.Height() != 8 || ai.Width() != 6 {
+				t.Errorf("input resized to %dx%d", ai.Height(), ai.Width())
+			}
+			if ap, _ := ai.GetAt(0, 0, 0); ap.R != 200 || ap.G != 100 || ap.B != 50 {
+				t.Errorf("input pixel changed to %d,%d,%d", ap.R, ap.G, ap.B)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package ansimage
+
+import (
+	"image/color"
+	"io"
+)
+
+// quadrantBlocks maps the quadrants drawn in the foreground color (bit 0:
+// upper left, 1: upper right, 2: lower left, 3: lower right) to the Block
+// Element character showing them.
+var quadrantBlocks = [16]string{
+	" ", "▘", "▝", "▀",
+	"▖", "▌", "▞", "▛",
+	"▗", "▚", "▐", "▜",
+	"▄", "▙", "▟", fullBlock,
+}
+
+// splitColors divides the pixels of a block (in row order) into the two groups
+// of closest colors, returning the pixels of the first group as a bit mask
+// (bit i for pixel i) and the mean color of each group. A uniform block is a
+// single group, with every bit set.
+func splitColors(px []color.RGBA) (uint8, color.RGBA, color.RGBA) {
+	mean := func(mask uint8, in bool) (color.RGBA, int) {
+		var r, g, b, n int
+		for i, p := range px {
+			if (mask&(1<<uint(i)) != 0) == in {
+				r, g, b, n = r+int(p.R), g+int(p.G), b+int(p.B), n+1
+			}
+		}
+		if n == 0 {
+			return color.RGBA{A: 0xff}, 0
+		}
+		return color.RGBA{uint8((r + n/2) / n), uint8((g + n/2) / n), uint8((b + n/2) / n), 0xff}, n
+	}
+	sqErr := func(mask uint8, in bool, m color.RGBA) int {
+		e := 0
+		for i, p := range px {
+			if (mask&(1<<uint(i)) != 0) == in {
+				dr, dg, db := int(p.R)-int(m.R), int(p.G)-int(m.G), int(p.B)-int(m.B)
+				e += dr*dr + dg*dg + db*db
+			}
+		}
+		return e
+	}
+
+	best, bestErr := uint8(0), -1
+	var bestFg, bestBg color.RGBA
+	for mask := uint8(1<<uint(len(px)) - 1); mask > 0; mask-- {
+		fg, _ := mean(mask, true)
+		bg, n := mean(mask, false)
+		if n == 0 {
+			bg = fg
+		}
+		if e := sqErr(mask, true, fg) + sqErr(mask, false, bg); bestErr < 0 || e < bestErr {
+			best, bestErr, bestFg, bestBg = mask, e, fg, bg
+		}
+	}
+	return best, bestFg, bestBg
+}
+
+// QuadrantRenderer renders ANSImages dithered with quadrants: every terminal
+// cell shows its 2x2 block with the quadrant block characters, in the two
+// colors that best match the block.
+type QuadrantRenderer struct {
+	// DisableBgColor leaves the background color unset, so only the
+	// foreground quadrants are drawn.
+	DisableBgColor bool
+
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+
+	// ColorMode selects the color sequences, true color by default.
+	ColorMode ColorMode
+}
+
+// RenderPixel returns the color sequences and quadrant block of ap.
+func (qr QuadrantRenderer) RenderPixel(ap *ANSIpixel) string {
+	return ditheredCell(ap, quadrantBlocks[ap.dots&0xf], qr.DisableBgColor, qr.ColorFunc, qr.ColorMode)
+}
+
+// RenderRow writes the cells of a terminal row, then resets the style.
+func (qr QuadrantRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	return renderRow(w, ai, frame, row, qr.RenderPixel)
+}
+
+// RenderFrame writes all the terminal rows of frame, through a buffer.
+func (qr QuadrantRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	return renderFrame(w, ai, frame, qr)
+}
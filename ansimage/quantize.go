@@ -0,0 +1,158 @@
+package ansimage
+
+// ColorDithering selects how the limited color modes (Color256, Color16, Mono, Gray4)
+// approximate colors missing from their palette.
+type ColorDithering uint8
+
+// ANSImage color dithering:
+// nearest (every color becomes the closest palette color),
+// Floyd–Steinberg (the difference is spread to the next pixels, so areas
+// average to the original color: smoother gradients, but noisier animations),
+// ordered (colors are offset by a tiled Bayer matrix of the given size before
+// taking the closest palette color: coarser, but a pixel only changes when
+// its color does, which keeps delta rendering and caching effective).
+const (
+	NearestColor = ColorDithering(iota)
+	FloydSteinberg
+	OrderedBayer2
+	OrderedBayer4
+	OrderedBayer8
+)
+
+// bayerSize returns the size of the Bayer matrix of an ordered color dithering, or 0.
+func (cd ColorDithering) bayerSize() int {
+	switch cd {
+	case OrderedBayer2:
+		return 2
+	case OrderedBayer4:
+		return 4
+	case OrderedBayer8:
+		return 8
+	}
+	return 0
+}
+
+// quantized returns a copy of ai holding only frame, with its colors passed
+// through cf and replaced by colors of the palette of cm, using cd.
+func (ai *ANSImage) quantized(frame int, cm ColorMode, cd ColorDithering, cf ColorFunc) *ANSImage {
+	out := *ai
+	out.frame = []ANSIframe{make(ANSIframe, ai.h)}
+	out.delay = ai.delay[frame : frame+1]
+	for y := range out.frame[0] {
+		out.frame[0][y] = make([]*ANSIpixel, ai.w)
+		for x := range out.frame[0][y] {
+			p := *ai.frame[frame][y][x]
+			p.R, p.G, p.B = applyColorFunc(cf, p.R, p.G, p.B)
+			p.bgR, p.bgG, p.bgB = applyColorFunc(cf, p.bgR, p.bgG, p.bgB)
+			out.frame[0][y][x] = &p
+		}
+	}
+
+	fg := func(y, x int) [3]*uint8 {
+		p := out.frame[0][y][x]
+		return [3]*uint8{&p.R, &p.G, &p.B}
+	}
+	bg := func(y, x int) [3]*uint8 {
+		p := out.frame[0][y][x]
+		return [3]*uint8{&p.bgR, &p.bgG, &p.bgB}
+	}
+	if cd == FloydSteinberg {
+		floydSteinberg(ai.h, ai.w, cm, fg)
+		if ai.dithering != NoDithering {
+			floydSteinberg(ai.h, ai.w, cm, bg)
+		}
+	} else if n := cd.bayerSize(); n > 0 {
+		orderedDither(ai.h, ai.w, cm, bayerMatrix(n), fg)
+		if ai.dithering != NoDithering {
+			orderedDither(ai.h, ai.w, cm, bayerMatrix(n), bg)
+		}
+	}
+	return &out
+}
+
+// bayerMatrix returns the n×n Bayer matrix (n a power of two), holding every
+// threshold rank from 0 to n²-1.
+func bayerMatrix(n int) [][]int {
+	m := [][]int{{0}}
+	for size := 1; size < n; size *= 2 {
+		next := make([][]int, 2*size)
+		for y := range next {
+			next[y] = make([]int, 2*size)
+			for x := range next[y] {
+				// quadrants in the order 0 (top left), 2, 3, 1 (top right)
+				quadrant := [2][2]int{{0, 2}, {3, 1}}[y/size][x/size]
+				next[y][x] = 4*m[y%size][x%size] + quadrant
+			}
+		}
+		m = next
+	}
+	return m
+}
+
+// orderedDither replaces the colors of a h×w grid of pixels, reached through
+// at, by colors of the palette of cm, after offsetting them by up to half the
+// distance between palette colors following the tiled matrix.
+func orderedDither(h, w int, cm ColorMode, matrix [][]int, at func(y, x int) [3]*uint8) {
+	n := len(matrix)
+	spread := 255 / 5 // steps of the xterm color cube
+	switch cm {
+	case Color16:
+		spread = 128
+	case Mono:
+		spread = 255
+	case Gray4:
+		spread = 255 / 3
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			offset := (2*matrix[y%n][x%n]+1)*spread/(2*n*n) - spread/2
+			px := at(y, x)
+			var want [3]uint8
+			for i, v := range px {
+				c := int(*v) + offset
+				if c < 0 {
+					c = 0
+				} else if c > 255 {
+					c = 255
+				}
+				want[i] = uint8(c)
+			}
+			*px[0], *px[1], *px[2] = cm.paletteColor(cm.index(want[0], want[1], want[2]))
+		}
+	}
+}
+
+// floydSteinberg replaces the colors of a h×w grid of pixels, reached through
+// at, by colors of the palette of cm, spreading the quantization error of
+// every pixel to its right and lower neighbours (by 7/16, 3/16, 5/16, 1/16).
+func floydSteinberg(h, w int, cm ColorMode, at func(y, x int) [3]*uint8) {
+	cur, next := make([][3]int, w+2), make([][3]int, w+2) // errors in 16ths, from x-1 to x+1
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			px := at(y, x)
+			var want, got [3]int
+			for i, v := range px {
+				want[i] = int(*v) + cur[x+1][i]/16
+				if want[i] < 0 {
+					want[i] = 0
+				} else if want[i] > 255 {
+					want[i] = 255
+				}
+			}
+			r, g, b := cm.paletteColor(cm.index(uint8(want[0]), uint8(want[1]), uint8(want[2])))
+			*px[0], *px[1], *px[2] = r, g, b
+			got = [3]int{int(r), int(g), int(b)}
+			for i := range want {
+				e := want[i] - got[i]
+				cur[x+2][i] += 7 * e
+				next[x][i] += 3 * e
+				next[x+1][i] += 5 * e
+				next[x+2][i] += e
+			}
+		}
+		cur, next = next, cur
+		for i := range next {
+			next[i] = [3]int{}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ansimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/bits"
+)
+
+// Rasterize returns frame as it looks in a terminal: every ANSI-pixel shown by
+// RenderExt becomes a scale×scale square, or a scale×2·scale rectangle when
+// dithering (where an ANSI-pixel fills a whole cell). Dithered cells are drawn
+// in the color of their character blended over the background by brightness.
+func (ai *ANSImage) Rasterize(frame, scale int) *image.RGBA {
+	return ai.rasterize(frame, scale, nil)
+}
+
+// rasterize returns frame like Rasterize, passing every color through cf first
+// (nil keeps the colors unchanged).
+func (ai *ANSImage) rasterize(frame, scale int, cf ColorFunc) *image.RGBA {
+	cellH := scale
+	if ai.dithering != NoDithering {
+		cellH = 2 * scale
+	}
+
+	height := 0
+	for row := 0; row < ai.Rows(); row++ {
+		height += len(ai.PixelRows(row)) * cellH
+	}
+	out := image.NewRGBA(image.Rect(0, 0, ai.w*scale, height))
+	py := 0
+	for row := 0; row < ai.Rows(); row++ {
+		for _, y := range ai.PixelRows(row) {
+			for x := 0; x < ai.w; x++ {
+				rect := image.Rect(x*scale, py, (x+1)*scale, py+cellH)
+				c := ai.frame[frame][y][x].shownColor()
+				c.R, c.G, c.B = applyColorFunc(cf, c.R, c.G, c.B)
+				draw.Draw(out, rect, image.NewUniform(c), image.ZP, draw.Src)
+			}
+			py += cellH
+		}
+	}
+	return out
+}
+
+// shownColor returns the color a terminal shows for the ANSI-pixel.
+func (ap *ANSIpixel) shownColor() color.RGBA {
+	if ap.source.dithering == NoDithering {
+		return color.RGBA{ap.R, ap.G, ap.B, 255}
+	}
+	coverage := int(ap.Brightness)
+	switch ap.source.dithering {
+	case DitheringWithBraille:
+		coverage = 255 * bits.OnesCount8(ap.dots) / 8
+	case DitheringWithQuadrants:
+		coverage = 255 * bits.OnesCount8(ap.dots) / 4
+	case DitheringWithSextants:
+		coverage = 255 * bits.OnesCount8(ap.dots) / 6
+	}
+	mix := func(fg, bg uint8) uint8 {
+		return uint8((int(fg)*coverage + int(bg)*(255-coverage)) / 255)
+	}
+	return color.RGBA{mix(ap.R, ap.bgR), mix(ap.G, ap.bgG), mix(ap.B, ap.bgB), 255}
+}
@@ -0,0 +1,86 @@
+package ansimage
+
+import (
+	"sort"
+	"sync"
+)
+
+// RendererOptions are the settings a RendererFactory applies to the Renderer it makes.
+type RendererOptions struct {
+	// DisableBgColor leaves the background color unset in dithering mode.
+	DisableBgColor bool
+
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+
+	// ColorDithering selects how renderers with a limited palette approximate colors.
+	ColorDithering ColorDithering
+}
+
+// RendererFactory makes a Renderer with the given options.
+type RendererFactory func(opts RendererOptions) Renderer
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]RendererFactory{
+		"truecolor": func(opts RendererOptions) Renderer {
+			return TrueColorRenderer{DisableBgColor: opts.DisableBgColor, ColorFunc: opts.ColorFunc}
+		},
+		"xterm256": func(opts RendererOptions) Renderer {
+			return TrueColorRenderer{DisableBgColor: opts.DisableBgColor, ColorFunc: opts.ColorFunc,
+				ColorMode: Color256, ColorDithering: opts.ColorDithering}
+		},
+		"ansi16": func(opts RendererOptions) Renderer {
+			return TrueColorRenderer{DisableBgColor: opts.DisableBgColor, ColorFunc: opts.ColorFunc,
+				ColorMode: Color16, ColorDithering: opts.ColorDithering}
+		},
+		"mono": func(opts RendererOptions) Renderer {
+			return TrueColorRenderer{DisableBgColor: opts.DisableBgColor, ColorFunc: opts.ColorFunc,
+				ColorMode: Mono, ColorDithering: opts.ColorDithering}
+		},
+		"gray4": func(opts RendererOptions) Renderer {
+			return TrueColorRenderer{DisableBgColor: opts.DisableBgColor, ColorFunc: opts.ColorFunc,
+				ColorMode: Gray4, ColorDithering: opts.ColorDithering}
+		},
+		"kitty": func(opts RendererOptions) Renderer {
+			return KittyRenderer{ColorFunc: opts.ColorFunc}
+		},
+		"sixel": func(opts RendererOptions) Renderer {
+			return SixelRenderer{ColorFunc: opts.ColorFunc}
+		},
+		"iterm2": func(opts RendererOptions) Renderer {
+			return ITerm2Renderer{ColorFunc: opts.ColorFunc}
+		},
+	}
+)
+
+// RegisterRenderer makes a Renderer available by name, so that programs can
+// select third-party output formats at runtime (e.g. from a query parameter).
+// Registering an existing name replaces its factory.
+func RegisterRenderer(name string, factory RendererFactory) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	renderers[name] = factory
+}
+
+// LookupRenderer returns the factory registered as name, if any.
+// The built-in renderers are "truecolor", "xterm256", "ansi16", "mono", "gray4",
+// "kitty", "sixel" and "iterm2".
+func LookupRenderer(name string) (RendererFactory, bool) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	factory, ok := renderers[name]
+	return factory, ok
+}
+
+// RendererNames returns the registered renderer names, sorted.
+func RendererNames() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,337 @@
+package ansimage
+
+import (
+	"bufio"
+	"io"
+	"time"
+)
+
+// Renderer turns the ANSI-pixels of an ANSImage into output for a terminal
+// (or any other display), so new output formats can be added without
+// changing ANSImage. Rows are terminal rows, see ANSImage.Rows.
+type Renderer interface {
+	// RenderPixel returns the output for a single ANSI-pixel.
+	RenderPixel(ap *ANSIpixel) string
+
+	// RenderRow writes a terminal row of frame to w, including the line ending.
+	RenderRow(w io.Writer, ai *ANSImage, frame, row int) error
+
+	// RenderFrame writes a whole frame to w.
+	RenderFrame(w io.Writer, ai *ANSImage, frame int) error
+}
+
+// TrueColorRenderer is the default Renderer, writing SGR sequences (24-bit
+// unless ColorMode says otherwise) with the renderer matching the dithering
+// mode of the ANSImage (see ModeRenderer).
+type TrueColorRenderer struct {
+	// DisableBgColor leaves the background color unset in dithering mode.
+	DisableBgColor bool
+
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+
+	// ColorMode selects the color sequences, true color by default.
+	ColorMode ColorMode
+
+	// ColorDithering selects how a limited ColorMode approximates colors in
+	// RenderFrame (rows are always drawn with the nearest colors).
+	ColorDithering ColorDithering
+}
+
+// ModeRenderer returns the Renderer used for an ANSImage in dithering mode dm.
+func (tr TrueColorRenderer) ModeRenderer(dm DitheringMode) Renderer {
+	switch dm {
+	case NoDithering:
+		return HalfBlockRenderer{ColorFunc: tr.ColorFunc, ColorMode: tr.ColorMode}
+	case DitheringWithBlocks:
+		return ShadeBlockRenderer{DisableBgColor: tr.DisableBgColor, ColorFunc: tr.ColorFunc, ColorMode: tr.ColorMode}
+	case DitheringWithChars:
+		return CharRenderer{DisableBgColor: tr.DisableBgColor, ColorFunc: tr.ColorFunc, ColorMode: tr.ColorMode}
+	case DitheringWithBraille:
+		return BrailleRenderer{DisableBgColor: tr.DisableBgColor, ColorFunc: tr.ColorFunc, ColorMode: tr.ColorMode}
+	case DitheringWithQuadrants:
+		return QuadrantRenderer{DisableBgColor: tr.DisableBgColor, ColorFunc: tr.ColorFunc, ColorMode: tr.ColorMode}
+	case DitheringWithSextants:
+		return SextantRenderer{DisableBgColor: tr.DisableBgColor, ColorFunc: tr.ColorFunc, ColorMode: tr.ColorMode}
+	case DitheringWithEdges:
+		return EdgeRenderer{DisableBgColor: tr.DisableBgColor, ColorFunc: tr.ColorFunc, ColorMode: tr.ColorMode}
+	}
+	panic(errUnknownDitheringMode)
+}
+
+// RenderPixel returns the ANSI-compatible string form of ap.
+func (tr TrueColorRenderer) RenderPixel(ap *ANSIpixel) string {
+	return tr.ModeRenderer(ap.source.dithering).RenderPixel(ap)
+}
+
+// RenderRow writes the ANSI-pixels of a terminal row, then resets the style.
+func (tr TrueColorRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	return tr.ModeRenderer(ai.dithering).RenderRow(w, ai, frame, row)
+}
+
+// RenderFrame writes all the terminal rows of frame, through a buffer.
+func (tr TrueColorRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	if tr.ColorMode != TrueColor && tr.ColorDithering != NearestColor {
+		q := ai.quantized(frame, tr.ColorMode, tr.ColorDithering, tr.ColorFunc)
+		tr.ColorFunc, tr.ColorDithering = nil, NearestColor // already applied
+		return tr.ModeRenderer(ai.dithering).RenderFrame(w, q, 0)
+	}
+	return tr.ModeRenderer(ai.dithering).RenderFrame(w, ai, frame)
+}
+
+// HalfBlockRenderer renders ANSImages without dithering: each terminal cell
+// shows an upper pixel as background color and a lower one as a half block.
+type HalfBlockRenderer struct {
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+
+	// ColorMode selects the color sequences, true color by default.
+	ColorMode ColorMode
+}
+
+// RenderPixel returns the background color sequence of an upper pixel, or the
+// foreground color sequence and half block of a lower one. A transparent
+// upper pixel sets the default background, and a transparent lower one
+// draws a space: RenderCell draws transparent pixels better, as a pair.
+func (hr HalfBlockRenderer) RenderPixel(ap *ANSIpixel) string {
+	r, g, b := applyColorFunc(hr.ColorFunc, ap.R, ap.G, ap.B)
+	switch {
+	case ap.upper && ap.transparent:
+		return defaultBackground
+	case ap.upper:
+		return hr.ColorMode.sgr(true, r, g, b)
+	case ap.transparent:
+		return " "
+	}
+	return hr.ColorMode.sgr(false, r, g, b) + ap.glyph(lowerHalfBlock)
+}
+
+// RenderCell returns the terminal cell showing the pixel pair upper and
+// lower. A cell with both pixels transparent is skipped with a cursor
+// movement, leaving what the terminal shows there; a single transparent pixel
+// shows the default background of the terminal.
+func (hr HalfBlockRenderer) RenderCell(upper, lower *ANSIpixel) string {
+	switch {
+	case upper.transparent && lower.transparent:
+		return cursorForward
+	case upper.transparent:
+		return defaultBackground + hr.RenderPixel(lower)
+	case lower.transparent:
+		r, g, b := applyColorFunc(hr.ColorFunc, upper.R, upper.G, upper.B)
+		return defaultBackground + hr.ColorMode.sgr(false, r, g, b) + upperHalfBlock
+	}
+	return hr.RenderPixel(upper) + hr.RenderPixel(lower)
+}
+
+// RenderRow writes the pixel pairs of a terminal row, then resets the style.
+func (hr HalfBlockRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	pixelRows := ai.PixelRows(row)
+	for x := 0; x < ai.w; x++ {
+		cell := hr.RenderCell(ai.frame[frame][pixelRows[0]][x], ai.frame[frame][pixelRows[1]][x])
+		if _, err := io.WriteString(w, cell); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\033[0m\n") // reset ansi style
+	return err
+}
+
+// RenderFrame writes all the terminal rows of frame, through a buffer.
+func (hr HalfBlockRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	return renderFrame(w, ai, frame, hr)
+}
+
+// ShadeBlockRenderer renders dithered ANSImages with full and shade blocks
+// chosen by brightness.
+type ShadeBlockRenderer struct {
+	// DisableBgColor leaves the background color unset.
+	DisableBgColor bool
+
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+
+	// ColorMode selects the color sequences, true color by default.
+	ColorMode ColorMode
+}
+
+// RenderPixel returns the color sequences and block of ap.
+func (sr ShadeBlockRenderer) RenderPixel(ap *ANSIpixel) string {
+	return ditheredCell(ap, shadeBlock(ap.Brightness), sr.DisableBgColor, sr.ColorFunc, sr.ColorMode)
+}
+
+// shadeBlock returns the full or shade block drawing brightness bri.
+func shadeBlock(bri uint8) string {
+	switch {
+	case bri > 204:
+		return fullBlock
+	case bri > 152:
+		return darkShadeBlock
+	case bri > 100:
+		return mediumShadeBlock
+	case bri > 48:
+		return lightShadeBlock
+	}
+	return " "
+}
+
+// RenderRow writes the cells of a terminal row, then resets the style.
+func (sr ShadeBlockRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	return renderRow(w, ai, frame, row, sr.RenderPixel)
+}
+
+// RenderFrame writes all the terminal rows of frame, through a buffer.
+func (sr ShadeBlockRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	return renderFrame(w, ai, frame, sr)
+}
+
+// CharRenderer renders dithered ANSImages with ASCII characters chosen by brightness.
+type CharRenderer struct {
+	// DisableBgColor leaves the background color unset.
+	DisableBgColor bool
+
+	// Ramp, if not empty, replaces the characters of the ANSImage (see
+	// ANSImage.SetCharRamp), from the darkest to the brightest.
+	Ramp []rune
+
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+
+	// ColorMode selects the color sequences, true color by default.
+	ColorMode ColorMode
+}
+
+// RenderPixel returns the color sequences and character of ap.
+func (cr CharRenderer) RenderPixel(ap *ANSIpixel) string {
+	return ditheredCell(ap, rampChar(ap, cr.Ramp), cr.DisableBgColor, cr.ColorFunc, cr.ColorMode)
+}
+
+// rampChar returns the character of ramp drawing the brightness of ap, or of
+// the ramp of its ANSImage if ramp is empty, or of the default ramp.
+func rampChar(ap *ANSIpixel, ramp []rune) string {
+	if len(ramp) == 0 {
+		ramp = ap.source.charRamp
+	}
+	if len(ramp) > 0 {
+		return string(ramp[int(ap.Brightness)*len(ramp)/256])
+	}
+
+	switch bri := ap.Brightness; {
+	case bri > 230:
+		return "#"
+	case bri > 207:
+		return "&"
+	case bri > 184:
+		return "$"
+	case bri > 161:
+		return "X"
+	case bri > 138:
+		return "x"
+	case bri > 115:
+		return "="
+	case bri > 92:
+		return "+"
+	case bri > 69:
+		return ";"
+	case bri > 46:
+		return ":"
+	case bri > 23:
+		return "."
+	}
+	return " "
+}
+
+// RenderRow writes the cells of a terminal row, then resets the style.
+func (cr CharRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	return renderRow(w, ai, frame, row, cr.RenderPixel)
+}
+
+// RenderFrame writes all the terminal rows of frame, through a buffer.
+func (cr CharRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	return renderFrame(w, ai, frame, cr)
+}
+
+// applyColorFunc returns the color r, g, b passed through cf, if not nil.
+func applyColorFunc(cf ColorFunc, r, g, b uint8) (uint8, uint8, uint8) {
+	if cf != nil {
+		return cf(r, g, b)
+	}
+	return r, g, b
+}
+
+// ditheredCell returns the color sequences of a dithered ANSI-pixel followed
+// by block, or by its overlay text.
+func ditheredCell(ap *ANSIpixel, block string, disableBgColor bool, cf ColorFunc, cm ColorMode) string {
+	if ap.transparent {
+		return cursorForward
+	}
+	r, g, b := applyColorFunc(cf, ap.R, ap.G, ap.B)
+	bgColorStr := ""
+	switch {
+	case disableBgColor:
+	case ap.clearBg:
+		bgColorStr = defaultBackground
+	default:
+		bgR, bgG, bgB := applyColorFunc(cf, ap.bgR, ap.bgG, ap.bgB)
+		bgColorStr = cm.sgr(true, bgR, bgG, bgB)
+	}
+	return bgColorStr + cm.sgr(false, r, g, b) + ap.glyph(block)
+}
+
+// renderRow writes the ANSI-pixels of a terminal row rendered by pixel,
+// then resets the style.
+func renderRow(w io.Writer, ai *ANSImage, frame, row int, pixel func(ap *ANSIpixel) string) error {
+	pixelRows := ai.PixelRows(row)
+	for x := 0; x < ai.w; x++ {
+		for _, y := range pixelRows {
+			if _, err := io.WriteString(w, pixel(ai.frame[frame][y][x])); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, "\033[0m\n") // reset ansi style
+	return err
+}
+
+// renderFrame writes all the terminal rows of frame rendered by r, through a buffer.
+func renderFrame(w io.Writer, ai *ANSImage, frame int, r Renderer) error {
+	bw := bufio.NewWriter(w)
+	for row := 0; row < ai.Rows(); row++ {
+		if err := r.RenderRow(bw, ai, frame, row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Rows gets the number of terminal rows a frame is rendered in.
+func (ai *ANSImage) Rows() int {
+	n := ai.h - 1 // the last row is left out, as in RenderExt
+	if ai.dithering == NoDithering {
+		n = (ai.h - 2) / 2 // the first pixel pair too
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// PixelRows gets the rows of ANSI-pixels drawn in a terminal row: the upper
+// and lower pixel rows without dithering, a single one with dithering.
+func (ai *ANSImage) PixelRows(row int) []int {
+	if ai.dithering == NoDithering {
+		return []int{2*row + 2, 2*row + 3}
+	}
+	return []int{row}
+}
+
+// RenderWith writes frame to w using the Renderer r.
+func (ai *ANSImage) RenderWith(frame int, w io.Writer, r Renderer) error {
+	if ai.renderHook == nil {
+		return r.RenderFrame(w, ai, frame)
+	}
+	start := time.Now()
+	cw := &countingWriter{w: w}
+	err := r.RenderFrame(cw, ai, frame)
+	ai.observe(frame, cw.n, start)
+	return err
+}
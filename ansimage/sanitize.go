@@ -0,0 +1,67 @@
+package ansimage
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SanitizeText strips escape sequences and control characters from text
+// provided by users (names, captions, labels) before it is written to a
+// terminal, so it can't move the cursor, change colors, retitle the window
+// or worse. Newlines are kept, tabs become spaces, and invalid UTF-8 is dropped.
+func SanitizeText(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\033':
+			size = escapeLength(text[i:])
+		case r == '\n':
+			b.WriteByte('\n')
+		case r == '\t':
+			b.WriteByte(' ')
+		case r == utf8.RuneError && size == 1, unicode.IsControl(r):
+			// C0 and C1 controls, DEL, invalid bytes
+		default:
+			b.WriteString(text[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// escapeLength returns the length of the escape sequence s starts with: a CSI
+// sequence, a control string (OSC, DCS, APC, PM or SOS) up to its terminator,
+// or a two-byte escape. Unterminated sequences run to the end of s.
+func escapeLength(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(s)
+	case ']', 'P', '_', '^', 'X':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\033' {
+				if i+1 < len(s) && s[i+1] == '\\' {
+					return i + 2
+				}
+				return i // an escape aborts the string
+			}
+		}
+		return len(s)
+	}
+	if s[1] >= 0x20 && s[1] <= 0x7e {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,102 @@
+package ansimage
+
+import (
+	"errors"
+	"image"
+	"sort"
+	"sync"
+
+	"github.com/disintegration/imaging"
+)
+
+// Scaler is a scale strategy of the New*Scaled* constructors: it returns img
+// (a composited GIF frame) scaled to rows×cols ANSI-pixels. Pixels left
+// transparent show the background color.
+type Scaler interface {
+	Scale(img image.Image, rows, cols int) image.Image
+}
+
+// ScalerFunc adapts a function to the Scaler interface.
+type ScalerFunc func(img image.Image, rows, cols int) image.Image
+
+// Scale calls f(img, rows, cols).
+func (f ScalerFunc) Scale(img image.Image, rows, cols int) image.Image {
+	return f(img, rows, cols)
+}
+
+// errTooManyScaleModes occurs when no ScaleMode is left for a new Scaler.
+var errTooManyScaleModes = errors.New("ANSImage: too many scale modes")
+
+var (
+	scalersMu sync.RWMutex
+	scalers   = map[ScaleMode]Scaler{
+		ScaleModeResize: ScalerFunc(func(img image.Image, rows, cols int) image.Image {
+			return imaging.Resize(img, cols, rows, imaging.Lanczos)
+		}),
+		ScaleModeFill: ScalerFunc(func(img image.Image, rows, cols int) image.Image {
+			return imaging.Fill(img, cols, rows, imaging.Center, imaging.Lanczos)
+		}),
+		ScaleModeFit: ScalerFunc(func(img image.Image, rows, cols int) image.Image {
+			return imaging.Fit(img, cols, rows, imaging.Lanczos)
+		}),
+		ScaleModeLetterbox: ScalerFunc(func(img image.Image, rows, cols int) image.Image {
+			area := image.NewNRGBA(image.Rect(0, 0, cols, rows)) // transparent bars show the background
+			return imaging.PasteCenter(area, imaging.Fit(img, cols, rows, imaging.Lanczos))
+		}),
+	}
+	scaleModes = map[string]ScaleMode{
+		"resize":    ScaleModeResize,
+		"fill":      ScaleModeFill,
+		"fit":       ScaleModeFit,
+		"letterbox": ScaleModeLetterbox,
+	}
+)
+
+// RegisterScaler makes a scale strategy available by name, so that programs
+// can add their own (e.g. seam carving or tiling) and select it at runtime.
+// It returns the ScaleMode to pass to the New*Scaled* constructors, which can
+// be combined with AutoCrop like the built-in modes. Registering an existing
+// name replaces its Scaler and keeps its ScaleMode.
+func RegisterScaler(name string, s Scaler) ScaleMode {
+	scalersMu.Lock()
+	defer scalersMu.Unlock()
+	sm, ok := scaleModes[name]
+	if !ok {
+		sm = ScaleMode(len(scalers))
+		if sm >= AutoCrop {
+			panic(errTooManyScaleModes)
+		}
+		scaleModes[name] = sm
+	}
+	scalers[sm] = s
+	return sm
+}
+
+// LookupScaleMode returns the ScaleMode registered as name, if any.
+// The built-in modes are "resize", "fill", "fit" and "letterbox".
+func LookupScaleMode(name string) (ScaleMode, bool) {
+	scalersMu.RLock()
+	defer scalersMu.RUnlock()
+	sm, ok := scaleModes[name]
+	return sm, ok
+}
+
+// ScaleModeNames returns the registered scale mode names, sorted.
+func ScaleModeNames() []string {
+	scalersMu.RLock()
+	defer scalersMu.RUnlock()
+	names := make([]string, 0, len(scaleModes))
+	for name := range scaleModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scaler returns the Scaler of scale mode sm, if any.
+func scaler(sm ScaleMode) (Scaler, bool) {
+	scalersMu.RLock()
+	defer scalersMu.RUnlock()
+	s, ok := scalers[sm]
+	return s, ok
+}
@@ -0,0 +1,57 @@
+package ansimage
+
+import "io"
+
+// sextantBlock returns the character showing the sextants in mask (bit 0:
+// upper left, 1: upper right, 2: middle left, 3: middle right, 4: lower left,
+// 5: lower right). The Symbols for Legacy Computing block (U+1FB00) holds
+// every pattern but the ones already in Block Elements.
+func sextantBlock(mask uint8) string {
+	switch mask &= 0x3f; mask {
+	case 0:
+		return " "
+	case 0x15:
+		return "▌" // left half block
+	case 0x2a:
+		return "▐" // right half block
+	case 0x3f:
+		return fullBlock
+	}
+	code := 0x1fb00 + int(mask) - 1
+	if mask > 0x2a {
+		code -= 2
+	} else if mask > 0x15 {
+		code--
+	}
+	return string(rune(code))
+}
+
+// SextantRenderer renders ANSImages dithered with sextants: every terminal
+// cell shows its 3x2 block with the sextant characters of Unicode 13, in the
+// two colors that best match the block.
+type SextantRenderer struct {
+	// DisableBgColor leaves the background color unset, so only the
+	// foreground sextants are drawn.
+	DisableBgColor bool
+
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+
+	// ColorMode selects the color sequences, true color by default.
+	ColorMode ColorMode
+}
+
+// RenderPixel returns the color sequences and sextant character of ap.
+func (sr SextantRenderer) RenderPixel(ap *ANSIpixel) string {
+	return ditheredCell(ap, sextantBlock(ap.dots), sr.DisableBgColor, sr.ColorFunc, sr.ColorMode)
+}
+
+// RenderRow writes the cells of a terminal row, then resets the style.
+func (sr SextantRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	return renderRow(w, ai, frame, row, sr.RenderPixel)
+}
+
+// RenderFrame writes all the terminal rows of frame, through a buffer.
+func (sr SextantRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	return renderFrame(w, ai, frame, sr)
+}
@@ -0,0 +1,40 @@
+package ansimage
+
+import "testing"
+
+func TestSextantBlock(t *testing.T) {
+	tests := []struct {
+		mask uint8
+		want string
+	}{
+		{0x00, " "},
+		{0x15, "▌"},
+		{0x2a, "▐"},
+		{0x3f, fullBlock},
+		{0x01, "\U0001FB00"},
+		{0x02, "\U0001FB01"},
+		{0x14, "\U0001FB13"},
+		{0x16, "\U0001FB14"},
+		{0x29, "\U0001FB27"},
+		{0x2b, "\U0001FB28"},
+		{0x3e, "\U0001FB3B"},
+		{0x40, " "},
+		{0xff, fullBlock},
+	}
+	for _, tt := range tests {
+		if got := sextantBlock(tt.mask); got != tt.want {
+			t.Errorf("sextantBlock(%#x) = %q, want %q", tt.mask, got, tt.want)
+		}
+	}
+}
+
+func TestSextantBlockDistinct(t *testing.T) {
+	seen := map[string]uint8{}
+	for mask := uint8(0); mask < 64; mask++ {
+		block := sextantBlock(mask)
+		if other, ok := seen[block]; ok {
+			t.Errorf("sextantBlock(%#x) = sextantBlock(%#x) = %q", mask, other, block)
+		}
+		seen[block] = mask
+	}
+}
@@ -0,0 +1,111 @@
+package ansimage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// DEFAULT_SIXEL_SCALE is the SixelRenderer scale used when none is set,
+// making half block cells 8×16 pixels, close to common terminal fonts.
+const DEFAULT_SIXEL_SCALE = 8
+
+// SixelRenderer is a Renderer writing frames as DEC sixel graphics (xterm -ti
+// vt340, mlterm, foot, WezTerm...), quantized to a 6×6×6 color cube.
+type SixelRenderer struct {
+	// Scale is the size in pixels of an ANSI-pixel, see ANSImage.Rasterize
+	// (0 for DEFAULT_SIXEL_SCALE).
+	Scale int
+
+	// ColorFunc, if not nil, adjusts every color before it is written.
+	ColorFunc ColorFunc
+}
+
+// RenderPixel is not supported: pixels are only sent as part of a frame.
+func (sr SixelRenderer) RenderPixel(ap *ANSIpixel) string {
+	return ""
+}
+
+// RenderRow is not supported: sixel images are sent whole.
+func (sr SixelRenderer) RenderRow(w io.Writer, ai *ANSImage, frame, row int) error {
+	return ErrUnsupported
+}
+
+// RenderFrame writes frame as a sixel image at the cursor.
+func (sr SixelRenderer) RenderFrame(w io.Writer, ai *ANSImage, frame int) error {
+	scale := sr.Scale
+	if scale <= 0 {
+		scale = DEFAULT_SIXEL_SCALE
+	}
+	img := ai.rasterize(frame, scale, sr.ColorFunc)
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// color cube index of every pixel
+	level := func(v uint8) int {
+		return (int(v)*5 + 127) / 255
+	}
+	index := make([]uint8, width*height)
+	for i := range index {
+		p := img.Pix[4*i : 4*i+3 : 4*i+3]
+		index[i] = uint8(36*level(p[0]) + 6*level(p[1]) + level(p[2]))
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\033Pq\"1;1;%d;%d", width, height) // 1:1 pixel aspect ratio
+	for c := 0; c < 216; c++ {
+		fmt.Fprintf(bw, "#%d;2;%d;%d;%d", c, c/36*20, c/6%6*20, c%6*20) // RGB in percents
+	}
+
+	sixels := make([]byte, width)
+	for band := 0; band < height; band += 6 {
+		var used [216]bool
+		for y := band; y < band+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				used[index[y*width+x]] = true
+			}
+		}
+
+		first := true
+		for c := range used {
+			if !used[c] {
+				continue
+			}
+			for x := range sixels {
+				var bits byte
+				for k := 0; k < 6 && band+k < height; k++ {
+					if int(index[(band+k)*width+x]) == c {
+						bits |= 1 << uint(k)
+					}
+				}
+				sixels[x] = '?' + bits
+			}
+			if !first {
+				bw.WriteByte('$') // back to the start of the band
+			}
+			first = false
+			fmt.Fprintf(bw, "#%d", c)
+			writeSixelRun(bw, sixels)
+		}
+		bw.WriteByte('-') // next band
+	}
+	bw.WriteString("\033\\")
+	return bw.Flush()
+}
+
+// writeSixelRun writes sixel characters, compressing repeats.
+func writeSixelRun(bw *bufio.Writer, sixels []byte) {
+	for i := 0; i < len(sixels); {
+		j := i
+		for j < len(sixels) && sixels[j] == sixels[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(bw, "!%d%c", n, sixels[i])
+		} else {
+			for ; i < j; i++ {
+				bw.WriteByte(sixels[i])
+			}
+		}
+		i = j
+	}
+}
@@ -0,0 +1,47 @@
+package ansimage
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/draw"
+	_ "image/jpeg" // initialize decoder
+	_ "image/png"  // initialize decoder
+
+	_ "golang.org/x/image/webp" // initialize decoder
+)
+
+// isGIF reports whether the data buffered in r starts like a GIF file.
+func isGIF(r *bufio.Reader) bool {
+	magic, _ := r.Peek(4)
+	return bytes.Equal(magic, []byte("GIF8"))
+}
+
+// loadStill creates a new ANSImage of a single frame from a static image
+// (PNG, JPEG, WebP...) with cfg. Its loop count is -1, so players show it
+// once instead of redrawing it forever. AutoCrop doesn't apply to stills.
+func loadStill(img image.Image, cfg *loadConfig) (*ANSImage, error) {
+	b := img.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), img, b.Min, draw.Src)
+
+	var frame image.Image = canvas
+	if cfg.filtered() {
+		frame = cfg.prepareFrame(canvas)
+	}
+	frame = cfg.transformFrame(frame)
+	if cfg.sizeY > 0 {
+		scale, ok := scaler(cfg.scaleMode &^ AutoCrop)
+		if !ok {
+			panic(errUnknownScaleMode)
+		}
+		frame = cfg.scaleFrame(scale, frame)
+	}
+
+	proxy := gifProxy{
+		image:     []image.Image{frame},
+		delay:     []int{BrowserDefaultDelay},
+		loopCount: -1,
+	}
+	return cfg.apply(createANSImage(&proxy, cfg.bg, cfg.dithering, cfg))
+}
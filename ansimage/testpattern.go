@@ -0,0 +1,138 @@
+package ansimage
+
+import (
+	"errors"
+	"image/color"
+)
+
+// ANSImage test patterns:
+// color bars (SMPTE-style, static),
+// gradient (red, green, blue and gray ramps, static),
+// checkerboard (moving one cell per frame).
+const (
+	TestPatternColorBars = TestPatternKind(iota)
+	TestPatternGradient
+	TestPatternCheckerboard
+)
+
+// errUnknownTestPattern occurs when test pattern kind is invalid.
+var errUnknownTestPattern = errors.New("ANSImage: unknown test pattern")
+
+// TestPatternKind type is used for test pattern constants.
+type TestPatternKind uint8
+
+// checkerboard animation settings
+const (
+	checkerSize   = 4  // square size in terminal columns
+	checkerDelay  = 10 // frame delay, in 100ths of a second
+	checkerFrames = 2 * checkerSize
+)
+
+// NewTestPattern creates an ANSImage test pattern filling rows×cols terminal cells,
+// to calibrate terminal colors or smoke-test a deployment without image files.
+func NewTestPattern(rows, cols int, kind TestPatternKind) (*ANSImage, error) {
+	var pixel func(frame, y, x, h, w int) color.RGBA
+	frameCount, delay := 1, 0
+
+	switch kind {
+	case TestPatternColorBars:
+		pixel = colorBarsPixel
+	case TestPatternGradient:
+		pixel = gradientPixel
+	case TestPatternCheckerboard:
+		pixel = checkerboardPixel
+		frameCount, delay = checkerFrames, checkerDelay
+	default:
+		return nil, errUnknownTestPattern
+	}
+
+	h, w := 2*rows, cols // half blocks: two pixels per cell
+	ansimage, err := New(h, w, frameCount, color.Black, NoDithering)
+	if err != nil {
+		return nil, err
+	}
+
+	for frame := 0; frame < frameCount; frame++ {
+		ansimage.delay[frame] = delay
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := pixel(frame, y, x, h, w)
+				if err := ansimage.SetAt(frame, y, x, c.R, c.G, c.B, 0); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return ansimage, nil
+}
+
+// colorBarsPixel draws SMPTE-style color bars: 75% bars, a reversed
+// castellation strip, then -I, white, +Q, black and the PLUGE.
+func colorBarsPixel(frame, y, x, h, w int) color.RGBA {
+	bars := [7]color.RGBA{
+		{191, 191, 191, 255}, // gray
+		{191, 191, 0, 255},   // yellow
+		{0, 191, 191, 255},   // cyan
+		{0, 191, 0, 255},     // green
+		{191, 0, 191, 255},   // magenta
+		{191, 0, 0, 255},     // red
+		{0, 0, 191, 255},     // blue
+	}
+	black := color.RGBA{19, 19, 19, 255}
+	bar := x * 7 / w
+
+	switch {
+	case y < h*2/3:
+		return bars[bar]
+	case y < h*3/4:
+		if bar%2 == 1 {
+			return black
+		}
+		return bars[6-bar]
+	}
+
+	// lower third split in sixths of the width
+	switch sixth := x * 6 / w; {
+	case sixth == 0:
+		return color.RGBA{0, 33, 76, 255} // -I
+	case sixth == 1:
+		return color.RGBA{255, 255, 255, 255}
+	case sixth == 2:
+		return color.RGBA{50, 0, 106, 255} // +Q
+	case sixth == 3:
+		return black
+	default:
+		// PLUGE: below black, black, above black
+		switch third := (x - 4*w/6) * 3 / (w - 4*w/6); third {
+		case 0:
+			return color.RGBA{9, 9, 9, 255}
+		case 2:
+			return color.RGBA{29, 29, 29, 255}
+		}
+		return black
+	}
+}
+
+// gradientPixel draws horizontal red, green, blue and gray ramps in four bands.
+func gradientPixel(frame, y, x, h, w int) color.RGBA {
+	v := uint8(x * 255 / (w - 1))
+	switch y * 4 / h {
+	case 0:
+		return color.RGBA{v, 0, 0, 255}
+	case 1:
+		return color.RGBA{0, v, 0, 255}
+	case 2:
+		return color.RGBA{0, 0, v, 255}
+	}
+	return color.RGBA{v, v, v, 255}
+}
+
+// checkerboardPixel draws black and white squares, checkerSize columns by
+// checkerSize pixels (half as many rows), scrolling right one column per frame.
+func checkerboardPixel(frame, y, x, h, w int) color.RGBA {
+	if ((x+checkerFrames-frame)/checkerSize+y/checkerSize)%2 == 0 {
+		return color.RGBA{255, 255, 255, 255}
+	}
+	return color.RGBA{0, 0, 0, 255}
+}
@@ -0,0 +1,162 @@
+package ansimage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ManifestName is the file listing the frames of a pre-rendered animation directory.
+// Each line holds a frame file name and its delay in 100ths of a second:
+//
+//	frame000.txt 4
+//	frame001.ans 4
+//
+// Blank lines and lines starting with '#' are ignored.
+const ManifestName = "manifest.txt"
+
+// ErrEmptyManifest occurs when an animation manifest lists no frame.
+var ErrEmptyManifest = errors.New("ANSImage: manifest lists no frame")
+
+// Animation is implemented by ANSImage and TextAnimation, so that players can
+// stream decoded images and pre-rendered ANSI art alike.
+type Animation interface {
+	FrameCount() int
+	FrameDelay(frame int) int
+	Width() int
+	RenderFiltered(frame int, disableBgColor bool, cf ColorFunc) string
+	RenderFilteredTo(frame int, w io.Writer, disableBgColor bool, cf ColorFunc) error
+}
+
+// TextAnimation is an animation made of pre-rendered ANSI text frames,
+// such as hand-made .ans art or frames exported by ANSImage.SaveFrames.
+type TextAnimation struct {
+	w     int
+	frame []string
+	delay []int
+}
+
+// sgrPattern matches escape sequences, to measure the visible width of a row.
+var sgrPattern = regexp.MustCompile("\033\\[[0-9;?]*[A-Za-z]")
+
+// trueColorPattern matches 24-bit foreground/background SGR sequences.
+var trueColorPattern = regexp.MustCompile("\033\\[([34])8;2;([0-9]+);([0-9]+);([0-9]+)m")
+
+// LoadTextAnimation loads the frames listed in the ManifestName file of dir.
+// Frames that aren't valid UTF-8 (classic .ans files) are decoded as CP437,
+// and SAUCE metadata after the EOF character is dropped.
+func LoadTextAnimation(dir string) (*TextAnimation, error) {
+	manifest, err := ioutil.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+
+	ta := &TextAnimation{}
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("ANSImage: %s line %d: want \"file delay\"", ManifestName, n)
+		}
+		delay, err := strconv.Atoi(fields[1])
+		if err != nil || delay < 0 {
+			return nil, fmt.Errorf("ANSImage: %s line %d: invalid delay %q", ManifestName, n, fields[1])
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(fields[0])))
+		if err != nil {
+			return nil, err
+		}
+		ta.addFrame(decodeANSIText(data), delay)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ta.frame) == 0 {
+		return nil, ErrEmptyManifest
+	}
+	return ta, nil
+}
+
+// decodeANSIText converts a frame file to a UTF-8 string with "\n" line endings.
+func decodeANSIText(data []byte) string {
+	if i := bytes.IndexByte(data, 0x1a); i >= 0 {
+		data = data[:i] // SAUCE record follows the EOF character
+	}
+	if !utf8.Valid(data) {
+		if decoded, err := charmap.CodePage437.NewDecoder().Bytes(data); err == nil {
+			logf("LoadTextAnimation: frame is not UTF-8, decoded as CP437")
+			data = decoded
+		}
+	}
+	text := strings.Replace(string(data), "\r\n", "\n", -1)
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text
+}
+
+// addFrame appends a frame, widening the animation to its longest visible row.
+func (ta *TextAnimation) addFrame(text string, delay int) {
+	for _, row := range strings.Split(text, "\n") {
+		if w := utf8.RuneCountInString(sgrPattern.ReplaceAllString(row, "")); w > ta.w {
+			ta.w = w
+		}
+	}
+	ta.frame = append(ta.frame, text)
+	ta.delay = append(ta.delay, delay)
+}
+
+// FrameCount gets the animation frame count.
+func (ta *TextAnimation) FrameCount() int {
+	return len(ta.frame)
+}
+
+// FrameDelay gets the successive delay times for frame, in 100ths of a second.
+func (ta *TextAnimation) FrameDelay(frame int) int {
+	return ta.delay[frame]
+}
+
+// Width gets the visible columns of the widest row.
+func (ta *TextAnimation) Width() int {
+	return ta.w
+}
+
+// RenderFiltered returns the text of frame. Colors given as 24-bit SGR
+// sequences are passed through cf (nil returns the frame unchanged);
+// disableBgColor is ignored because the art carries its own colors.
+func (ta *TextAnimation) RenderFiltered(frame int, disableBgColor bool, cf ColorFunc) string {
+	if cf == nil {
+		return ta.frame[frame]
+	}
+	return trueColorPattern.ReplaceAllStringFunc(ta.frame[frame], func(sgr string) string {
+		m := trueColorPattern.FindStringSubmatch(sgr)
+		r, _ := strconv.Atoi(m[2])
+		g, _ := strconv.Atoi(m[3])
+		b, _ := strconv.Atoi(m[4])
+		nr, ng, nb := cf(uint8(r), uint8(g), uint8(b))
+		return fmt.Sprintf("\033[%s8;2;%d;%d;%dm", m[1], nr, ng, nb)
+	})
+}
+
+// RenderFilteredTo writes the text of frame to w, like RenderFiltered.
+func (ta *TextAnimation) RenderFilteredTo(frame int, w io.Writer, disableBgColor bool, cf ColorFunc) error {
+	_, err := io.WriteString(w, ta.RenderFiltered(frame, disableBgColor, cf))
+	return err
+}
@@ -0,0 +1,146 @@
+package ansimage
+
+import (
+	"errors"
+	"time"
+)
+
+// errInvalidFPS occurs when a frame rate is not positive.
+var errInvalidFPS = errors.New("ANSImage: frame rate must be positive")
+
+// Browsers show the frames of GIFs with a delay below BrowserMinDelay for
+// BrowserDefaultDelay instead (in 100ths of a second).
+const (
+	BrowserMinDelay     = 2
+	BrowserDefaultDelay = 10
+)
+
+// NormalizeDelays makes the ANSImage play at the speed people know from
+// browsers: frames with a delay below BrowserMinDelay (0 or 10 ms), which
+// would otherwise be shown as fast as possible, get BrowserDefaultDelay.
+// Delays still below minDelay (in 100ths of a second) are then raised to it;
+// a minDelay of 0 keeps them.
+func (ai *ANSImage) NormalizeDelays(minDelay int) {
+	changed := 0
+	for frame, delay := range ai.delay {
+		if delay < BrowserMinDelay {
+			delay = BrowserDefaultDelay
+		}
+		if delay < minDelay {
+			delay = minDelay
+		}
+		if delay != ai.delay[frame] {
+			ai.delay[frame] = delay
+			changed++
+		}
+	}
+	if changed > 0 {
+		logf("NormalizeDelays: lengthened the delay of %d of %d frames", changed, len(ai.delay))
+	}
+}
+
+// FitDuration proportionally rescales all frame delays so one loop of the
+// ANSImage lasts d (to the nearest 100th of a second). Rounding is spread
+// over the frames so the total is exact. An animation without delays gets
+// evenly spaced frames.
+func (ai *ANSImage) FitDuration(d time.Duration) {
+	if len(ai.delay) == 0 {
+		return
+	}
+	target := int((d + 5*time.Millisecond) / (10 * time.Millisecond))
+	if target < 0 {
+		target = 0
+	}
+
+	total := 0
+	for _, delay := range ai.delay {
+		total += delay
+	}
+
+	elapsed, prevEnd := 0, 0
+	for frame, delay := range ai.delay {
+		var end int
+		if total == 0 {
+			end = (frame + 1) * target / len(ai.delay)
+		} else {
+			elapsed += delay
+			end = int(float64(elapsed)*float64(target)/float64(total) + 0.5)
+		}
+		ai.delay[frame] = end - prevEnd
+		if ai.delay[frame] == 0 && delay > 0 {
+			logf("FitDuration: frame %d delay rounded down to zero", frame)
+		}
+		prevEnd = end
+	}
+}
+
+// Decimate drops frames so the ANSImage plays at no more than targetFPS frames
+// per second, reducing bandwidth for high frame rate GIFs. The delay of each
+// dropped frame is added to the frame kept before it, so playback speed and
+// loop duration are unchanged.
+func (ai *ANSImage) Decimate(targetFPS float64) error {
+	if targetFPS <= 0 {
+		return errInvalidFPS
+	}
+	period := 100 / targetFPS // in 100ths of a second
+
+	var frames []ANSIframe
+	var delays []int
+	shown := 0.0 // how long the last kept frame has been shown
+	for frame, delay := range ai.delay {
+		if len(frames) > 0 && shown < period {
+			delays[len(delays)-1] += delay
+			shown += float64(delay)
+			continue
+		}
+		frames = append(frames, ai.frame[frame])
+		delays = append(delays, delay)
+		shown = float64(delay)
+	}
+
+	if dropped := len(ai.frame) - len(frames); dropped > 0 {
+		logf("Decimate: dropped %d of %d frames to play at %g fps", dropped, len(ai.frame), targetFPS)
+	}
+	ai.frame, ai.delay = frames, delays
+	return nil
+}
+
+// Reversed returns a new ANSImage playing the frames of ai backwards.
+func (ai *ANSImage) Reversed() (*ANSImage, error) {
+	order := make([]int, len(ai.frame))
+	for i := range order {
+		order[i] = len(order) - 1 - i
+	}
+	return ai.reordered(order)
+}
+
+// Boomerang returns a new ANSImage playing the frames of ai forwards then
+// backwards. The first and last frames aren't repeated at the turns, so it loops smoothly.
+func (ai *ANSImage) Boomerang() (*ANSImage, error) {
+	var order []int
+	for i := range ai.frame {
+		order = append(order, i)
+	}
+	for i := len(ai.frame) - 2; i > 0; i-- {
+		order = append(order, i)
+	}
+	return ai.reordered(order)
+}
+
+// reordered returns a copy of ai made of its frames (and their delays) in order.
+func (ai *ANSImage) reordered(order []int) (*ANSImage, error) {
+	out, err := New(ai.h, ai.w, len(order), ai.background(), ai.dithering)
+	if err != nil {
+		return nil, err
+	}
+	out.maxprocs = ai.maxprocs
+	out.blockY, out.blockX = ai.blockY, ai.blockX
+	out.charRamp = ai.charRamp
+	out.loopCount = ai.loopCount
+	out.renderHook = ai.renderHook
+	for i, frame := range order {
+		copyFrame(out.frame[i], ai.frame[frame])
+		out.delay[i] = ai.delay[frame]
+	}
+	return out, nil
+}
@@ -0,0 +1,196 @@
+package ansimage
+
+import (
+	"errors"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// ANSImage geometric transforms, for images oriented wrong for a terminal:
+// rotate 90 (clockwise),
+// rotate 180,
+// rotate 270 (clockwise, i.e. 90 counter-clockwise),
+// flip horizontally (mirror left to right),
+// flip vertically (mirror top to bottom).
+const (
+	TransformRotate90 = Transform(iota)
+	TransformRotate180
+	TransformRotate270
+	TransformFlipH
+	TransformFlipV
+)
+
+// Transform type is used for geometric transform constants.
+type Transform uint8
+
+var (
+	// errUnknownTransform occurs when transform is invalid.
+	errUnknownTransform = errors.New("ANSImage: unknown transform")
+
+	// errTransformDithering occurs when rotating a dithered ANSImage by a
+	// quarter turn, as its cells aren't square: use WithTransform instead.
+	errTransformDithering = errors.New("ANSImage: dithered images can only be rotated by 90 or 270 degrees when loaded, see WithTransform")
+)
+
+// WithTransform rotates or flips every frame by ts, in order, before scaling,
+// so the image is fitted to the size set by WithSize as it is shown. Unlike
+// the ANSImage methods (see ANSImage.Rotate90), it works with any dithering mode.
+func WithTransform(ts ...Transform) Option {
+	return func(cfg *loadConfig) {
+		cfg.transforms = append([]Transform(nil), ts...)
+	}
+}
+
+// transformFrame returns img rotated or flipped by the transforms of cfg.
+func (cfg *loadConfig) transformFrame(img image.Image) image.Image {
+	for _, t := range cfg.transforms {
+		switch t {
+		case TransformRotate90:
+			img = imaging.Rotate270(img) // imaging rotates counter-clockwise
+		case TransformRotate180:
+			img = imaging.Rotate180(img)
+		case TransformRotate270:
+			img = imaging.Rotate90(img)
+		case TransformFlipH:
+			img = imaging.FlipH(img)
+		case TransformFlipV:
+			img = imaging.FlipV(img)
+		}
+	}
+	return img
+}
+
+// Rotate90 returns a new ANSImage with every frame of ai rotated clockwise by
+// 90 degrees. Without dithering, a row of background is added at the bottom
+// when the width of ai is odd, as the height must be even. Dithered images
+// return an error, their cells being twice as tall as wide: rotate them at
+// load time with WithTransform instead.
+func (ai *ANSImage) Rotate90() (*ANSImage, error) {
+	return ai.Transform(TransformRotate90)
+}
+
+// Rotate180 returns a new ANSImage with every frame of ai turned upside down.
+func (ai *ANSImage) Rotate180() (*ANSImage, error) {
+	return ai.Transform(TransformRotate180)
+}
+
+// Rotate270 returns a new ANSImage with every frame of ai rotated clockwise by
+// 270 degrees (90 counter-clockwise), see Rotate90.
+func (ai *ANSImage) Rotate270() (*ANSImage, error) {
+	return ai.Transform(TransformRotate270)
+}
+
+// FlipH returns a new ANSImage with every frame of ai mirrored left to right.
+func (ai *ANSImage) FlipH() (*ANSImage, error) {
+	return ai.Transform(TransformFlipH)
+}
+
+// FlipV returns a new ANSImage with every frame of ai mirrored top to bottom.
+func (ai *ANSImage) FlipV() (*ANSImage, error) {
+	return ai.Transform(TransformFlipV)
+}
+
+// Transform returns a new ANSImage with every frame of ai rotated or flipped
+// by t (see Rotate90). The dots of dithered cells (braille, quadrants,
+// sextants) and the slants of edges are mirrored with them. Overlay text is dropped, as it would read
+// backwards: stamp it after transforming.
+func (ai *ANSImage) Transform(t Transform) (*ANSImage, error) {
+	h, w := ai.h, ai.w
+	var src func(y, x int) (int, int) // pixel of ai at the position y, x of the result
+	var mirrorY, mirrorX bool         // how the dots of a cell are mirrored
+	switch t {
+	case TransformRotate90:
+		h, w = ai.w, ai.h
+		src = func(y, x int) (int, int) { return ai.h - 1 - x, y }
+	case TransformRotate180:
+		src = func(y, x int) (int, int) { return ai.h - 1 - y, ai.w - 1 - x }
+		mirrorY, mirrorX = true, true
+	case TransformRotate270:
+		h, w = ai.w, ai.h
+		src = func(y, x int) (int, int) { return x, ai.w - 1 - y }
+	case TransformFlipH:
+		src = func(y, x int) (int, int) { return y, ai.w - 1 - x }
+		mirrorX = true
+	case TransformFlipV:
+		src = func(y, x int) (int, int) { return ai.h - 1 - y, x }
+		mirrorY = true
+	default:
+		return nil, errUnknownTransform
+	}
+	if h != ai.h && ai.dithering != NoDithering {
+		return nil, errTransformDithering
+	}
+	if ai.dithering == NoDithering && h%2 != 0 {
+		h++ // padded with background
+	}
+
+	out, err := New(h, w, len(ai.frame), ai.background(), ai.dithering)
+	if err != nil {
+		return nil, err
+	}
+	out.maxprocs = ai.maxprocs
+	out.blockY, out.blockX = ai.blockY, ai.blockX
+	out.charRamp = ai.charRamp
+	out.loopCount = ai.loopCount
+	out.renderHook = ai.renderHook
+	copy(out.delay, ai.delay)
+	for i, frame := range out.frame {
+		for y, row := range frame {
+			for x, p := range row {
+				sy, sx := src(y, x)
+				if sy < 0 || sy >= ai.h || sx < 0 || sx >= ai.w {
+					p.R, p.G, p.B = p.bgR, p.bgG, p.bgB
+					continue
+				}
+				s := ai.frame[i][sy][sx]
+				p.R, p.G, p.B = s.R, s.G, s.B
+				p.bgR, p.bgG, p.bgB = s.bgR, s.bgG, s.bgB
+				p.Brightness = s.Brightness
+				p.dots = mirrorDots(s.dots, ai.dithering, mirrorY, mirrorX)
+				p.transparent = s.transparent
+				p.clearBg = s.clearBg
+			}
+		}
+	}
+	return out, nil
+}
+
+// mirrorDots returns the dots of a cell of dithering mode dm mirrored top to
+// bottom and/or left to right. Modes without dots keep them.
+func mirrorDots(dots uint8, dm DitheringMode, mirrorY, mirrorX bool) uint8 {
+	var bit func(y, x int) uint8
+	switch dm {
+	case DitheringWithBraille:
+		bit = func(y, x int) uint8 { return brailleDots[y][x] }
+	case DitheringWithQuadrants, DitheringWithSextants:
+		_, blockX := BlockSize(dm)
+		bit = func(y, x int) uint8 { return 1 << uint(y*blockX+x) }
+	case DitheringWithEdges:
+		if mirrorY != mirrorX && (dots == 2 || dots == 4) {
+			return 6 - dots // / and \ swap
+		}
+		return dots
+	default:
+		return dots
+	}
+
+	blockY, blockX := BlockSize(dm)
+	var out uint8
+	for y := 0; y < blockY; y++ {
+		for x := 0; x < blockX; x++ {
+			if dots&bit(y, x) == 0 {
+				continue
+			}
+			ny, nx := y, x
+			if mirrorY {
+				ny = blockY - 1 - y
+			}
+			if mirrorX {
+				nx = blockX - 1 - x
+			}
+			out |= bit(ny, nx)
+		}
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"giflive/ansimage"
+	"io"
+	"time"
+)
+
+// byteCounter is an io.Writer discarding its input, counting the bytes.
+type byteCounter int64
+
+func (bc *byteCounter) Write(p []byte) (int, error) {
+	*bc += byteCounter(len(p))
+	return len(p), nil
+}
+
+// runBench renders a synthetic animation (the checkerboard test pattern) as
+// fast as possible with every registered output format, for d each, at the
+// default and at the largest terminal size, and reports the throughput to w.
+func runBench(w io.Writer, d time.Duration) error {
+	ro := defaultRenderOptions()
+	sizes := [][2]int{{ro.rows, ro.cols}, {MAX_ROWS, MAX_COLS}}
+
+	for _, size := range sizes {
+		image, err := ansimage.NewTestPattern(size[0], size[1], ansimage.TestPatternCheckerboard)
+		if err != nil {
+			return err
+		}
+		for _, name := range ansimage.RendererNames() {
+			factory, _ := ansimage.LookupRenderer(name)
+			renderer := factory(ansimage.RendererOptions{})
+
+			var bytes byteCounter
+			frames := 0
+			start := time.Now()
+			for time.Since(start) < d {
+				if err := image.RenderWith(frames%image.FrameCount(), &bytes, renderer); err != nil {
+					return fmt.Errorf("%s: %s", name, err)
+				}
+				frames++
+			}
+			seconds := time.Since(start).Seconds()
+			fmt.Fprintf(w, "%-8s %-10s %9.1f frames/s %8.2f MB/s\n", fmt.Sprintf("%dx%d", size[1], size[0]), name,
+				float64(frames)/seconds, float64(bytes)/seconds/1e6)
+		}
+	}
+	return nil
+}
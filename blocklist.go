@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// blocklist holds the client networks refused by every frontend, loaded from
+// the -blocklist file.
+var blocklist = &ipBlocklist{}
+
+// ipBlocklist is a list of networks read from a file, one address or CIDR per
+// line (blank lines and # comments are skipped). The file is read again when
+// it changes, so entries can be added without restarting the server.
+type ipBlocklist struct {
+	mu       sync.Mutex
+	filename string
+	modTime  time.Time
+	nets     netList
+}
+
+// load sets the file of b and reads it.
+func (b *ipBlocklist) load(filename string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.filename = filename
+	return b.reload()
+}
+
+// reload reads the file of b if it changed since the last read. On error, the
+// previous list is kept. b.mu must be held.
+func (b *ipBlocklist) reload() error {
+	info, err := os.Stat(b.filename)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(b.modTime) {
+		return nil
+	}
+
+	f, err := os.Open(b.filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var nets netList
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if err := nets.Set(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	b.nets, b.modTime = nets, info.ModTime()
+	return nil
+}
+
+// blocked reports whether the client address addr (an IP, with or without a
+// port) is on the blocklist.
+func (b *ipBlocklist) blocked(addr string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.filename == "" {
+		return false
+	}
+	if err := b.reload(); err != nil {
+		log.Printf("Blocklist %s not reloaded: %s\n", b.filename, err)
+	}
+
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && b.nets.contains(ip)
+}
+
+// blocklistMiddleware refuses HTTP requests from blocked clients.
+func blocklistMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if blocklist.blocked(c.RealIP()) {
+			return c.String(http.StatusForbidden, "Access denied.\n")
+		}
+		return next(c)
+	}
+}
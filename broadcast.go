@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"giflive/ansimage"
+	"giflive/player"
+	"log"
+	"sync"
+	"time"
+)
+
+// broadcastMode makes every stream join the broadcast of its animation, so
+// the frames are rendered once for all the viewers instead of once per viewer.
+var broadcastMode bool
+
+// errBroadcastStopped occurs when a broadcast ends while viewers still watch it.
+var errBroadcastStopped = errors.New("broadcast stopped")
+
+// BROADCAST_BUFFER_FRAMES is the number of frames queued for each viewer of a
+// broadcast. A viewer too slow to keep up loses the oldest ones.
+const BROADCAST_BUFFER_FRAMES = 8
+
+// queuedFrame is a frame of a broadcast, with its delay.
+type queuedFrame struct {
+	data  []byte
+	delay time.Duration
+}
+
+// frameRing is a bounded queue of frames for a broadcast viewer, dropping the
+// oldest frame when full so that pushing never blocks the broadcast.
+type frameRing struct {
+	mu      sync.Mutex
+	frames  []queuedFrame
+	head, n int
+	dropped int
+
+	ready chan struct{} // signalled after a push
+}
+
+func newFrameRing(size int) *frameRing {
+	return &frameRing{frames: make([]queuedFrame, size), ready: make(chan struct{}, 1)}
+}
+
+// push queues frame, dropping the oldest queued frame if the ring is full.
+func (r *frameRing) push(frame queuedFrame) {
+	r.mu.Lock()
+	if r.n == len(r.frames) {
+		r.frames[r.head] = queuedFrame{}
+		r.head = (r.head + 1) % len(r.frames)
+		r.n--
+		r.dropped++
+	}
+	r.frames[(r.head+r.n)%len(r.frames)] = frame
+	r.n++
+	r.mu.Unlock()
+
+	select {
+	case r.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes and returns the oldest queued frame, if any.
+func (r *frameRing) pop() (queuedFrame, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.n == 0 {
+		return queuedFrame{}, false
+	}
+	frame := r.frames[r.head]
+	r.frames[r.head] = queuedFrame{}
+	r.head = (r.head + 1) % len(r.frames)
+	r.n--
+	return frame, true
+}
+
+// broadcast plays an animation once and fans its frames out to the subscribers.
+// It's the player.Transport of the player: every frame is copied once into an
+// immutable slice, which every subscriber then sends over its own transport.
+type broadcast struct {
+	mu   sync.Mutex
+	subs map[*frameRing]bool
+
+	stop context.CancelFunc
+	done chan struct{} // closed once the player returns
+}
+
+// WriteFrame queues a frame for the subscribers. It never waits for them, so
+// a slow subscriber can't hold up the others.
+func (b *broadcast) WriteFrame(p []byte, delay time.Duration) error {
+	frame := queuedFrame{append([]byte(nil), p...), delay} // shared by the subscribers, never modified
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		sub.push(frame)
+	}
+	return nil
+}
+
+// Close does nothing: the subscribers close their own transports.
+func (b *broadcast) Close(reason string) error {
+	return nil
+}
+
+// broadcastHub keeps the running broadcasts, one per animation.
+type broadcastHub struct {
+	mu    sync.Mutex
+	casts map[ansimage.Animation]*broadcast
+}
+
+// broadcasts is shared by every frontend, like animations.
+var broadcasts = &broadcastHub{casts: make(map[ansimage.Animation]*broadcast)}
+
+// play subscribes to the broadcast of image, starting it with opts if it
+// isn't running, and sends its frames to t until ctx is cancelled, a write
+// fails or the broadcast stops. The broadcast stops with its last viewer.
+func (h *broadcastHub) play(ctx context.Context, t player.Transport, image ansimage.Animation, opts playOptions) error {
+	sub := newFrameRing(BROADCAST_BUFFER_FRAMES)
+
+	h.mu.Lock()
+	b, ok := h.casts[image]
+	if !ok {
+		b = h.start(image, opts)
+	}
+	b.mu.Lock()
+	b.subs[sub] = true
+	b.mu.Unlock()
+	h.mu.Unlock()
+	defer h.leave(image, b, sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.done:
+			// the last frames were queued before the broadcast stopped
+			for frame, ok := sub.pop(); ok; frame, ok = sub.pop() {
+				if err := t.WriteFrame(frame.data, frame.delay); err != nil {
+					return err
+				}
+			}
+			return errBroadcastStopped
+		case <-sub.ready:
+			for frame, ok := sub.pop(); ok; frame, ok = sub.pop() {
+				if err := t.WriteFrame(frame.data, frame.delay); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// start runs a new broadcast of image played with opts. h.mu must be held.
+func (h *broadcastHub) start(image ansimage.Animation, opts playOptions) *broadcast {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &broadcast{
+		subs: make(map[*frameRing]bool),
+		stop: cancel,
+		done: make(chan struct{}),
+	}
+	h.casts[image] = b
+
+	opts.broadcast = false
+	go func() {
+		defer close(b.done)
+		if sharedCache != nil && opts.name != "" {
+			seek, err := joinClusterBroadcast(ctx, clusterChannel(opts.name, image))
+			if err != nil {
+				log.Printf("Broadcast of %s not synchronized: %s", opts.name, err)
+			}
+			opts.seek = seek
+		}
+		if err := play(ctx, b, image, opts); err != nil && err != context.Canceled {
+			log.Printf("Broadcast stopped: %s", err)
+		}
+		h.mu.Lock()
+		if h.casts[image] == b {
+			delete(h.casts, image)
+		}
+		h.mu.Unlock()
+	}()
+	return b
+}
+
+// leave unsubscribes sub from b, stopping b if it was the last subscriber.
+func (h *broadcastHub) leave(image ansimage.Animation, b *broadcast, sub *frameRing) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b.mu.Lock()
+	delete(b.subs, sub)
+	empty := len(b.subs) == 0
+	b.mu.Unlock()
+	if sub.dropped > 0 {
+		log.Printf("Broadcast viewer fell behind, %d frames dropped", sub.dropped)
+	}
+	if empty {
+		b.stop()
+		if h.casts[image] == b {
+			delete(h.casts, image)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"giflive/ansimage"
+	"strings"
+	"time"
+)
+
+// Burn-in protection for terminals driving always-on kiosk displays.
+const (
+	BURNIN_SHIFT_INTERVAL = time.Minute      // how often the image moves by a cell
+	BURNIN_DIM_AFTER      = 10 * time.Minute // how long until colours are dimmed
+	BURNIN_DIM_LEVEL      = 0.6              // colour intensity once dimmed
+)
+
+// burnInOrbit lists the column offsets the image cycles through, one per BURNIN_SHIFT_INTERVAL.
+var burnInOrbit = []int{0, 1, 2, 1}
+
+// burnInShift returns the column offset for a stream that has been playing for elapsed.
+func burnInShift(elapsed time.Duration) int {
+	return burnInOrbit[int(elapsed/BURNIN_SHIFT_INTERVAL)%len(burnInOrbit)]
+}
+
+// burnInDim returns the colour filter for a stream that has been playing for
+// elapsed, or nil while colours are still at full intensity.
+func burnInDim(elapsed time.Duration) ansimage.ColorFunc {
+	if elapsed < BURNIN_DIM_AFTER {
+		return nil
+	}
+	return func(r, g, b uint8) (uint8, uint8, uint8) {
+		return uint8(float64(r) * BURNIN_DIM_LEVEL),
+			uint8(float64(g) * BURNIN_DIM_LEVEL),
+			uint8(float64(b) * BURNIN_DIM_LEVEL)
+	}
+}
+
+// shiftRows moves every row of a rendered frame right by cols cells.
+func shiftRows(render string, cols int) string {
+	if cols <= 0 {
+		return render // "\033[0C" still moves one cell on most terminals
+	}
+	cursorForward := fmt.Sprintf("\033[%dC", cols)
+	rows := strings.SplitAfter(render, "\n")
+	for i, row := range rows {
+		if row != "" {
+			rows[i] = cursorForward + row
+		}
+	}
+	return strings.Join(rows, "")
+}
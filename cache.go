@@ -0,0 +1,562 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// errGIFNotFound occurs when a requested GIF name does not map to a file in GIF_DIR.
+var errGIFNotFound = errors.New("GIF image not found")
+
+// minFrameDelay is the shortest time a GIF frame is shown, on top of the
+// delays normalized like in browsers (see ansimage.ANSImage.NormalizeDelays).
+var minFrameDelay time.Duration
+
+// maxFPS caps the frame rate of loaded GIFs to save bandwidth (0 means no limit).
+var maxFPS float64
+
+// autoCrop trims letterboxing from GIFs before scaling them.
+var autoCrop bool
+
+// serveNearest makes requests for an uncached variant of a GIF play the
+// nearest cached variant right away, while a background worker loads the
+// requested one for the next viewers.
+var serveNearest bool
+
+// cacheBudget is the memory, in bytes, the cached variants of GIFs at other
+// than the default render options may take; the least recently played are
+// dropped beyond it, as every client can ask for its own size and style.
+var cacheBudget int64 = 256 << 20
+
+// PIXEL_MEMORY is the approximate memory taken by an ANSI-pixel of a frame:
+// the pixel and its pointer in the frame row.
+const PIXEL_MEMORY = 56
+
+// PREGEN_QUEUE_SIZE is the number of variants waiting for the background
+// worker; requests beyond it load their variant themselves.
+const PREGEN_QUEUE_SIZE = 64
+
+// animationCache keeps decoded animations so concurrent viewers of the same GIF
+// share a single copy, whichever frontend they connected through.
+type animationCache struct {
+	mu      sync.Mutex
+	images  map[cacheKey]ansimage.Animation
+	used    map[cacheKey]uint64 // when each variant was last played, on clock
+	clock   uint64
+	pending map[cacheKey]bool // queued for the background worker
+	lanes   map[string]*gifLane
+	queue   chan pregenJob
+	worker  sync.Once
+}
+
+// pregenJob is a variant of the GIF named name to load in the background.
+type pregenJob struct {
+	name string
+	key  cacheKey
+}
+
+// cacheKey identifies an animation decoded from a file with given render options.
+// The modification time and size of the file (of the files of a pre-rendered
+// frames directory, see framesStamp) and of its route settings are part of
+// the key, so replacing either on disk makes the next viewers load it again.
+type cacheKey struct {
+	filename  string
+	modTime   int64 // in nanoseconds since the Unix epoch
+	size      int64
+	routeTime int64 // of the route settings file, 0 without one
+	routeSize int64
+	render    renderOptions
+}
+
+// sameFiles reports whether k and other were loaded from the same versions of
+// the same files, whatever their render options.
+func (k cacheKey) sameFiles(other cacheKey) bool {
+	return k.filename == other.filename && k.modTime == other.modTime && k.size == other.size &&
+		k.routeTime == other.routeTime && k.routeSize == other.routeSize
+}
+
+func newAnimationCache() *animationCache {
+	return &animationCache{
+		images:  make(map[cacheKey]ansimage.Animation),
+		used:    make(map[cacheKey]uint64),
+		pending: make(map[cacheKey]bool),
+		lanes:   make(map[string]*gifLane),
+		queue:   make(chan pregenJob, PREGEN_QUEUE_SIZE),
+	}
+}
+
+// Get returns the animation named name decoded with ro, loading it on first use
+// (and again once the file is replaced) from either a GIF file or a directory
+// of pre-rendered ANSI frames. Under memory pressure, ro is degraded.
+func (c *animationCache) Get(name string, ro renderOptions) (ansimage.Animation, error) {
+	if underMemoryPressure() {
+		ro = ro.degraded()
+	}
+
+	filename := animationPath(name)
+	if filename == "" && isQuarantined(name) {
+		return nil, errGIFBroken
+	} else if filename == "" {
+		return nil, errGIFNotFound
+	}
+
+	key := cacheKey{filename: filename, render: ro}
+	if isImageFile(filename) {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := refused(filename, info); err != nil {
+			return nil, err
+		}
+		key.modTime, key.size = info.ModTime().UnixNano(), info.Size()
+		if key.routeTime, key.routeSize, err = routeStamp(name); err != nil {
+			return nil, err
+		}
+	} else {
+		key.render = renderOptions{} // pre-rendered frames are played as is
+		var err error
+		if key.modTime, key.size, err = framesStamp(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	image, ok := c.images[key]
+	if ok {
+		c.touch(key)
+	}
+	if !ok && serveNearest {
+		if nearest := c.nearest(key); nearest != nil && c.enqueue(name, key) {
+			image, ok = nearest, true
+		}
+	}
+	c.mu.Unlock()
+	if ok {
+		return image, nil
+	}
+	return c.load(name, key)
+}
+
+// load decodes the variant key of the animation named name and caches it.
+// Variants of an animation are loaded one at a time, in its lane.
+func (c *animationCache) load(name string, key cacheKey) (ansimage.Animation, error) {
+	lane := c.lane(name)
+	if err := lane.acquireLoad(); err != nil {
+		return nil, err
+	}
+	defer lane.releaseLoad()
+
+	// a request waiting in the lane before may have loaded it already
+	c.mu.Lock()
+	image, ok := c.images[key]
+	c.mu.Unlock()
+	if ok {
+		return image, nil
+	}
+
+	var err error
+	if isImageFile(key.filename) {
+		image, err = loadSharedGIF(name, key.filename, key.render)
+	} else {
+		image, err = ansimage.LoadTextAnimation(key.filename)
+	}
+	if err != nil && isImageFile(key.filename) {
+		if verr := revalidate(key.filename); verr != nil {
+			return nil, verr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	for k := range c.images {
+		if k.filename == key.filename && !k.sameFiles(key) {
+			c.drop(k) // renders of a replaced file or route, at any size
+		}
+	}
+	c.images[key] = image
+	c.touch(key)
+	c.trim()
+	c.mu.Unlock()
+	return image, nil
+}
+
+// framesStamp returns the latest modification time and the total size of
+// the files of the pre-rendered frames directory dir (the manifest and the
+// frames), so editing any of them makes the next viewers load it again.
+func framesStamp(dir string) (int64, int64, error) {
+	if _, err := os.Stat(filepath.Join(dir, ansimage.ManifestName)); err != nil {
+		return 0, 0, err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	var modTime, size int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if t := entry.ModTime().UnixNano(); t > modTime {
+			modTime = t
+		}
+		size += entry.Size()
+	}
+	return modTime, size, nil
+}
+
+// touch marks the variant key as just played. c.mu must be held.
+func (c *animationCache) touch(key cacheKey) {
+	c.clock++
+	c.used[key] = c.clock
+}
+
+// drop removes the variant key from the cache. c.mu must be held.
+func (c *animationCache) drop(key cacheKey) {
+	delete(c.images, key)
+	delete(c.used, key)
+}
+
+// trim drops the least recently played variants other than the default
+// variant of each file (and pre-rendered frames) until they fit in
+// cacheBudget. c.mu must be held.
+func (c *animationCache) trim() {
+	def := defaultRenderOptions()
+	for {
+		var total int64
+		var oldest cacheKey
+		found := false
+		for k, image := range c.images {
+			if k.render == def || k.render == (renderOptions{}) {
+				continue
+			}
+			total += animationMemory(image)
+			if !found || c.used[k] < c.used[oldest] {
+				oldest, found = k, true
+			}
+		}
+		if !found || total <= cacheBudget {
+			return
+		}
+		c.drop(oldest)
+	}
+}
+
+// animationMemory returns the approximate memory taken by a decoded animation.
+func animationMemory(a ansimage.Animation) int64 {
+	ai, ok := a.(*ansimage.ANSImage)
+	if !ok {
+		return 0 // pre-rendered frames aren't variants
+	}
+	return int64(ai.Height()) * int64(ai.Width()) * int64(ai.FrameCount()) * PIXEL_MEMORY
+}
+
+// nearest returns the cached variant of the same file closest to key, if any,
+// preferring the same dithering and scale modes. c.mu must be held.
+func (c *animationCache) nearest(key cacheKey) ansimage.Animation {
+	var best ansimage.Animation
+	bestScore := -1
+	for k, image := range c.images {
+		if !k.sameFiles(key) {
+			continue
+		}
+		score := abs(k.render.rows-key.render.rows) + abs(k.render.cols-key.render.cols)
+		if k.render.dithering != key.render.dithering {
+			score += 2 * (MAX_ROWS + MAX_COLS)
+		}
+		if k.render.light != key.render.light {
+			score += 2 * (MAX_ROWS + MAX_COLS)
+		}
+		if k.render.scaleMode != key.render.scaleMode {
+			score += MAX_ROWS + MAX_COLS
+		}
+		if bestScore < 0 || score < bestScore {
+			best, bestScore = image, score
+		}
+	}
+	return best
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// enqueue queues the variant key of the animation named name for the
+// background worker, reporting false if the queue is full. c.mu must be held.
+func (c *animationCache) enqueue(name string, key cacheKey) bool {
+	if c.pending[key] {
+		return true
+	}
+	select {
+	case c.queue <- pregenJob{name, key}:
+	default:
+		return false
+	}
+	c.pending[key] = true
+	c.worker.Do(func() { go c.pregenerate() })
+	return true
+}
+
+// pregenerate loads the queued variants one after another, so the work of
+// filling the cache doesn't compete with streaming.
+func (c *animationCache) pregenerate() {
+	for job := range c.queue {
+		if _, err := c.load(job.name, job.key); err != nil {
+			log.Printf("Pre-generating %s: %s", job.name, err)
+		}
+		c.mu.Lock()
+		delete(c.pending, job.key)
+		c.mu.Unlock()
+	}
+}
+
+// loadGIF decodes the GIF file filename with ro, and applies the background,
+// inset, caption, credits and logo configured for the GIF named name.
+func loadGIF(name, filename string, ro renderOptions) (*ansimage.ANSImage, error) {
+	cfg, err := loadRouteConfig(name)
+	if err != nil {
+		return nil, err
+	}
+
+	scaleMode := ro.scaleMode
+	var bg color.Color = BACKGROUND_COLOUR
+	if ro.light {
+		bg = LIGHT_BACKGROUND_COLOUR
+	}
+	if cfg.Background != nil {
+		// the gradient also fills the letterbox bars
+		if scaleMode == ansimage.ScaleModeFit {
+			scaleMode = ansimage.ScaleModeLetterbox
+		}
+		if bg, err = cfg.Background.gradient(); err != nil {
+			return nil, err
+		}
+	}
+	if autoCrop {
+		scaleMode |= ansimage.AutoCrop
+	}
+
+	var opts []ansimage.Option
+	if cfg.ChromaKey != nil {
+		key, err := parseHexColour(cfg.ChromaKey.Colour)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, ansimage.WithChromaKey(key, cfg.ChromaKey.Tolerance))
+	}
+	if cfg.Denoise > 0 {
+		opts = append(opts, ansimage.WithDenoise(cfg.Denoise))
+	}
+	if cfg.Smoothing > 0 {
+		opts = append(opts, ansimage.WithTemporalSmoothing(cfg.Smoothing))
+	}
+	if cfg.Chars != "" {
+		opts = append(opts, ansimage.WithCharRamp([]rune(cfg.Chars)))
+	} else if ro.light {
+		opts = append(opts, ansimage.WithCharRamp([]rune(LIGHT_CHAR_RAMP)))
+	}
+
+	if a := cfg.Adjust; a != nil {
+		opts = append(opts, ansimage.WithAdjustment(a.Gamma, a.Brightness, a.Contrast))
+	}
+	if cfg.BlockSize != nil {
+		opts = append(opts, ansimage.WithBlockSize(cfg.BlockSize[0], cfg.BlockSize[1]))
+	}
+	if cfg.Transparent {
+		opts = append(opts, ansimage.WithTransparency(), ansimage.WithAlphaThreshold(cfg.AlphaThreshold))
+	}
+	if len(cfg.Transform) > 0 {
+		ts := make([]ansimage.Transform, len(cfg.Transform))
+		for i, name := range cfg.Transform {
+			t, ok := transforms[name]
+			if !ok {
+				return nil, fmt.Errorf("Invalid transform %s", name)
+			}
+			ts[i] = t
+		}
+		opts = append(opts, ansimage.WithTransform(ts...))
+	}
+
+	rows, cols := ro.rows, ro.cols
+	if z := cfg.Zoom; z != nil && z.Factor > 1 {
+		rows, cols = int(float64(rows)*z.Factor), int(float64(cols)*z.Factor)
+	}
+	y, x := ansimage.ScaledSize(rows, cols, ro.dithering, opts...)
+	image, err := ansimage.NewScaledFromFile(
+		filename,
+		y,
+		x,
+		bg,
+		scaleMode,
+		ro.dithering,
+		opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if image, err = zoomView(cfg.Zoom, image, ro); err != nil {
+		return nil, err
+	}
+	pipeline, err := ansimage.ParsePipeline(cfg.Pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid pipeline: %s", err.Error())
+	}
+	if image, err = pipeline.Run(image); err != nil {
+		return nil, err
+	}
+
+	image.NormalizeDelays(int(minFrameDelay / (10 * time.Millisecond)))
+	if maxFPS > 0 {
+		if err := image.Decimate(maxFPS); err != nil {
+			return nil, err
+		}
+	}
+	if image, err = compositeInset(cfg.Inset, image, bg, ro); err != nil {
+		return nil, err
+	}
+	if err := stampCaption(cfg.Caption, image, ro.light); err != nil {
+		return nil, err
+	}
+	if image, err = appendCredits(cfg.Credits, image, bg, ro.light); err != nil {
+		return nil, err
+	}
+	if logo != nil {
+		layer, err := logo.layerOn(image, ro)
+		if err != nil {
+			return nil, err
+		}
+		if image, err = image.Flatten(layer); err != nil {
+			return nil, err
+		}
+	}
+	image.SetRenderHook(frameMetrics.observe)
+	return image, nil
+}
+
+// zoomView crops img, loaded at the size enlarged by the zoom configured by
+// cfg (if any), to the stream size around the zoom center.
+func zoomView(cfg *zoomConfig, img *ansimage.ANSImage, ro renderOptions) (*ansimage.ANSImage, error) {
+	if cfg == nil || cfg.Factor <= 1 {
+		return img, nil
+	}
+	center := [2]float64{0.5, 0.5}
+	if cfg.Center != nil {
+		center = *cfg.Center
+	}
+	h, w := ro.rows, ro.cols // in ANSI-pixels
+	if ro.dithering == ansimage.NoDithering {
+		h *= 2
+	}
+	// the window is kept inside the image
+	top := int(center[0]*float64(img.Height())) - h/2
+	if top > img.Height()-h {
+		top = img.Height() - h
+	}
+	if top < 0 {
+		top = 0
+	}
+	left := int(center[1]*float64(img.Width())) - w/2
+	if left > img.Width()-w {
+		left = img.Width() - w
+	}
+	if left < 0 {
+		left = 0
+	}
+	return img.Crop(image.Rect(left, top, left+w, top+h))
+}
+
+// compositeInset plays the inset GIF configured by cfg (if any) in the
+// top-right corner of image, below the widget row. The stream lasts as long
+// as the longer of both, the other one looping.
+func compositeInset(cfg *insetConfig, image *ansimage.ANSImage, bg color.Color, ro renderOptions) (*ansimage.ANSImage, error) {
+	if cfg == nil || cfg.GIF == "" || image.Rows() < 2 {
+		return image, nil
+	}
+	filename := gifPath(cfg.GIF)
+	if filename == "" {
+		return nil, fmt.Errorf("inset %s: %s", cfg.GIF, errGIFNotFound.Error())
+	}
+	mode, ok := blendModes[cfg.Blend]
+	if !ok {
+		return nil, fmt.Errorf("Invalid inset blend %s", cfg.Blend)
+	}
+	rows, cols := cfg.Rows, cfg.Cols
+	if rows <= 0 {
+		rows = ro.rows / 3
+	}
+	if cols <= 0 {
+		cols = ro.cols / 3
+	}
+
+	var opts []ansimage.Option
+	if ro.dithering != ansimage.NoDithering {
+		opts = append(opts, ansimage.WithBlockSize(image.BlockSize())) // cells of the same size
+	}
+	y, x := ansimage.ScaledSize(rows, cols, ro.dithering, opts...)
+	inset, err := ansimage.NewScaledFromFile(filename, y, x, bg, ansimage.ScaleModeFit, ro.dithering, opts...)
+	if err != nil {
+		return nil, err
+	}
+	inset.NormalizeDelays(int(minFrameDelay / (10 * time.Millisecond)))
+	return image.Composite(inset, image.PixelRows(1)[0], image.Width()-inset.Width(), mode)
+}
+
+// stampCaption writes caption (if any) on the bottom row of every frame of
+// image, from the left, in white over the image, or black on light backgrounds.
+func stampCaption(caption string, image *ansimage.ANSImage, light bool) error {
+	if caption == "" || image.Rows() == 0 {
+		return nil
+	}
+	var fg color.Color = color.White
+	if light {
+		fg = color.Black
+	}
+	for frame := 0; frame < image.FrameCount(); frame++ {
+		if err := image.OverlayText(frame, image.Rows()-1, 0, caption, fg, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DEFAULT_CREDITS_SPEED is the credits speed, in rows per second, when a route doesn't set one.
+const DEFAULT_CREDITS_SPEED = 4
+
+// appendCredits appends the scrolling credits configured by cfg (if any) to image,
+// in black on light backgrounds. Credits are drawn with half blocks, so
+// dithered images are left without them.
+func appendCredits(cfg *creditsConfig, image *ansimage.ANSImage, bg color.Color, light bool) (*ansimage.ANSImage, error) {
+	if cfg == nil || cfg.Text == "" || image.DitheringMode() != ansimage.NoDithering {
+		return image, nil
+	}
+	speed := cfg.Speed
+	if speed <= 0 {
+		speed = DEFAULT_CREDITS_SPEED
+	}
+
+	var fg color.Color = color.White
+	if light {
+		fg = color.Black
+	}
+	credits, err := ansimage.NewCredits(image.Height()/2, image.Width(),
+		cfg.Text, fg, bg, speed)
+	if err != nil {
+		return nil, err
+	}
+	return image.Append(credits)
+}
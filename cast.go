@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"giflive/ansimage"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIME_ASCIICAST is the media type of asciicast files.
+const MIME_ASCIICAST = "application/x-asciicast"
+
+// castHandler serves one loop of the GIF named by the request path as an
+// asciicast v2 file, for web pages embedding asciinema-player. The cols,
+// rows, dither, scale and theme query parameters apply as for streams.
+func castHandler(c echo.Context) error {
+	gifName := ansimage.SanitizeText(c.Param("GIFNAME"))
+
+	ro, err := parseRenderOptions(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error()+".\n")
+	}
+	image, err := animations.Get(gifName, ro)
+	if err == errGIFNotFound {
+		return c.String(http.StatusNotFound,
+			fmt.Sprintf("GIF image %s not found.\n", gifName))
+	} else if err == errGIFBroken {
+		return c.String(http.StatusGone,
+			fmt.Sprintf("GIF image %s is broken.\n", gifName))
+	} else if err == errGIFBusy {
+		return c.String(http.StatusServiceUnavailable,
+			fmt.Sprintf("GIF image %s is busy, please try again later.\n", gifName))
+	} else if err != nil {
+		return c.String(http.StatusInternalServerError,
+			fmt.Sprintf("GIF image load error: %s.\n", err.Error()))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, MIME_ASCIICAST)
+	c.Response().WriteHeader(http.StatusOK)
+	w := bufio.NewWriter(c.Response())
+	if err := writeAsciicast(w, image, gifName); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writeAsciicast writes one loop of image as an asciicast, titled title. The
+// frames are the ones streams send with the default settings, each one at the
+// time the delays of the previous ones add up to. A last empty event holds
+// the delay of the last frame, so players loop it on time.
+func writeAsciicast(w *bufio.Writer, image ansimage.Animation, title string) error {
+	timestamp := playbackEpoch
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	if err := writeAsciicastHeader(w, image, title, timestamp); err != nil {
+		return err
+	}
+
+	var at time.Duration
+	for frame := 0; frame < image.FrameCount(); frame++ {
+		data := []byte("\033[2J\033[H" + image.RenderFiltered(frame, false, nil) + "\n")
+		if _, err := w.Write(asciicastEvent(at, data)); err != nil {
+			return err
+		}
+		at += time.Duration(image.FrameDelay(frame)) * 10 * time.Millisecond
+	}
+	_, err := w.Write(asciicastEvent(at, nil))
+	return err
+}
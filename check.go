@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// streamNames returns the names of the GIFs and pre-rendered animations in GIF_DIR, sorted.
+func streamNames() ([]string, error) {
+	entries, err := ioutil.ReadDir(GIF_DIR)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			if framesPath(name) != "" {
+				names = append(names, name)
+			}
+		} else if isImageFile(name) {
+			// an image hidden by another one of the same name isn't streamable
+			base := strings.TrimSuffix(name, filepath.Ext(name))
+			if gifPath(base) == filepath.Join(GIF_DIR, name) {
+				names = append(names, base)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// runCheck loads every GIF and pre-rendered animation in GIF_DIR with its route
+// settings, renders its first frame at the default size, and reports the result
+// and timings of each to w. It returns the number of animations that failed.
+func runCheck(w io.Writer) (int, error) {
+	names, err := streamNames()
+	if err != nil {
+		return 0, err
+	}
+	ro := defaultRenderOptions()
+
+	failed := 0
+	for _, name := range names {
+		start := time.Now()
+		image, err := animations.Get(name, ro)
+		loaded := time.Now()
+		if err == nil {
+			err = image.RenderFilteredTo(0, ioutil.Discard, false, nil)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "FAIL %s: %s\n", name, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "ok   %s: %d frames, load %s, render %s\n", name, image.FrameCount(),
+			loaded.Sub(start).Round(time.Microsecond), time.Since(loaded).Round(time.Microsecond))
+	}
+	fmt.Fprintf(w, "%d checked, %d failed in %s\n", len(names), failed, GIF_DIR)
+	return failed, nil
+}
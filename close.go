@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"giflive/player"
+	"sync"
+	"time"
+)
+
+// SHUTDOWN_GRACE bounds how long a shutting down frontend waits for its
+// streams to say goodbye to their viewers.
+const SHUTDOWN_GRACE = 2 * time.Second
+
+// serverContextKey is the request context key holding the context of the HTTP
+// frontend, cancelled when the server shuts down.
+type serverContextKey struct{}
+
+// closeReason returns the line telling a viewer why the server ended their
+// stream with err (from playAnimation), or an empty string if the viewer left.
+// server is the context of the frontend.
+func closeReason(server context.Context, err error) string {
+	switch {
+	case server.Err() != nil && upgrades.isUpgraded():
+		return "The server is being upgraded. Please reconnect."
+	case server.Err() != nil:
+		return "The server is shutting down. Please come back later."
+	case err == errSessionKilled:
+		return "Disconnected by the server operator."
+	case err == errBroadcastStopped:
+		return "The broadcast has ended."
+	case isTimeout(err):
+		return "Disconnected: the connection was too slow to keep up."
+	}
+	return ""
+}
+
+// closeStream closes t after playAnimation returned err, telling the viewer
+// why if the server ended the stream.
+func closeStream(t player.Transport, server context.Context, err error) {
+	t.Close(closeReason(server, err))
+}
+
+// sessionGroup counts the sessions of a frontend, for its shutdown to wait
+// for them. No session starts once the shutdown began waiting.
+type sessionGroup struct {
+	mu      sync.Mutex
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// add counts a new session, or returns false if the frontend is shutting down.
+func (g *sessionGroup) add() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closing {
+		return false
+	}
+	g.wg.Add(1)
+	return true
+}
+
+// done marks a session counted by add as ended.
+func (g *sessionGroup) done() {
+	g.wg.Done()
+}
+
+// wait waits for the sessions to end, at most SHUTDOWN_GRACE.
+func (g *sessionGroup) wait() {
+	g.mu.Lock()
+	g.closing = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(SHUTDOWN_GRACE):
+	}
+}
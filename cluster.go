@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"giflive/ansimage"
+	"strconv"
+	"time"
+)
+
+// REDIS_BROADCAST_PREFIX prefixes the Redis keys of the start times of the
+// broadcasts shared by the servers.
+const REDIS_BROADCAST_PREFIX = "giflive:broadcast:"
+
+// BROADCAST_START_TTL is how long the start time of a broadcast is kept once
+// no server plays it anymore. Servers playing it refresh it.
+const BROADCAST_START_TTL = 5 * time.Minute
+
+// clusterChannel returns the name of the broadcast of image, the GIF named
+// name, across servers. Servers decode the same variant of a GIF to the same
+// size and frames.
+func clusterChannel(name string, image ansimage.Animation) string {
+	var loop int
+	for frame := 0; frame < image.FrameCount(); frame++ {
+		loop += image.FrameDelay(frame)
+	}
+	return fmt.Sprintf("%s:%d:%d:%d", name, image.Width(), image.FrameCount(), loop)
+}
+
+// joinClusterBroadcast returns how long ago the broadcast channel started on
+// the servers sharing sharedCache, starting it now if none plays it. A
+// broadcast seeking that far shows the same frames as on the other servers
+// at the same time, as long as their clocks are synchronized (NTP). The start
+// time is kept in Redis until ctx is cancelled.
+func joinClusterBroadcast(ctx context.Context, channel string) (time.Duration, error) {
+	key := REDIS_BROADCAST_PREFIX + channel
+	ttl := strconv.Itoa(int(BROADCAST_START_TTL / time.Second))
+	now := time.Now()
+	if _, err := sharedCache.do("SET", key, strconv.FormatInt(now.UnixNano(), 10), "NX", "EX", ttl); err != nil {
+		return 0, err
+	}
+	value, err := sharedCache.get(key)
+	if err != nil {
+		return 0, err
+	}
+	start, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	go func() {
+		ticker := time.NewTicker(BROADCAST_START_TTL / 5)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// set again rather than extended, in case Redis lost it
+				sharedCache.do("SET", key, value, "EX", ttl)
+			}
+		}
+	}()
+
+	if seek := now.Sub(time.Unix(0, start)); seek > 0 {
+		return seek, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"giflive/ansimage"
+	"giflive/player"
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MAX_RENDER_SIZE is the largest GIF file accepted by POST /render, in bytes.
+// It is rendered as it arrives rather than kept, so it may be larger than
+// MAX_UPLOAD_SIZE.
+const MAX_RENDER_SIZE = 64 << 20
+
+// RENDER_READ_TIMEOUT bounds how long POST /render waits for more of the GIF
+// once the next frame is due, and RENDER_MAX_TIME the whole upload, so stalled
+// or trickling uploads don't hold their connection forever.
+const (
+	RENDER_READ_TIMEOUT = 30 * time.Second
+	RENDER_MAX_TIME     = 30 * time.Minute
+)
+
+// errRenderTooLarge occurs when a GIF posted to /render goes over MAX_RENDER_SIZE.
+var errRenderTooLarge = fmt.Errorf("larger than %d bytes", MAX_RENDER_SIZE)
+
+// renderConn is the connection of a POST /render request, read and written
+// at once so frames are sent while the GIF is still being uploaded. HTTP/1
+// servers stop reading the body of requests once the response has started,
+// so HTTP/1 connections are taken over and answered by hand.
+type renderConn struct {
+	c      echo.Context
+	conn   net.Conn // taken over HTTP/1 connection, nil for HTTP/2
+	raw    net.Conn // client connection, whose read deadline is set
+	body   io.Reader
+	w      io.Writer
+	length *io.LimitedReader // what is left of MAX_RENDER_SIZE
+}
+
+// deadlineReader refreshes the read deadline of conn before every read, up
+// to end at most, so a stalled upload fails the read instead of blocking the
+// player forever.
+type deadlineReader struct {
+	r    io.Reader
+	conn net.Conn
+	end  time.Time
+}
+
+func (dr deadlineReader) Read(p []byte) (int, error) {
+	deadline := time.Now().Add(RENDER_READ_TIMEOUT)
+	if deadline.After(dr.end) {
+		deadline = dr.end
+	}
+	dr.conn.SetReadDeadline(deadline)
+	return dr.r.Read(p)
+}
+
+// takeOverRender returns the connection of c, ready to read the GIF.
+func takeOverRender(c echo.Context) (*renderConn, error) {
+	r := c.Request()
+	ctx := r.Context()
+	rc := &renderConn{c: c}
+	end := time.Now().Add(RENDER_MAX_TIME)
+	if r.ProtoMajor >= 2 {
+		rc.raw = ctx.Value(connContextKey{}).(net.Conn)
+		rc.length = &io.LimitedReader{R: r.Body, N: MAX_RENDER_SIZE}
+		rc.body = deadlineReader{rc.length, rc.raw, end}
+		rc.w = deadlineWriter{c.Response(), rc.raw, writeTimeout}
+		return rc, nil
+	}
+
+	conn, rw, err := c.Response().Hijack()
+	if err != nil {
+		return nil, err
+	}
+	rc.conn, rc.raw = conn, conn
+	rc.w = deadlineWriter{conn, conn, writeTimeout}
+
+	// the body of the request, as the server would have read it
+	var body io.Reader = io.LimitReader(rw.Reader, r.ContentLength)
+	if len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked" {
+		body = httputil.NewChunkedReader(rw.Reader)
+	}
+	rc.length = &io.LimitedReader{R: body, N: MAX_RENDER_SIZE}
+	rc.body = deadlineReader{rc.length, conn, end}
+	if strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+		if _, err := io.WriteString(rc.w, "HTTP/1.1 100 Continue\r\n\r\n"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// respond starts the response with status, the frames or message following.
+func (rc *renderConn) respond(status int) error {
+	if rc.conn == nil {
+		rc.c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+		rc.c.Response().WriteHeader(status)
+		return nil
+	}
+	_, err := fmt.Fprintf(rc.w, "HTTP/1.1 %d %s\r\n"+
+		"Content-Type: %s\r\n"+
+		"Connection: close\r\n\r\n", status, http.StatusText(status), echo.MIMETextPlainCharsetUTF8)
+	return err
+}
+
+// fail answers with status and message, before any frame is sent.
+func (rc *renderConn) fail(status int, message string) error {
+	if err := rc.respond(status); err != nil {
+		return err
+	}
+	_, err := io.WriteString(rc.w, message)
+	return err
+}
+
+// readError returns the error of the body behind err, a GIF decoding error.
+func (rc *renderConn) readError(err error) error {
+	if rc.length.N <= 0 && err == io.ErrUnexpectedEOF {
+		return errRenderTooLarge
+	}
+	return err
+}
+
+// Close ends the response; taken over connections are closed.
+func (rc *renderConn) Close() error {
+	if rc.conn == nil {
+		return rc.raw.SetReadDeadline(time.Time{})
+	}
+	return rc.conn.Close()
+}
+
+// renderUploadHandler plays the GIF posted as the request body once, as a
+// curl animation, while it is still being uploaded: every frame is shown as
+// soon as it is decoded, for its own delay, so huge files start playing at
+// once instead of after the whole upload. Nothing is kept.
+func renderUploadHandler(c echo.Context) error {
+	if _, err := uploadKeyName(c); err == errInvalidAPIKey {
+		return c.String(http.StatusUnauthorized, "Unauthorized.\n")
+	} else if err != nil {
+		return c.String(http.StatusInternalServerError,
+			fmt.Sprintf("Store error: %s.\n", err.Error()))
+	}
+
+	ro, err := parseRenderOptions(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error()+".\n")
+	}
+
+	rc, err := takeOverRender(c)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	stream, err := ansimage.NewGIFStream(rc.body)
+	if err != nil {
+		return rc.fail(http.StatusUnprocessableEntity,
+			fmt.Sprintf("GIF image rejected: not a GIF image: %s.\n", rc.readError(err).Error()))
+	}
+	if b := stream.Bounds(); int64(b.Dx())*int64(b.Dy()) > MAX_IMAGE_PIXELS {
+		return rc.fail(http.StatusUnprocessableEntity,
+			fmt.Sprintf("GIF image rejected: %s.\n", errImageTooLarge.Error()))
+	}
+	if err := rc.respond(http.StatusOK); err != nil {
+		return err
+	}
+
+	server := c.Request().Context().Value(serverContextKey{}).(context.Context)
+	t := player.StreamTransport{W: rc.w}
+	log.Printf("Client %s is rendering an upload\n", c.RealIP())
+	err = playUpload(server, t, stream, ro)
+	reason := closeReason(server, err)
+	if err != nil && reason == "" && !isTimeout(err) {
+		reason = fmt.Sprintf("GIF image decode error: %s.", rc.readError(err).Error())
+	}
+	t.Close(reason)
+	return nil
+}
+
+// playUpload writes the frames of stream to t as they are decoded, each
+// after the delay of the previous one, until the last frame or an error.
+func playUpload(server context.Context, t player.Transport, stream *ansimage.GIFStream, ro renderOptions) error {
+	var bg color.Color = BACKGROUND_COLOUR
+	var opts []ansimage.Option
+	if ro.light {
+		bg = LIGHT_BACKGROUND_COLOUR
+		opts = append(opts, ansimage.WithCharRamp([]rune(LIGHT_CHAR_RAMP)))
+	}
+	y, x := ansimage.ScaledSize(ro.rows, ro.cols, ro.dithering, opts...)
+	opts = append(opts, ansimage.WithSize(y, x), ansimage.WithScaleMode(ro.scaleMode))
+
+	next := time.Now()
+	for {
+		canvas, delay, err := stream.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		frame, err := ansimage.NewFromFrames([]image.Image{canvas}, []int{delay}, bg, ro.dithering, opts...)
+		if err != nil {
+			return err
+		}
+		frame.NormalizeDelays(int(minFrameDelay / (10 * time.Millisecond)))
+
+		var buf bytes.Buffer
+		buf.WriteString("\033[2J\033[H")
+		if err := frame.RenderFilteredTo(0, &buf, false, nil); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+
+		select {
+		case <-time.After(time.Until(next)):
+		case <-server.Done():
+			return server.Err()
+		}
+		frameDelay := time.Duration(frame.FrameDelay(0)) * 10 * time.Millisecond
+		if err := t.WriteFrame(buf.Bytes(), frameDelay); err != nil {
+			return err
+		}
+		next = time.Now().Add(frameDelay)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"giflive/ansimage"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DIFF_TOLERANCE is the difference per colour channel under which the cells
+// of /diff count as unchanged, so compression noise isn't highlighted.
+const DIFF_TOLERANCE = 8
+
+// diffHandler plays the differences between the GIFs named by the a and b
+// query parameters, e.g. /diff?a=cat&b=cat-v2: the cells of b that changed
+// from a are shown as they are, the others in dim gray.
+func diffHandler(c echo.Context) error {
+	names := [2]string{ansimage.SanitizeText(c.QueryParam("a")), ansimage.SanitizeText(c.QueryParam("b"))}
+
+	ro, err := parseRenderOptions(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error()+".\n")
+	}
+
+	var images [2]*ansimage.ANSImage
+	for i, gifName := range names {
+		animation, err := animations.Get(gifName, ro)
+		if err == errGIFNotFound {
+			return c.String(http.StatusNotFound,
+				fmt.Sprintf("GIF image %s not found.\n", gifName))
+		} else if err == errGIFBroken {
+			return c.String(http.StatusGone,
+				fmt.Sprintf("GIF image %s is broken.\n", gifName))
+		} else if err == errGIFBusy {
+			return c.String(http.StatusServiceUnavailable,
+				fmt.Sprintf("GIF image %s is busy, please try again later.\n", gifName))
+		} else if err != nil {
+			return c.String(http.StatusInternalServerError,
+				fmt.Sprintf("GIF image load error: %s.\n", err.Error()))
+		}
+		image, ok := animation.(*ansimage.ANSImage)
+		if !ok {
+			return c.String(http.StatusBadRequest,
+				fmt.Sprintf("No diff for the pre-rendered animation %s.\n", gifName))
+		}
+		images[i] = image
+	}
+
+	diff, err := images[0].Diff(images[1], DIFF_TOLERANCE)
+	if err == ansimage.ErrSizeMismatch {
+		return c.String(http.StatusBadRequest,
+			fmt.Sprintf("GIF images %s and %s differ in size.\n", names[0], names[1]))
+	} else if err != nil {
+		return c.String(http.StatusInternalServerError,
+			fmt.Sprintf("Diff error: %s.\n", err.Error()))
+	}
+
+	var opts playOptions
+	if err := parseQueryOptions(c, &opts); err != nil {
+		return c.String(http.StatusBadRequest, err.Error()+".\n")
+	}
+	return streamImage(c, diff, opts)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"giflive/ansimage"
+	"strconv"
+	"strings"
+)
+
+// colorFilters maps the names used in the filter query parameter to colour
+// filters. Those taking an argument get it after a colon (e.g. hue:90).
+var colorFilters = map[string]func(arg float64) ansimage.ColorFunc{
+	"invert": func(float64) ansimage.ColorFunc { return ansimage.Invert },
+	"sepia":  func(float64) ansimage.ColorFunc { return ansimage.Sepia },
+	"saturation": func(factor float64) ansimage.ColorFunc {
+		return ansimage.Saturation(factor)
+	},
+	"hue": func(degrees float64) ansimage.ColorFunc {
+		return ansimage.HueShift(degrees)
+	},
+}
+
+// parseFilters converts a comma-separated list of colour filters, like
+// "sepia,saturation:1.5", into a colour func applying them in order.
+func parseFilters(list string) (ansimage.ColorFunc, error) {
+	var funcs []ansimage.ColorFunc
+	for _, filter := range strings.Split(list, ",") {
+		name, arg := filter, ""
+		if i := strings.IndexByte(filter, ':'); i >= 0 {
+			name, arg = filter[:i], filter[i+1:]
+		}
+		makeFunc, ok := colorFilters[name]
+		if !ok {
+			return nil, fmt.Errorf("Invalid filter %s", filter)
+		}
+		var v float64
+		if arg != "" {
+			var err error
+			if v, err = strconv.ParseFloat(arg, 64); err != nil {
+				return nil, fmt.Errorf("Invalid filter %s", filter)
+			}
+		}
+		funcs = append(funcs, makeFunc(v))
+	}
+	return chainColorFuncs(funcs...), nil
+}
@@ -4,6 +4,13 @@ go 1.14
 
 require (
 	github.com/disintegration/imaging v1.6.2
+	github.com/kettek/apng v0.0.0-20220823221153-ff692776a607
 	github.com/labstack/echo/v4 v4.1.16
 	github.com/lucasb-eyer/go-colorful v1.0.3
+	github.com/mattn/go-runewidth v0.0.9
+	github.com/mattn/go-sqlite3 v1.14.0
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/crypto v0.0.0-20201216223049-8b5274cf687f
+	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8
+	golang.org/x/text v0.3.2
 )
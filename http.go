@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"giflive/ansimage"
+	"giflive/player"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// httpFrontend streams animations as chunked HTTP responses for curl.
+type httpFrontend struct {
+	addr string
+	e    *echo.Echo
+}
+
+func newHTTPFrontend(addr string) *httpFrontend {
+	e := echo.New()
+	e.IPExtractor = trustedProxies.ipExtractor()
+	e.Use(blocklistMiddleware)
+
+	e.POST("/render", renderUploadHandler)
+	e.POST("/:GIFNAME", uploadHandler)
+	e.GET("/:GIFNAME", streamHandler)
+	e.GET("/:GIFNAME/original", originalHandler)
+	e.GET("/:GIFNAME/preview.png", previewHandler("image/png", encodePreviewPNG))
+	e.GET("/:GIFNAME/preview.gif", previewHandler("image/gif", (*ansimage.ANSImage).EncodeGIF))
+	e.GET("/:GIFNAME/preview.html", previewHandler(echo.MIMETextHTMLCharsetUTF8, encodePreviewHTML))
+	e.GET("/:GIFNAME/preview.pbm", previewHandler("image/x-portable-bitmap", encodePreviewPBM))
+	e.GET("/:GIFNAME/preview.pgm", previewHandler("image/x-portable-graymap", encodePreviewPGM))
+	e.GET("/:GIFNAME/cast", castHandler)
+	e.GET("/testpattern/:KIND", testPatternHandler)
+	e.GET("/diff", diffHandler)
+	e.GET("/metrics", metricsHandler)
+	registerAdminRoutes(e)
+
+	// Remember each request's connection so streams can set write deadlines on it.
+	e.Server.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+		return context.WithValue(ctx, connContextKey{}, conn)
+	}
+
+	return &httpFrontend{addr: addr, e: e}
+}
+
+func (h *httpFrontend) Name() string {
+	return "HTTP"
+}
+
+func (h *httpFrontend) Listen() error {
+	ln, err := upgrades.listen(h.addr)
+	if err != nil {
+		return err
+	}
+	h.e.Listener = ln
+	return nil
+}
+
+func (h *httpFrontend) Serve(ctx context.Context) error {
+	// Streams end with the server, so they can tell their viewers why.
+	h.e.Server.BaseContext = func(net.Listener) context.Context {
+		return context.WithValue(ctx, serverContextKey{}, ctx)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- h.e.Start(h.addr)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_GRACE)
+		defer cancel()
+		h.e.Shutdown(shutdownCtx)
+		return nil
+	}
+}
+
+// connContextKey is the request context key holding the client net.Conn.
+type connContextKey struct{}
+
+// streamHandler plays the GIF named by the request path as a curl animation.
+func streamHandler(c echo.Context) error {
+	gifName := ansimage.SanitizeText(c.Param("GIFNAME"))
+	if wantsHTML(c) {
+		return playerHandler(c, gifName)
+	}
+
+	ro, err := parseRenderOptions(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error()+".\n")
+	}
+
+	image, loadErr := animations.Get(gifName, ro)
+	if loadErr == errGIFNotFound {
+		return c.String(http.StatusNotFound,
+			fmt.Sprintf("GIF image %s not found.\n", gifName))
+	} else if loadErr == errGIFBroken {
+		return c.String(http.StatusGone,
+			fmt.Sprintf("GIF image %s is broken.\n", gifName))
+	} else if loadErr == errGIFBusy {
+		return c.String(http.StatusServiceUnavailable,
+			fmt.Sprintf("GIF image %s is busy, please try again later.\n", gifName))
+	} else if loadErr != nil {
+		return c.String(http.StatusInternalServerError,
+			fmt.Sprintf("GIF image load error: %s.\n", loadErr.Error()))
+	}
+
+	var opts playOptions
+	if err := opts.applyRoute(gifName); err != nil {
+		return c.String(http.StatusInternalServerError,
+			fmt.Sprintf("Route config error: %s.\n", err.Error()))
+	}
+	if !opts.broadcast { // everyone sees the same broadcast
+		if err := parseQueryOptions(c, &opts); err != nil {
+			return c.String(http.StatusBadRequest, err.Error()+".\n")
+		}
+	}
+
+	return streamImage(c, image, opts)
+}
+
+// originalHandler serves the source GIF (or image) file named by the request path, for
+// web players and bots.
+func originalHandler(c echo.Context) error {
+	gifName := ansimage.SanitizeText(c.Param("GIFNAME"))
+	filename := gifPath(gifName)
+	if filename == "" {
+		return c.String(http.StatusNotFound,
+			fmt.Sprintf("GIF image %s not found.\n", gifName))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, mime.TypeByExtension(filepath.Ext(filename)))
+	return c.File(filename)
+}
+
+// testPatterns maps the names used by testPatternHandler to test pattern kinds.
+var testPatterns = map[string]ansimage.TestPatternKind{
+	"bars":         ansimage.TestPatternColorBars,
+	"gradient":     ansimage.TestPatternGradient,
+	"checkerboard": ansimage.TestPatternCheckerboard,
+}
+
+// testPatternHandler plays a generated test pattern, e.g. /testpattern/bars,
+// to check terminal colour support or smoke-test a deployment.
+func testPatternHandler(c echo.Context) error {
+	kind, ok := testPatterns[c.Param("KIND")]
+	if !ok {
+		return c.String(http.StatusNotFound,
+			fmt.Sprintf("Test pattern %s not found.\n", c.Param("KIND")))
+	}
+
+	ro, err := parseRenderOptions(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error()+".\n")
+	}
+
+	image, err := ansimage.NewTestPattern(ro.rows, ro.cols, kind)
+	if err != nil {
+		return c.String(http.StatusInternalServerError,
+			fmt.Sprintf("Test pattern error: %s.\n", err.Error()))
+	}
+	image.SetRenderHook(frameMetrics.observe)
+
+	var opts playOptions
+	if err := parseQueryOptions(c, &opts); err != nil {
+		return c.String(http.StatusBadRequest, err.Error()+".\n")
+	}
+
+	return streamImage(c, image, opts)
+}
+
+// parseQueryOptions sets the playback options given in the request query string.
+func parseQueryOptions(c echo.Context, opts *playOptions) error {
+	var err error
+
+	opts.pacing, _ = strconv.ParseBool(c.QueryParam("pacing"))
+	opts.checksum, _ = strconv.ParseBool(c.QueryParam("checksum"))
+	if burnIn := c.QueryParam("burnin"); burnIn != "" { // else as the route says
+		opts.burnIn, _ = strconv.ParseBool(burnIn)
+	}
+	if warmShift := c.QueryParam("warmshift"); warmShift != "" {
+		opts.warmShift, _ = strconv.ParseBool(warmShift)
+	}
+	opts.gray, _ = strconv.ParseBool(c.QueryParam("gray"))
+	opts.delta, _ = strconv.ParseBool(c.QueryParam("delta"))
+	if list := c.QueryParam("filter"); list != "" {
+		if opts.filter, err = parseFilters(list); err != nil {
+			return err
+		}
+	}
+	opts.record, _ = strconv.ParseBool(c.QueryParam("record"))
+	if opts.record && recordDir == "" {
+		return fmt.Errorf("Invalid record %s, recording is disabled", c.QueryParam("record"))
+	}
+	if opts.record && !isAdminRequest(c) {
+		return fmt.Errorf("Invalid record %s, recording needs the admin token", c.QueryParam("record"))
+	}
+	if opts.clear, err = parseClearMode(c.QueryParam("clear")); err != nil {
+		return fmt.Errorf("Invalid clear mode %s", c.QueryParam("clear"))
+	}
+	switch c.QueryParam("newline") {
+	case "", "lf":
+	case "crlf":
+		opts.crlf = true
+	default:
+		return fmt.Errorf("Invalid newline %s", c.QueryParam("newline"))
+	}
+	if opts.direction, err = parseDirection(c.QueryParam("direction")); err != nil {
+		return fmt.Errorf("Invalid direction %s", c.QueryParam("direction"))
+	}
+	if format := c.QueryParam("format"); format != "" { // else the route's
+		if opts.renderer, opts.ansi16, err = parseFormat(format); err != nil {
+			return fmt.Errorf("Invalid format %s (available: %s)",
+				format, strings.Join(ansimage.RendererNames(), ", "))
+		}
+	}
+	if name := c.QueryParam("colordither"); name != "" {
+		cd, ok := colorDitherings[name]
+		if !ok {
+			return fmt.Errorf("Invalid colour dithering %s", name)
+		}
+		opts.colorDithering = cd
+	}
+	if names := c.QueryParam("widgets"); names != "" {
+		if opts.widgets, err = parseWidgets(strings.Split(names, ",")); err != nil {
+			return fmt.Errorf("Invalid widgets %s", names)
+		}
+	}
+	return nil
+}
+
+// streamImage plays image until the client goes away: as a curl animation,
+// or to web clients over a WebSocket or as server-sent events.
+func streamImage(c echo.Context, image ansimage.Animation, opts playOptions) error {
+	ctx := c.Request().Context()
+	server := ctx.Value(serverContextKey{}).(context.Context)
+	conn := ctx.Value(connContextKey{}).(net.Conn)
+	defer conn.SetWriteDeadline(time.Time{}) // keep-alive connections outlive the stream
+
+	var t player.Transport
+	if isWebSocketUpgrade(c.Request()) {
+		ws, err := upgradeWebSocket(c)
+		if err == errBadWebSocketHandshake {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("%s.\n", err.Error()))
+		} else if err != nil {
+			return err
+		}
+		ws.crlf = opts.crlf
+
+		// The hijacked connection no longer cancels the request context.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			ws.readUntilClose()
+			cancel()
+		}()
+		t = ws
+	} else {
+		var w io.Writer = deadlineWriter{c.Response(), conn, writeTimeout}
+		if wantsEventStream(c) {
+			c.Response().Header().Set(echo.HeaderContentType, MIME_EVENT_STREAM)
+			c.Response().Header().Set("Cache-Control", "no-cache")
+			t = &sseTransport{w: w}
+		} else {
+			// curl animation
+			c.Response().Header().Set("Transfer-Encoding", "chunked")
+			if opts.crlf {
+				w = crlfWriter{w}
+			}
+			t = player.StreamTransport{W: w}
+		}
+		c.Response().WriteHeader(http.StatusOK)
+	}
+
+	err := playSession(ctx, "http", c.RealIP(), strings.TrimPrefix(c.Request().URL.Path, "/"), t, image, opts)
+	if isTimeout(err) {
+		log.Printf("Client %s stalled, disconnecting\n", c.RealIP())
+	} else {
+		log.Printf("Client %s stopped listening\n", c.RealIP())
+	}
+	closeStream(t, server, err)
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// GIF_LOAD_QUEUE is the number of requests that may wait for a GIF while
+// another variant of it loads. Further requests fail with errGIFBusy, so a
+// file that is slow to decode can't pile up goroutines and memory.
+const GIF_LOAD_QUEUE = 16
+
+// GIF_RENDER_SLOTS is the number of frames of a GIF rendered at the same time
+// by all of its streams. Streams of a GIF too expensive to render slow down
+// each other instead of taking the CPU from the other GIFs.
+const GIF_RENDER_SLOTS = 4
+
+// errGIFBusy occurs when too many requests wait for a GIF to load.
+var errGIFBusy = errors.New("GIF image busy")
+
+// gifLane isolates the work done for a GIF (loading its variants, rendering
+// the frames of its streams) from the other GIFs, so a pathological file
+// (huge frames, thousands of frames) degrades only its own route.
+type gifLane struct {
+	load    chan struct{} // held while a variant of the GIF loads
+	waiting int32         // requests waiting for load
+	render  chan struct{} // held while a frame of the GIF renders
+}
+
+func newGIFLane() *gifLane {
+	return &gifLane{
+		load:   make(chan struct{}, 1),
+		render: make(chan struct{}, GIF_RENDER_SLOTS),
+	}
+}
+
+// lane returns the lane of the GIF named name.
+func (c *animationCache) lane(name string) *gifLane {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lane, ok := c.lanes[name]
+	if !ok {
+		lane = newGIFLane()
+		c.lanes[name] = lane
+	}
+	return lane
+}
+
+// acquireLoad waits for the GIF to be free to load, unless GIF_LOAD_QUEUE
+// requests already wait for it. The caller must call releaseLoad after a nil error.
+func (l *gifLane) acquireLoad() error {
+	if atomic.AddInt32(&l.waiting, 1) > GIF_LOAD_QUEUE {
+		atomic.AddInt32(&l.waiting, -1)
+		return errGIFBusy
+	}
+	l.load <- struct{}{}
+	atomic.AddInt32(&l.waiting, -1)
+	return nil
+}
+
+func (l *gifLane) releaseLoad() {
+	<-l.load
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// errInvalidLink occurs when the URL of a route link isn't an absolute
+// http(s) URL made of printable ASCII characters, as OSC 8 requires.
+var errInvalidLink = errors.New("link url must be an absolute http or https URL")
+
+// hyperlink is an OSC 8 hyperlink shown with the frames of a GIF, which
+// supporting terminals (iTerm2, GNOME Terminal, kitty, WezTerm, Windows
+// Terminal...) let viewers click. Other terminals ignore it.
+type hyperlink struct {
+	url    string
+	footer string // text of the footer line, or empty to link the art itself
+}
+
+// newHyperlink checks the link configured by cfg (if any).
+func newHyperlink(cfg *linkConfig) (*hyperlink, error) {
+	if cfg == nil || cfg.URL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errInvalidLink
+	}
+	for i := 0; i < len(cfg.URL); i++ {
+		if cfg.URL[i] < 0x20 || cfg.URL[i] > 0x7e {
+			return nil, errInvalidLink
+		}
+	}
+	footer := strings.Replace(ansimage.SanitizeText(cfg.Footer), "\n", " ", -1)
+	return &hyperlink{url: cfg.URL, footer: footer}, nil
+}
+
+// osc8 returns the sequence starting a hyperlink to target, or ending it if target is empty.
+func osc8(target string) string {
+	return "\033]8;;" + target + "\033\\"
+}
+
+// start writes the start of the link around the art, if it links the art.
+func (l *hyperlink) start(w io.Writer) {
+	if l.footer == "" {
+		fmt.Fprint(w, osc8(l.url))
+	}
+}
+
+// end ends the link around the art, or writes the footer line linking to the URL.
+func (l *hyperlink) end(w io.Writer) {
+	if l.footer == "" {
+		fmt.Fprint(w, osc8(""))
+		return
+	}
+	fmt.Fprintln(w, osc8(l.url)+l.footer+osc8(""))
+}
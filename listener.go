@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// frontend is a network service streaming animations to its clients.
+// Listen opens its listening socket; Serve then blocks until ctx is
+// cancelled or the frontend fails.
+type frontend interface {
+	Name() string
+	Listen() error
+	Serve(ctx context.Context) error
+}
+
+// listenerManager runs several frontends concurrently from one process.
+// When any of them stops, the others are shut down as well.
+type listenerManager struct {
+	frontends []frontend
+}
+
+// Add registers a frontend to be started by Run.
+func (m *listenerManager) Add(f frontend) {
+	m.frontends = append(m.frontends, f)
+}
+
+// Run serves every frontend until ctx is cancelled or one of them fails.
+// ready is called once all of them listen, so an old process handing its
+// sockets off is only told to stop once this one can take the connections.
+// It returns the first failure, or nil on a clean shutdown.
+func (m *listenerManager) Run(ctx context.Context, ready func()) error {
+	if len(m.frontends) == 0 {
+		return errors.New("no listener enabled")
+	}
+	for _, f := range m.frontends {
+		if err := f.Listen(); err != nil {
+			return fmt.Errorf("%s: %s", f.Name(), err.Error())
+		}
+	}
+	ready()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, len(m.frontends))
+	for _, f := range m.frontends {
+		go func(f frontend) {
+			log.Printf("%s frontend started\n", f.Name())
+			err := f.Serve(ctx)
+			if err != nil {
+				err = fmt.Errorf("%s: %s", f.Name(), err.Error())
+			}
+			errc <- err
+		}(f)
+	}
+
+	// The first frontend to return takes the others down with it.
+	err := <-errc
+	cancel()
+	for i := 1; i < len(m.frontends); i++ {
+		if e := <-errc; err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"giflive/ansimage"
+	"image/color"
+	"sync"
+)
+
+// LOGO_KEY_COLOUR fills the transparent area of the logo so it can be keyed out when layering.
+var LOGO_KEY_COLOUR = color.RGBA{0xff, 0x00, 0xff, 0xff}
+
+// streamLogo is an animated sprite drawn in the bottom-right corner of every stream.
+type streamLogo struct {
+	filename   string
+	rows, cols int
+
+	mu     sync.Mutex
+	images map[ansimage.DitheringMode]*ansimage.ANSImage
+}
+
+// logo is the configured stream logo, or nil when streams are shown as is.
+var logo *streamLogo
+
+// loadLogo loads the GIF file filename as a logo fitting in cols×rows terminal cells.
+func loadLogo(filename string, rows, cols int) (*streamLogo, error) {
+	l := &streamLogo{
+		filename: filename,
+		rows:     rows,
+		cols:     cols,
+		images:   make(map[ansimage.DitheringMode]*ansimage.ANSImage),
+	}
+	if _, err := l.image(defaultRenderOptions()); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// image returns the logo decoded for the dithering mode of ro.
+func (l *streamLogo) image(ro renderOptions) (*ansimage.ANSImage, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if image, ok := l.images[ro.dithering]; ok {
+		return image, nil
+	}
+
+	y, x := ansimage.ScaledSize(l.rows, l.cols, ro.dithering)
+	image, err := ansimage.NewScaledFromFile(
+		l.filename,
+		y,
+		x,
+		LOGO_KEY_COLOUR,
+		ansimage.ScaleModeFit,
+		ro.dithering)
+	if err != nil {
+		return nil, err
+	}
+	l.images[ro.dithering] = image
+	return image, nil
+}
+
+// layerOn returns the layer placing the logo in the bottom-right corner of base,
+// decoded with ro.
+func (l *streamLogo) layerOn(base *ansimage.ANSImage, ro renderOptions) (ansimage.Layer, error) {
+	image, err := l.image(ro)
+	if err != nil {
+		return ansimage.Layer{}, err
+	}
+	return ansimage.Layer{
+		Image: image,
+		Y:     base.Height() - image.Height(),
+		X:     base.Width() - image.Width(),
+		Key:   LOGO_KEY_COLOUR,
+	}, nil
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"giflive/ansimage"
-	"fmt"
 	"image/color"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"syscall"
 	"time"
-
-	"github.com/labstack/echo/v4"
 )
 
 const (
@@ -20,83 +23,203 @@ const (
 const DITHERING_MODE = ansimage.NoDithering
 const SCALE_MODE = ansimage.ScaleModeFit
 
+// GIF_DIR is the directory streamable GIF files are served from.
+const GIF_DIR = "./gifs"
+
+// IMAGE_EXTENSIONS are the extensions of the image files served from GIF_DIR,
+// by precedence when several files have the same name. Static images are
+// shown once (see ansimage.Load).
+var IMAGE_EXTENSIONS = []string{".gif", ".png", ".jpg", ".jpeg", ".webp"}
+
 var BACKGROUND_COLOUR = color.Black
 
-func main() {
-	e := echo.New()
+// gifNamePattern restricts GIF names so they can be safely mapped to files in GIF_DIR.
+var gifNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// gifPath returns the file path of the GIF (or static image) named name, or
+// an empty string if the name is invalid or no such image exists.
+func gifPath(name string) string {
+	if !gifNamePattern.MatchString(name) {
+		return ""
+	}
+	for _, ext := range IMAGE_EXTENSIONS {
+		filename := filepath.Join(GIF_DIR, name+ext)
+		if _, err := os.Stat(filename); err == nil {
+			return filename
+		}
+	}
+	return ""
+}
+
+// isImageFile reports whether filename has one of IMAGE_EXTENSIONS, rather
+// than being a directory of pre-rendered frames.
+func isImageFile(filename string) bool {
+	ext := filepath.Ext(filename)
+	for _, e := range IMAGE_EXTENSIONS {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// framesPath returns the directory of the pre-rendered animation named name
+// (see ansimage.LoadTextAnimation), or an empty string if the name is invalid
+// or no such animation exists.
+func framesPath(name string) string {
+	if !gifNamePattern.MatchString(name) {
+		return ""
+	}
+	dir := filepath.Join(GIF_DIR, name)
+	if _, err := os.Stat(filepath.Join(dir, ansimage.ManifestName)); err != nil {
+		return ""
+	}
+	return dir
+}
 
-	e.GET("/:GIFNAME", func(c echo.Context) error {
-		gifName := c.Param("GIFNAME")
+// animationPath returns the file path of the GIF (or static image) named name,
+// or else the directory of the pre-rendered animation of that name, or an
+// empty string if there is neither: what streams play for name.
+func animationPath(name string) string {
+	if filename := gifPath(name); filename != "" {
+		return filename
+	}
+	return framesPath(name)
+}
+
+// animations is shared by every frontend so each GIF is decoded only once.
+var animations = newAnimationCache()
 
-		// set image scale factor for ANSIPixel grid
-		sfy, sfx := ansimage.BlockSizeY, ansimage.BlockSizeX // 8x4 --> with dithering
-		if DITHERING_MODE == ansimage.NoDithering {
-			sfy, sfx = 2, 1 // 2x1 --> without dithering
+func main() {
+	httpAddr := flag.String("http", ":1323", "HTTP listen address (empty to disable)")
+	telnetAddr := flag.String("telnet", "", "telnet listen address (empty to disable)")
+	sshAddr := flag.String("ssh", "", "SSH listen address (empty to disable)")
+	sshHostKey := flag.String("ssh-host-key", "", "SSH host private key file (default: generate an ephemeral key)")
+	flag.Var(&trustedProxies, "trusted-proxies", "comma-separated CIDRs of reverse proxies trusted to set X-Forwarded-For and X-Real-IP")
+	blocklistFile := flag.String("blocklist", "", "file of client addresses and CIDRs to refuse, one per line, reread when it changes")
+	flag.StringVar(&recordDir, "record-dir", "", "directory of the asciicast recordings of sessions streamed with ?record=1 or recorded with the admin API (empty to disable)")
+	storeSpec := flag.String("store", "", "persist view counters, upload records and upload API keys in bolt:FILE or sqlite:FILE (empty to disable)")
+	redisAddr := flag.String("redis", "", "Redis server address (host:port) through which instances share decoded GIFs and view counts (empty to disable)")
+	flag.StringVar(&adminToken, "admin-token", "", "bearer token enabling the admin API under /admin (empty to disable)")
+	flag.DurationVar(&writeTimeout, "write-timeout", writeTimeout, "drop clients whose frame writes block longer than this")
+	flag.Var(&warmShift, "warm-shift-hours", "daily local time window for ?warmshift=1 streams")
+	flag.Float64Var(&warmShift.temperature, "warm-shift-temp", warmShift.temperature, "colour temperature in Kelvin during the warm-shift window")
+	logoFile := flag.String("logo", "", "GIF file drawn in the bottom-right corner of every stream")
+	logoRows := flag.Int("logo-rows", 6, "maximum logo height in terminal rows")
+	logoCols := flag.Int("logo-cols", 16, "maximum logo width in terminal columns")
+	flag.DurationVar(&minFrameDelay, "min-frame-delay", 0, "shortest time a GIF frame is shown, in addition to the browser-like delay of frames set to 0 or 10ms")
+	flag.DurationVar(&renderDeadline, "render-deadline", 0, "longest time a frame may take to render before its stream falls back to cheaper rendering (0 for no deadline)")
+	flag.Float64Var(&maxFPS, "max-fps", 0, "drop GIF frames above this frame rate to save bandwidth (0 for no limit)")
+	flag.BoolVar(&autoCrop, "auto-crop", false, "trim uniform borders (letterboxing) from GIFs before scaling")
+	flag.BoolVar(&serveNearest, "serve-nearest", false, "play the nearest cached size or mode of a GIF while the requested one loads in the background")
+	flag.DurationVar(&upgradeDrain, "upgrade-drain", upgradeDrain, "how long streams may go on after SIGHUP handed the listeners off to a new process, before being asked to reconnect")
+	flag.StringVar(&pidFile, "pid-file", "", "file the PID is written to once the server serves, and again by the new process after an upgrade (empty to disable)")
+	flag.BoolVar(&broadcastMode, "broadcast", false, "viewers of the same GIF and size share a single stream, ignoring their playback options")
+	uploadQuotaMB := flag.Int64("upload-quota", uploadQuota>>20, "megabytes of GIFs every upload API key may upload")
+	cacheSizeMB := flag.Int64("cache-size", cacheBudget>>20, "memory in megabytes for cached GIFs at other than the default size and style, the least recently played being dropped beyond it")
+	memoryLimitMB := flag.Uint64("memory-limit", 0, "heap size in megabytes above which new streams are reduced to the default size and caches are shed (0 to disable)")
+	seed := flag.Int64("seed", 0, "make streams deterministic for tests: frame times start at this Unix time and advance by the frame delays only (0 to disable)")
+	bench := flag.Duration("bench", 0, "measure the rendering throughput of every output format for this long each, then exit")
+	check := flag.Bool("check", false, "load and render every GIF once, report errors and timings, then exit")
+	flag.Parse()
+
+	ansimage.SetLogger(log.New(log.Writer(), "ansimage: ", log.Flags()))
+
+	if *seed != 0 {
+		playbackEpoch = time.Unix(*seed, 0).UTC()
+		startTime = playbackEpoch
+	}
+
+	if *blocklistFile != "" {
+		if err := blocklist.load(*blocklistFile); err != nil {
+			log.Fatal(err)
 		}
+	}
 
-		var image *ansimage.ANSImage
-		var loadErr error
-		var filename string = "reimu"
-
-		switch gifName {
-		case "reimu":
-			filename = "./gifs/reimu.gif"
-		case "chirno":
-			filename = "./gifs/chirno.gif"
-		case "cat":
-			filename = "./gifs/cat.gif"
-		default:
-			return c.String(http.StatusNotFound,
-				fmt.Sprintf("GIF image %s not found.\n", gifName))
+	if *logoFile != "" {
+		var err error
+		if logo, err = loadLogo(*logoFile, *logoRows, *logoCols); err != nil {
+			log.Fatal(err)
 		}
+	}
 
-		image, loadErr = ansimage.NewScaledFromFile(
-			filename,
-			sfy*VT100_HEIGHT,
-			sfx*VT100_WIDTH,
-			BACKGROUND_COLOUR,
-			SCALE_MODE,
-			DITHERING_MODE)
-
-		if loadErr != nil {
-			return c.String(http.StatusInternalServerError,
-				fmt.Sprintf("GIF image load error: %s.\n", loadErr.Error()))
+	if *bench > 0 {
+		if err := runBench(os.Stdout, *bench); err != nil {
+			log.Fatal(err)
 		}
+		return
+	}
 
-		// curl animation
-		c.Response().Header().Set("Transfer-Encoding", "chunked")
-		c.Response().WriteHeader(http.StatusOK)
-		w := c.Response().Writer
-		cn := w.(http.CloseNotifier)
-		flusher := w.(http.Flusher)
-
-		frame := 0
-		for {
-			select {
-			// Handle client disconnect
-			case <-cn.CloseNotify():
-				log.Println("Client stopped listening")
-				return nil
-			default:
-				// Clear screen
-				clearScreen := "\033[2J\033[H"
-
-				fmt.Fprint(w, clearScreen)
-
-				// Print image
-				fmt.Fprintln(w, image.RenderExt(frame, false))
-				flusher.Flush()
-
-				// GIF delay time
-				time.Sleep(time.Millisecond * time.Duration(image.FrameDelay(frame)*10))
-			}
+	if *check {
+		failed, err := runCheck(os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
 
-			frame++
-			if frame >= image.FrameCount() {
-				frame = 0
+	go validateGIFDir()
+
+	if *storeSpec != "" {
+		var err error
+		if store, err = openStore(*storeSpec); err != nil {
+			log.Fatal(err)
+		}
+		defer store.Close()
+	}
+
+	if *redisAddr != "" {
+		sharedCache = newRedisClient(*redisAddr)
+	}
+
+	manager := &listenerManager{}
+	if *httpAddr != "" {
+		manager.Add(newHTTPFrontend(*httpAddr))
+	}
+	if *telnetAddr != "" {
+		manager.Add(newTelnetFrontend(*telnetAddr))
+	}
+	if *sshAddr != "" {
+		ssh, err := newSSHFrontend(*sshAddr, *sshHostKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		manager.Add(ssh)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for s := range sig {
+			if s != syscall.SIGHUP {
+				cancel()
+				return
 			}
+			// SIGHUP upgrades to the binary now on disk.
+			if err := upgrades.upgrade(); err != nil {
+				log.Printf("Upgrade failed: %s\n", err)
+				continue
+			}
+			go func() {
+				upgrades.drain()
+				cancel()
+			}()
 		}
-	})
-
-	e.Logger.Fatal(e.Start(":1323"))
+	}()
+
+	cacheBudget = *cacheSizeMB << 20
+	uploadQuota = *uploadQuotaMB << 20
+	if *memoryLimitMB > 0 {
+		memoryLimit = *memoryLimitMB << 20
+		go watchMemory(ctx)
+	}
+
+	if err := manager.Run(ctx, upgrades.serving); err != nil {
+		log.Fatal(err)
+	}
 }
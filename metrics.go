@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"giflive/ansimage"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RENDER_SECONDS_BUCKETS are the upper bounds of the frame render duration histogram.
+var RENDER_SECONDS_BUCKETS = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25}
+
+// renderMetrics collects the render cost of every streamed frame, for /metrics.
+type renderMetrics struct {
+	mu      sync.Mutex
+	buckets []uint64 // cumulative counts, one per RENDER_SECONDS_BUCKETS
+	count   uint64
+	seconds float64
+	bytes   uint64
+}
+
+// frameMetrics is the render hook of every animation loaded by the server.
+var frameMetrics = &renderMetrics{buckets: make([]uint64, len(RENDER_SECONDS_BUCKETS))}
+
+// observe is an ansimage.RenderHook.
+func (m *renderMetrics) observe(stats ansimage.RenderStats) {
+	seconds := stats.Duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, le := range RENDER_SECONDS_BUCKETS {
+		if seconds <= le {
+			m.buckets[i]++
+		}
+	}
+	m.count++
+	m.seconds += seconds
+	m.bytes += uint64(stats.Bytes)
+}
+
+// metricsHandler serves the render metrics in the Prometheus text format.
+func metricsHandler(c echo.Context) error {
+	m := frameMetrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "# HELP giflive_frame_render_seconds Time spent rendering a frame.")
+	fmt.Fprintln(&b, "# TYPE giflive_frame_render_seconds histogram")
+	for i, le := range RENDER_SECONDS_BUCKETS {
+		fmt.Fprintf(&b, "giflive_frame_render_seconds_bucket{le=\"%g\"} %d\n", le, m.buckets[i])
+	}
+	fmt.Fprintf(&b, "giflive_frame_render_seconds_bucket{le=\"+Inf\"} %d\n", m.count)
+	fmt.Fprintf(&b, "giflive_frame_render_seconds_sum %g\n", m.seconds)
+	fmt.Fprintf(&b, "giflive_frame_render_seconds_count %d\n", m.count)
+	fmt.Fprintln(&b, "# HELP giflive_frame_render_bytes_total Size of the rendered frames.")
+	fmt.Fprintln(&b, "# TYPE giflive_frame_render_bytes_total counter")
+	fmt.Fprintf(&b, "giflive_frame_render_bytes_total %d\n", m.bytes)
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/plain; version=0.0.4")
+	return c.String(http.StatusOK, b.String())
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"giflive/ansimage"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// DEFAULT_MARQUEE_SPEED is the crawl speed, in cells per second, when a route doesn't set one.
+const DEFAULT_MARQUEE_SPEED = 8
+
+// marquee is a text ticker scrolling right to left along the bottom row of each frame.
+type marquee struct {
+	text  []cell
+	speed float64
+}
+
+func newMarquee(text string, speed float64) *marquee {
+	if speed <= 0 {
+		speed = DEFAULT_MARQUEE_SPEED
+	}
+	return &marquee{text: textCells(singleLine(text)), speed: speed}
+}
+
+// Line returns the visible part of the ticker width cells wide, elapsed after the stream started.
+func (m *marquee) Line(elapsed time.Duration, width int) string {
+	// The text enters from the right edge and leaves completely before restarting.
+	track := append(make([]cell, width), m.text...)
+	offset := int(elapsed.Seconds()*m.speed) % len(track)
+
+	line := make([]cell, width)
+	for i := range line {
+		line[i] = track[(offset+i)%len(track)]
+	}
+	return cellsString(line)
+}
+
+// cell is a terminal cell of overlay text: a character with its combining
+// marks. A wide (CJK) character takes two cells, the second one empty
+// with wide set.
+type cell struct {
+	text string
+	wide bool
+}
+
+// runeWidths measures characters like the terminals of western locales,
+// whatever the locale of the server: ambiguous characters take one cell.
+var runeWidths = &runewidth.Condition{EastAsianWidth: false}
+
+// textCells splits text into the terminal cells it takes.
+func textCells(text string) []cell {
+	var cells []cell
+	for _, r := range text {
+		switch runeWidths.RuneWidth(r) {
+		case 0:
+			if len(cells) > 0 {
+				if last := &cells[len(cells)-1]; last.text != "" {
+					last.text += string(r)
+				} else {
+					cells[len(cells)-2].text += string(r) // after a wide character
+				}
+			}
+		case 2:
+			cells = append(cells, cell{text: string(r), wide: true}, cell{wide: true})
+		default:
+			cells = append(cells, cell{text: string(r)})
+		}
+	}
+	return cells
+}
+
+// cellsString returns the text of cells, one column per cell. Halves of wide
+// characters cut at either end are replaced by spaces.
+func cellsString(cells []cell) string {
+	var b strings.Builder
+	for i, c := range cells {
+		switch {
+		case !c.wide:
+			if c.text == "" {
+				c.text = " " // zero value
+			}
+			b.WriteString(c.text)
+		case c.text == "" && i == 0, c.text != "" && i == len(cells)-1:
+			b.WriteByte(' ')
+		default:
+			b.WriteString(c.text)
+		}
+	}
+	return b.String()
+}
+
+// singleLine sanitizes text shown in a single row (see ansimage.SanitizeText),
+// with newlines turned into spaces.
+func singleLine(text string) string {
+	return strings.Replace(ansimage.SanitizeText(text), "\n", " ", -1)
+}
+
+// overlayRow replaces a row of a rendered frame with text drawn in the default
+// terminal colours. Negative rows count from the bottom (-1 is the last row).
+func overlayRow(render string, row int, text string) string {
+	rows := strings.SplitAfter(render, "\n")
+	n := len(rows)
+	if rows[n-1] == "" {
+		n-- // nothing follows the final newline
+	}
+	if row < 0 {
+		row += n
+	}
+	if row < 0 || row >= n {
+		return render
+	}
+	rows[row] = "\033[0m" + singleLine(text) + "\033[0m\n"
+	return strings.Join(rows, "")
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"giflive/player"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// writeTimeout bounds how long a single frame write may block before the
+// client is considered gone.
+var writeTimeout = 10 * time.Second
+
+// errUnknownClearMode occurs when a clear strategy name is invalid.
+var errUnknownClearMode = errors.New("unknown clear mode")
+
+// parseClearMode converts the name used in query parameters into a player.ClearMode.
+func parseClearMode(name string) (player.ClearMode, error) {
+	switch name {
+	case "", "full":
+		return player.ClearFull, nil
+	case "home":
+		return player.ClearHome, nil
+	case "scroll":
+		return player.ClearScroll, nil
+	}
+	return player.ClearFull, errUnknownClearMode
+}
+
+// errUnknownDirection occurs when a playback direction name is invalid.
+var errUnknownDirection = errors.New("unknown direction")
+
+// parseDirection converts the name used in query parameters into a player.Direction.
+func parseDirection(name string) (player.Direction, error) {
+	switch name {
+	case "", "forward":
+		return player.Forward, nil
+	case "reverse":
+		return player.Reverse, nil
+	case "boomerang":
+		return player.Boomerang, nil
+	}
+	return player.Forward, errUnknownDirection
+}
+
+// playOptions holds per-connection playback settings.
+type playOptions struct {
+	// pacing prefixes every frame with a pacing header (see player.PacingHeader).
+	pacing bool
+
+	// checksum ends every frame with a checksum trailer (see player.ChecksumTrailer).
+	checksum bool
+
+	// clear is the screen clearing strategy used between frames.
+	clear player.ClearMode
+
+	// burnIn periodically shifts and eventually dims the image (see burnin.go).
+	burnIn bool
+
+	// warmShift warms colours during the warmShift schedule (see warmshift.go).
+	warmShift bool
+
+	// gray shows luminance only (see ansimage.Grayscale).
+	gray bool
+
+	// filter, if not nil, adjusts the colours of every frame (see filter.go).
+	filter ansimage.ColorFunc
+
+	// marquee, if not nil, crawls along the bottom row of every frame.
+	marquee *marquee
+
+	// widgets are shown in the top row of every frame (see widget.go).
+	widgets []widget
+
+	// link, if not nil, links the art or a footer line to a web page (see link.go).
+	link *hyperlink
+
+	// transparent is set when the frames leave transparent cells undrawn
+	// (see routeConfig.Transparent), so every frame starts from an erased screen.
+	transparent bool
+
+	// bells are the frames ringing the terminal bell as they are shown, for
+	// rhythm-synced animations.
+	bells map[int]bool
+
+	// direction is the order frames are played in.
+	direction player.Direction
+
+	// renderer, if not nil, writes the frames of ANSImages instead of the
+	// default 24-bit colour text (see parseFormat).
+	renderer ansimage.RendererFactory
+
+	// colorDithering is how renderers with a limited palette approximate colours.
+	colorDithering ansimage.ColorDithering
+
+	// ansi16 is set when renderer draws with the 16 basic ANSI colours or fewer.
+	ansi16 bool
+
+	// delta redraws only the cells that changed since the previous frame, when
+	// the animation supports it (see ansimage.ANSImage.RenderDeltaTo).
+	delta bool
+
+	// broadcast joins the shared stream of the animation (see broadcast.go).
+	broadcast bool
+
+	// name is the name of the GIF, which names its broadcast across
+	// servers (see cluster.go).
+	name string
+
+	// seek starts the playback that far into it (see player.Player.Seek).
+	seek time.Duration
+
+	// record records the stream to recordDir (see record.go).
+	record bool
+
+	// crlf ends lines with "\r\n", for clients that don't translate "\n" (see crlfWriter).
+	crlf bool
+
+	// renderSlots, if not nil, bounds the frames rendered at the same time by
+	// the streams of the animation (see gifLane).
+	renderSlots chan struct{}
+}
+
+// renderDeadline is how long a frame may take to render before its stream
+// falls back to cheaper rendering, rather than stalling (0 for no deadline).
+var renderDeadline time.Duration
+
+// playbackEpoch, if not zero, makes every stream deterministic, so tests can
+// compare them byte for byte (see player.Player.Epoch).
+var playbackEpoch time.Time
+
+// DEFAULT_FORMAT is the output format of streams that don't ask for another one.
+// It's played with ANSImage.RenderFilteredTo, which supports delta rendering and overlays.
+const DEFAULT_FORMAT = "truecolor"
+
+// errUnknownFormat occurs when an output format name is invalid.
+var errUnknownFormat = errors.New("unknown format")
+
+// parseFormat converts the name used in query parameters into a renderer
+// registered in ansimage (nil for DEFAULT_FORMAT), and reports whether it
+// draws with the 16 basic ANSI colours or fewer (see playOptions.ansi16).
+func parseFormat(name string) (ansimage.RendererFactory, bool, error) {
+	if name == "" || name == DEFAULT_FORMAT {
+		return nil, false, nil
+	}
+	factory, ok := ansimage.LookupRenderer(name)
+	if !ok {
+		return nil, false, errUnknownFormat
+	}
+	switch name {
+	case "ansi16", "mono", "gray4": // no colours to degrade to
+		return factory, true, nil
+	}
+	return factory, false, nil
+}
+
+// colorDitherings maps the names used in query parameters to colour dithering methods.
+var colorDitherings = map[string]ansimage.ColorDithering{
+	"none":   ansimage.NearestColor,
+	"fs":     ansimage.FloydSteinberg,
+	"bayer2": ansimage.OrderedBayer2,
+	"bayer4": ansimage.OrderedBayer4,
+	"bayer8": ansimage.OrderedBayer8,
+}
+
+// DELTA_KEYFRAME_INTERVAL is the number of frames between full redraws of a
+// delta stream, repairing the screen if anything else wrote to it.
+const DELTA_KEYFRAME_INTERVAL = 30
+
+// deltaRenderer is implemented by animations that can render the changes
+// between two frames, like ansimage.ANSImage.
+type deltaRenderer interface {
+	RenderDeltaTo(frame, prev int, w io.Writer, disableBgColor bool, cf ansimage.ColorFunc) error
+}
+
+// playAnimation sends image to t frame by frame, looping as many times as
+// the animation asks (forever for most GIFs), until ctx is cancelled or a
+// write fails. It returns nil once the loops are done.
+func playAnimation(ctx context.Context, t player.Transport, image ansimage.Animation, opts playOptions) error {
+	atomic.AddInt64(&viewerCount, 1)
+	defer atomic.AddInt64(&viewerCount, -1)
+
+	if opts.broadcast {
+		return broadcasts.play(ctx, t, image, opts)
+	}
+	return play(ctx, t, image, opts)
+}
+
+// play sends image to t like playAnimation, for a viewer or a broadcast,
+// with a player.Player rendering the frames with the options.
+func play(ctx context.Context, t player.Transport, image ansimage.Animation, opts playOptions) error {
+	delta, _ := image.(deltaRenderer)
+	if !opts.delta || opts.clear == player.ClearScroll || opts.marquee != nil || len(opts.widgets) > 0 || opts.transparent {
+		delta = nil // overlays, scrolling and transparent cells need whole frames
+	}
+	if opts.warmShift || opts.burnIn {
+		delta = nil // the colours of unchanged cells change over time
+	}
+	custom, _ := image.(*ansimage.ANSImage)
+	if opts.renderer == nil {
+		custom = nil
+	} else if custom != nil {
+		delta = nil
+	}
+
+	p := player.New(image)
+	p.Clear = opts.clear
+	if delta != nil {
+		p.Clear = player.ClearHome // deltas draw over the previous frame
+	} else if opts.transparent && p.Clear == player.ClearHome {
+		p.Clear = player.ClearFull // the previous frame would show through
+	}
+	p.Direction = opts.direction
+	p.Pacing = opts.pacing
+	p.Checksum = opts.checksum
+	p.RenderSlots = opts.renderSlots
+	p.Seek = opts.seek
+	p.Epoch = playbackEpoch
+
+	// degrade makes the rest of the stream cheaper to render, one step at a
+	// time: no colour dithering, then 16 colours. It returns what it changed,
+	// or an empty string if nothing is left to degrade.
+	degrade := func() string {
+		if opts.colorDithering != ansimage.NearestColor {
+			opts.colorDithering = ansimage.NearestColor
+			return "colour dithering disabled"
+		}
+		if ansi16, ok := ansimage.LookupRenderer("ansi16"); ok && !opts.ansi16 {
+			if custom, _ = image.(*ansimage.ANSImage); custom != nil {
+				opts.renderer, opts.ansi16 = ansi16, true
+				delta = nil
+				return "switched to 16 colours"
+			}
+		}
+		return ""
+	}
+
+	lastShift, sinceKeyframe := 0, 0
+	render := func(w io.Writer, f player.Frame) error {
+		var shift int
+		var colorFunc ansimage.ColorFunc
+		if opts.warmShift {
+			colorFunc = warmShift.ColorFunc(f.At)
+		}
+		if opts.burnIn {
+			shift = burnInShift(f.Elapsed)
+			colorFunc = chainColorFuncs(colorFunc, burnInDim(f.Elapsed))
+		}
+		colorFunc = chainColorFuncs(opts.filter, colorFunc)
+		if opts.gray {
+			colorFunc = chainColorFuncs(colorFunc, ansimage.Grayscale)
+		}
+
+		// a shifted frame starts from a blank screen
+		prev := f.Prev
+		if prev >= 0 && shift != lastShift {
+			fmt.Fprint(w, "\033[2J\033[H")
+			prev = -1
+		}
+		lastShift = shift
+		if prev < 0 || sinceKeyframe >= DELTA_KEYFRAME_INTERVAL || shift != 0 {
+			prev, sinceKeyframe = -1, 0 // redraw the whole frame
+		}
+		sinceKeyframe++
+
+		if opts.bells[f.Index] {
+			fmt.Fprint(w, "\a")
+		}
+
+		if opts.link != nil {
+			opts.link.start(w)
+		}
+
+		// Print image
+		if custom != nil {
+			if err := custom.RenderWith(f.Index, w, opts.renderer(ansimage.RendererOptions{ColorFunc: colorFunc, ColorDithering: opts.colorDithering})); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		} else if delta != nil && prev >= 0 {
+			if err := delta.RenderDeltaTo(f.Index, prev, w, false, colorFunc); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		} else if opts.marquee == nil && len(opts.widgets) == 0 && shift == 0 {
+			if err := image.RenderFilteredTo(f.Index, w, false, colorFunc); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		} else {
+			render := image.RenderFiltered(f.Index, false, colorFunc)
+			if opts.marquee != nil {
+				render = overlayRow(render, -1, opts.marquee.Line(f.Elapsed, image.Width()))
+			}
+			if len(opts.widgets) > 0 {
+				render = overlayRow(render, 0, widgetLine(opts.widgets, f.At, image.Width()))
+			}
+			fmt.Fprintln(w, shiftRows(render, shift))
+		}
+		if opts.link != nil {
+			opts.link.end(w)
+		}
+		return nil
+	}
+	p.Render = func(w io.Writer, f player.Frame) error {
+		start := time.Now()
+		err := render(w, f)
+		if took := time.Since(start); renderDeadline > 0 && took > renderDeadline {
+			if change := degrade(); change != "" {
+				log.Printf("Frame rendered in %s, over the render deadline: %s\n", took.Round(time.Millisecond), change)
+			}
+		}
+		return err
+	}
+	return p.PlayTo(ctx, t)
+}
+
+// crlfWriter translates "\n" into "\r\n" for clients without a line discipline
+// (raw telnet sockets, SSH sessions with a pseudo-terminal).
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (cw crlfWriter) Write(p []byte) (int, error) {
+	if _, err := cw.w.Write(bytes.Replace(p, []byte("\n"), []byte("\r\n"), -1)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush flushes the underlying writer if it buffers (HTTP responses).
+func (cw crlfWriter) Flush() {
+	if flusher, ok := cw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeDeadliner is implemented by connections supporting write deadlines, like net.Conn.
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// deadlineWriter refreshes the write deadline of conn before every write and
+// flush, so a stalled peer fails the write instead of blocking the player forever.
+type deadlineWriter struct {
+	w       io.Writer
+	conn    writeDeadliner
+	timeout time.Duration
+}
+
+func (dw deadlineWriter) Write(p []byte) (int, error) {
+	dw.conn.SetWriteDeadline(time.Now().Add(dw.timeout))
+	return dw.w.Write(p)
+}
+
+// Flush flushes the underlying writer if it buffers (HTTP responses).
+func (dw deadlineWriter) Flush() {
+	if flusher, ok := dw.w.(http.Flusher); ok {
+		dw.conn.SetWriteDeadline(time.Now().Add(dw.timeout))
+		flusher.Flush()
+	}
+}
+
+// closeOnDeadline emulates write deadlines for streams that lack them (SSH
+// channels) by closing the underlying connection once a deadline passes.
+type closeOnDeadline struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	conn    io.Closer
+	expired bool
+}
+
+func (d *closeOnDeadline) SetWriteDeadline(t time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if !t.IsZero() {
+		d.timer = time.AfterFunc(time.Until(t), func() {
+			d.mu.Lock()
+			d.expired = true
+			d.mu.Unlock()
+			d.conn.Close()
+		})
+	}
+	return nil
+}
+
+// Expired reports whether a deadline has passed and the connection was closed.
+func (d *closeOnDeadline) Expired() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.expired
+}
+
+// isTimeout reports whether err is a write deadline expiry.
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,301 @@
+// Package player plays ansimage animations on terminals: it sends their
+// frames to a Transport on time, loop after loop, until the context ends.
+package player
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"giflive/ansimage"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// ClearMode selects how the screen is prepared before each frame.
+type ClearMode int
+
+const (
+	ClearFull   ClearMode = iota // erase the screen, then home the cursor
+	ClearHome                    // home the cursor and overwrite the previous frame
+	ClearScroll                  // append frames one after another (pagers, logs)
+)
+
+// Escape sequences written by the clear modes.
+const (
+	clearScreen = "\033[2J\033[H"
+	cursorHome  = "\033[H"
+)
+
+// Direction selects the order frames are played in.
+type Direction int
+
+const (
+	Forward   Direction = iota // first to last frame
+	Reverse                    // last to first frame
+	Boomerang                  // forwards then backwards
+)
+
+// FrameOrder lists the frame indexes of one loop of an animation of count
+// frames played in dir, matching ANSImage.Reversed and ANSImage.Boomerang.
+func FrameOrder(count int, dir Direction) []int {
+	order := make([]int, 0, 2*count)
+	for i := 0; i < count; i++ {
+		order = append(order, i)
+	}
+	switch dir {
+	case Reverse:
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	case Boomerang:
+		for i := count - 2; i > 0; i-- {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// PacingHeader returns the APC sequence announcing frame, to be shown for
+// delay, e.g. "\033_giflive;frame=3;delay=120ms\033\\". Terminals ignore
+// APC sequences, while replay clients can parse them to rebuffer with the
+// intended timing regardless of network jitter.
+func PacingHeader(frame int, delay time.Duration) string {
+	return fmt.Sprintf("\033_giflive;frame=%d;delay=%dms\033\\", frame, delay/time.Millisecond)
+}
+
+// ChecksumTrailer returns the APC sequence closing a frame with the CRC-32
+// (IEEE) of its bytes, e.g. "\033_giflive;crc32=1a2b3c4d\033\\", so replay
+// tools and test harnesses can tell frames truncated by proxies.
+func ChecksumTrailer(frame []byte) string {
+	return fmt.Sprintf("\033_giflive;crc32=%08x\033\\", crc32.ChecksumIEEE(frame))
+}
+
+// Frame describes a frame to render.
+type Frame struct {
+	// Index is the frame of the animation.
+	Index int
+
+	// Prev is the frame still on screen, which the new one overwrites with
+	// ClearHome, or -1 if the screen was cleared.
+	Prev int
+
+	// At is when the frame will be shown, and Elapsed how long after the
+	// start of the playback that is.
+	At      time.Time
+	Elapsed time.Duration
+}
+
+// RenderFunc writes frame f of an animation, after the screen was prepared.
+type RenderFunc func(w io.Writer, f Frame) error
+
+// LoopCounter is implemented by animations that play a limited number of
+// times, like ansimage.ANSImage.
+type LoopCounter interface {
+	LoopCount() int
+}
+
+// Player plays an animation. Set its fields before calling Play.
+type Player struct {
+	anim ansimage.Animation
+
+	// Clear is how the screen is prepared between frames. The first frame
+	// always starts from an erased screen, but with ClearScroll.
+	Clear ClearMode
+
+	// Direction is the order frames are played in.
+	Direction Direction
+
+	// Pacing prefixes every frame with a PacingHeader.
+	Pacing bool
+
+	// Checksum ends every frame with a ChecksumTrailer of the frame bytes
+	// before it, from the PacingHeader on.
+	Checksum bool
+
+	// Render writes a frame; by default, the frame rendered in 24-bit colour
+	// text by the animation, then a newline.
+	Render RenderFunc
+
+	// RenderSlots, if not nil, bounds the frames rendered at the same time:
+	// each render holds a slot of the channel.
+	RenderSlots chan struct{}
+
+	// Seek, if positive, starts the playback that far into it, as if it had
+	// been playing since: the first frame sent is the one shown then, for
+	// the rest of its delay. Broadcasts on several servers seek to the time
+	// elapsed since a shared start to show the same frames.
+	Seek time.Duration
+
+	// Epoch, if not zero, makes the frame times deterministic, for tests:
+	// the first frame is rendered as shown at Epoch, and every next one as
+	// shown once the delays of the previous ones elapsed, whatever the actual
+	// time. The frames then depend on the animation and the settings only.
+	Epoch time.Time
+}
+
+// New returns a Player of anim with the default settings.
+func New(anim ansimage.Animation) *Player {
+	return &Player{anim: anim}
+}
+
+// Play writes the animation to w frame by frame, like PlayTo with a
+// StreamTransport: a frame is written with a single call of w.Write, and
+// flushed if w is an http.Flusher.
+func (p *Player) Play(ctx context.Context, w io.Writer) error {
+	return p.PlayTo(ctx, StreamTransport{W: w})
+}
+
+// PlayTo sends the animation to t frame by frame, looping as many times as it
+// asks (forever for most GIFs, see LoopCounter), until ctx is cancelled or a
+// write fails. It returns nil once the loops are done, leaving the last frame
+// on screen. It doesn't close t.
+//
+// Every frame is rendered in the background while the previous one is
+// sent and shown, so expensive renders don't stretch the frame delays.
+func (p *Player) PlayTo(ctx context.Context, t Transport) error {
+	render := p.Render
+	if render == nil {
+		render = func(w io.Writer, f Frame) error {
+			if err := p.anim.RenderFilteredTo(f.Index, w, false, nil); err != nil {
+				return err
+			}
+			_, err := fmt.Fprintln(w)
+			return err
+		}
+	}
+
+	order := FrameOrder(p.anim.FrameCount(), p.Direction)
+	plays, maxPlays := 0, 0 // passes over order, and their limit (0 for none)
+	if lc, ok := p.anim.(LoopCounter); ok {
+		if n := lc.LoopCount(); n < 0 {
+			maxPlays = 1
+		} else if n > 0 {
+			maxPlays = n + 1
+		}
+	}
+	start := time.Now()
+	if !p.Epoch.IsZero() {
+		start = p.Epoch
+	}
+	at := start // when the frame being written is shown
+	prev := -1
+
+	// renderStep renders the frame at step of order, as it should look when
+	// shown at time at. Steps are rendered one at a time, in order.
+	renderStep := func(step int, at time.Time) (*bytes.Buffer, time.Duration, error) {
+		w := new(bytes.Buffer)
+		frame := order[step]
+		delay := p.delay(frame)
+
+		if p.Pacing {
+			fmt.Fprint(w, PacingHeader(frame, delay))
+		}
+
+		switch {
+		case p.Clear == ClearScroll:
+			prev = -1
+		case p.Clear == ClearHome && prev >= 0:
+			fmt.Fprint(w, cursorHome)
+		default:
+			fmt.Fprint(w, clearScreen)
+			prev = -1
+		}
+
+		if err := render(w, Frame{Index: frame, Prev: prev, At: at, Elapsed: at.Sub(start)}); err != nil {
+			return nil, 0, err
+		}
+		prev = frame
+		if p.Checksum {
+			fmt.Fprint(w, ChecksumTrailer(w.Bytes()))
+		}
+		return w, delay, nil
+	}
+
+	// prefetch renders a step in the background.
+	type rendered struct {
+		frame *bytes.Buffer
+		delay time.Duration
+		err   error
+	}
+	prefetch := func(step int, at time.Time) <-chan rendered {
+		next := make(chan rendered, 1)
+		go func() {
+			if p.RenderSlots != nil {
+				p.RenderSlots <- struct{}{}
+				defer func() { <-p.RenderSlots }()
+			}
+			frame, delay, err := renderStep(step, at)
+			next <- rendered{frame, delay, err}
+		}()
+		return next
+	}
+
+	step, shown := 0, time.Duration(0) // how long the first frame was shown already
+	if p.Seek > 0 {
+		step, shown, plays = p.seek(order)
+		if maxPlays > 0 && plays >= maxPlays {
+			// the loops are over: leave the last frame on screen
+			step, plays = len(order)-1, maxPlays-1
+			shown = p.delay(order[step])
+		}
+	}
+	next := prefetch(step, at)
+	for {
+		r := <-next
+		if r.err != nil {
+			return r.err
+		}
+		if err := t.WriteFrame(r.frame.Bytes(), r.delay); err != nil {
+			return err
+		}
+		r.delay -= shown
+		shown = 0
+
+		step++
+		if step >= len(order) {
+			step = 0
+			if plays++; maxPlays > 0 && plays >= maxPlays {
+				return nil
+			}
+		}
+		if p.Epoch.IsZero() {
+			at = time.Now()
+		}
+		at = at.Add(r.delay)
+		next = prefetch(step, at)
+
+		// GIF delay time
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.delay):
+		}
+	}
+}
+
+// delay returns how long frame is shown.
+func (p *Player) delay(frame int) time.Duration {
+	return time.Millisecond * time.Duration(p.anim.FrameDelay(frame)*10)
+}
+
+// seek returns the step of order shown p.Seek into the playback, how long it
+// was shown by then, and how many loops were completed.
+func (p *Player) seek(order []int) (step int, shown time.Duration, plays int) {
+	var loop time.Duration
+	for _, frame := range order {
+		loop += p.delay(frame)
+	}
+	if loop <= 0 {
+		return 0, 0, 0
+	}
+	left := p.Seek % loop
+	for step, frame := range order {
+		delay := p.delay(frame)
+		if left < delay {
+			return step, left, int(p.Seek / loop)
+		}
+		left -= delay
+	}
+	return 0, 0, int(p.Seek / loop) // unreachable
+}
@@ -0,0 +1,236 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAnimation is an animation of numbered frames, looping loops times.
+type testAnimation struct {
+	delays []int
+	loops  int
+}
+
+func (a testAnimation) FrameCount() int          { return len(a.delays) }
+func (a testAnimation) FrameDelay(frame int) int { return a.delays[frame] }
+func (a testAnimation) Width() int               { return 1 }
+func (a testAnimation) LoopCount() int           { return a.loops }
+
+func (a testAnimation) RenderFiltered(frame int, disableBgColor bool, cf ansimage.ColorFunc) string {
+	return fmt.Sprint(frame)
+}
+
+func (a testAnimation) RenderFilteredTo(frame int, w io.Writer, disableBgColor bool, cf ansimage.ColorFunc) error {
+	_, err := fmt.Fprint(w, frame)
+	return err
+}
+
+// errEnough stops the playback of animations looping forever.
+var errEnough = errors.New("enough frames")
+
+// testTransport records the frames sent, up to max if it isn't 0.
+type testTransport struct {
+	frames []string
+	delays []time.Duration
+	max    int
+}
+
+func (t *testTransport) WriteFrame(frame []byte, delay time.Duration) error {
+	if t.max > 0 && len(t.frames) >= t.max {
+		return errEnough
+	}
+	t.frames = append(t.frames, string(frame))
+	t.delays = append(t.delays, delay)
+	return nil
+}
+
+func (t *testTransport) Close(reason string) error {
+	return nil
+}
+
+// frameRenderer renders frames as their index and the frame left on screen.
+func frameRenderer(w io.Writer, f Frame) error {
+	_, err := fmt.Fprintf(w, "%d/%d", f.Index, f.Prev)
+	return err
+}
+
+func TestPlayToLoopCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		loops  int
+		max    int
+		frames int
+		err    error
+	}{
+		{"once", -1, 0, 3, nil},
+		{"twice", 1, 0, 6, nil},
+		{"three times", 2, 0, 9, nil},
+		{"forever", 0, 10, 10, errEnough},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(testAnimation{delays: []int{0, 0, 0}, loops: tt.loops})
+			p.Clear = ClearScroll
+			p.Render = frameRenderer
+			tr := &testTransport{max: tt.max}
+			if err := p.PlayTo(context.Background(), tr); err != tt.err {
+				t.Fatalf("PlayTo() = %v, want %v", err, tt.err)
+			}
+			if len(tr.frames) != tt.frames {
+				t.Fatalf("sent %d frames, want %d", len(tr.frames), tt.frames)
+			}
+			for i, frame := range tr.frames {
+				if want := fmt.Sprintf("%d/-1", i%3); frame != want {
+					t.Errorf("frame %d = %q, want %q", i, frame, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlayToClearModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		clear  ClearMode
+		frames []string
+	}{
+		{"full", ClearFull, []string{clearScreen + "0/-1", clearScreen + "1/-1", clearScreen + "2/-1"}},
+		{"home", ClearHome, []string{clearScreen + "0/-1", cursorHome + "1/0", cursorHome + "2/1"}},
+		{"scroll", ClearScroll, []string{"0/-1", "1/-1", "2/-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(testAnimation{delays: []int{0, 0, 0}, loops: -1})
+			p.Clear = tt.clear
+			p.Render = frameRenderer
+			tr := &testTransport{}
+			if err := p.PlayTo(context.Background(), tr); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tr.frames, tt.frames) {
+				t.Errorf("frames = %q, want %q", tr.frames, tt.frames)
+			}
+		})
+	}
+}
+
+func TestPlayToSeek(t *testing.T) {
+	tests := []struct {
+		name   string
+		loops  int
+		seek   time.Duration
+		frames []string
+	}{
+		{"first frame", 0, 5 * time.Millisecond, []string{"0", "1", "2"}},
+		{"second frame", 0, 15 * time.Millisecond, []string{"1", "2", "0"}},
+		{"third frame", 0, 35 * time.Millisecond, []string{"2", "0", "1"}},
+		{"next loop", 0, 75 * time.Millisecond, []string{"1", "2", "0"}},
+		{"last loop", 1, 75 * time.Millisecond, []string{"1", "2"}},
+		{"loops over", 1, 200 * time.Millisecond, []string{"2"}},
+		{"played once", -1, 60 * time.Millisecond, []string{"2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(testAnimation{delays: []int{1, 2, 3}, loops: tt.loops})
+			p.Clear = ClearScroll
+			p.Seek = tt.seek
+			p.Render = func(w io.Writer, f Frame) error {
+				_, err := fmt.Fprint(w, f.Index)
+				return err
+			}
+			tr := &testTransport{max: 3}
+			if err := p.PlayTo(context.Background(), tr); err != nil && err != errEnough {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tr.frames, tt.frames) {
+				t.Errorf("frames = %q, want %q", tr.frames, tt.frames)
+			}
+		})
+	}
+}
+
+func TestPlayToEpoch(t *testing.T) {
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := New(testAnimation{delays: []int{1, 2, 3}, loops: -1})
+	p.Clear = ClearScroll
+	p.Epoch = epoch
+	p.Render = func(w io.Writer, f Frame) error {
+		_, err := fmt.Fprint(w, f.Elapsed)
+		return err
+	}
+	tr := &testTransport{}
+	if err := p.PlayTo(context.Background(), tr); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0s", "10ms", "30ms"}
+	if !reflect.DeepEqual(tr.frames, want) {
+		t.Errorf("elapsed = %q, want %q", tr.frames, want)
+	}
+}
+
+func TestPlayToCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := New(testAnimation{delays: []int{100}, loops: 0})
+	p.Render = frameRenderer
+	if err := p.PlayTo(ctx, &testTransport{}); err != context.Canceled {
+		t.Errorf("PlayTo() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPlayToPacing(t *testing.T) {
+	p := New(testAnimation{delays: []int{4}, loops: -1})
+	p.Clear = ClearScroll
+	p.Pacing = true
+	p.Checksum = true
+	p.Render = frameRenderer
+	tr := &testTransport{}
+	if err := p.PlayTo(context.Background(), tr); err != nil {
+		t.Fatal(err)
+	}
+	frame := PacingHeader(0, 40*time.Millisecond) + "0/-1"
+	if want := frame + ChecksumTrailer([]byte(frame)); tr.frames[0] != want {
+		t.Errorf("frame = %q, want %q", tr.frames[0], want)
+	}
+	if tr.delays[0] != 40*time.Millisecond {
+		t.Errorf("delay = %v, want 40ms", tr.delays[0])
+	}
+}
+
+func TestFrameOrder(t *testing.T) {
+	tests := []struct {
+		count int
+		dir   Direction
+		order []int
+	}{
+		{4, Forward, []int{0, 1, 2, 3}},
+		{4, Reverse, []int{3, 2, 1, 0}},
+		{4, Boomerang, []int{0, 1, 2, 3, 2, 1}},
+		{2, Boomerang, []int{0, 1}},
+		{1, Boomerang, []int{0}},
+		{0, Forward, []int{}},
+	}
+	for _, tt := range tests {
+		if got := FrameOrder(tt.count, tt.dir); !reflect.DeepEqual(got, tt.order) {
+			t.Errorf("FrameOrder(%d, %d) = %v, want %v", tt.count, tt.dir, got, tt.order)
+		}
+	}
+}
+
+func TestStreamTransportClose(t *testing.T) {
+	var b strings.Builder
+	tr := StreamTransport{W: &b}
+	if err := tr.Close(""); err != nil || b.Len() != 0 {
+		t.Errorf("Close(\"\") wrote %q, %v", b.String(), err)
+	}
+	if err := tr.Close("bye"); err != nil || b.String() != "\033[0m\nbye\n" {
+		t.Errorf("Close(\"bye\") wrote %q, %v", b.String(), err)
+	}
+}
@@ -0,0 +1,55 @@
+package player
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Transport carries the frames of a Player to a viewer, whatever the protocol:
+// a byte stream for terminals, or messages for browser clients.
+type Transport interface {
+	// WriteFrame sends a whole frame, to be shown for delay.
+	WriteFrame(frame []byte, delay time.Duration) error
+
+	// Close ends the stream, telling the viewer reason if it isn't empty.
+	// It doesn't close the underlying connection unless the protocol has
+	// nothing else to do with it.
+	Close(reason string) error
+}
+
+// StreamTransport sends frames as they are over a byte stream, like a chunked
+// HTTP response, a telnet connection or an SSH channel. Every frame is written
+// with a single call of W.Write, and flushed if W is an http.Flusher.
+type StreamTransport struct {
+	W io.Writer
+}
+
+// WriteFrame writes frame to the stream; terminals show it on arrival.
+func (t StreamTransport) WriteFrame(frame []byte, delay time.Duration) error {
+	if _, err := t.W.Write(frame); err != nil {
+		return err
+	}
+	t.flush()
+	return nil
+}
+
+// Close resets the style and writes reason on its own line, instead of
+// leaving the viewer with a frozen frame.
+func (t StreamTransport) Close(reason string) error {
+	if reason == "" {
+		return nil
+	}
+	if _, err := fmt.Fprintf(t.W, "\033[0m\n%s\n", reason); err != nil {
+		return err
+	}
+	t.flush()
+	return nil
+}
+
+func (t StreamTransport) flush() {
+	if flusher, ok := t.W.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"giflive/ansimage"
+	"html/template"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PREVIEW_SCALE is the size in pixels of an ANSI-pixel in preview images.
+const PREVIEW_SCALE = 4
+
+// playerPage is shown to browsers and link unfurlers (Slack, Discord...) instead of
+// the ANSI stream. Its OpenGraph tags point to the rasterized preview.
+var playerPage = template.Must(template.New("player").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} - giflive</title>
+<meta property="og:type" content="website">
+<meta property="og:title" content="{{.Name}}">
+<meta property="og:description" content="curl {{.URL}}">
+<meta property="og:url" content="{{.URL}}">
+<meta property="og:image" content="{{.URL}}/preview.png">
+<meta name="twitter:card" content="summary_large_image">
+<style>body { background: #000; color: #ccc; font-family: monospace; text-align: center; }</style>
+</head>
+<body>
+<p><img src="{{.URL}}/original" alt="{{.Name}}"></p>
+<p>$ curl {{.URL}}</p>
+</body>
+</html>
+`))
+
+// unfurlBots are User-Agent fragments of link unfurlers, which don't all ask for HTML.
+var unfurlBots = []string{"Slackbot", "Discordbot", "Twitterbot", "TelegramBot", "facebookexternalhit"}
+
+// wantsHTML reports whether the request comes from a browser or a link unfurler
+// rather than a terminal client.
+func wantsHTML(c echo.Context) bool {
+	if strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMETextHTML) {
+		return true
+	}
+	agent := c.Request().UserAgent()
+	for _, bot := range unfurlBots {
+		if strings.Contains(agent, bot) {
+			return true
+		}
+	}
+	return false
+}
+
+// playerHandler shows the player page of the GIF named by the request path.
+func playerHandler(c echo.Context, gifName string) error {
+	if animationPath(gifName) == "" {
+		return c.String(http.StatusNotFound,
+			fmt.Sprintf("GIF image %s not found.\n", gifName))
+	}
+
+	data := struct{ Name, URL string }{
+		Name: gifName,
+		URL:  c.Scheme() + "://" + c.Request().Host + "/" + gifName,
+	}
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+	return playerPage.Execute(c.Response(), data)
+}
+
+// previewHandler returns a handler serving the GIF named by the request path,
+// rasterized as it looks in a terminal, as an image of contentType written
+// by encode.
+func previewHandler(contentType string, encode func(image *ansimage.ANSImage, w io.Writer) error) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		gifName := ansimage.SanitizeText(c.Param("GIFNAME"))
+
+		animation, err := animations.Get(gifName, defaultRenderOptions())
+		if err == errGIFNotFound {
+			return c.String(http.StatusNotFound,
+				fmt.Sprintf("GIF image %s not found.\n", gifName))
+		} else if err == errGIFBroken {
+			return c.String(http.StatusGone,
+				fmt.Sprintf("GIF image %s is broken.\n", gifName))
+		} else if err != nil {
+			return c.String(http.StatusInternalServerError,
+				fmt.Sprintf("GIF image load error: %s.\n", err.Error()))
+		}
+		image, ok := animation.(*ansimage.ANSImage)
+		if !ok {
+			return c.String(http.StatusNotFound,
+				fmt.Sprintf("No preview for %s.\n", gifName))
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, contentType)
+		c.Response().WriteHeader(http.StatusOK)
+		return encode(image, c.Response())
+	}
+}
+
+// encodePreviewPNG writes the first frame of image as a PNG image.
+func encodePreviewPNG(image *ansimage.ANSImage, w io.Writer) error {
+	return png.Encode(w, image.Rasterize(0, PREVIEW_SCALE))
+}
+
+// encodePreviewPBM writes the first frame of image in black and white as a
+// binary PBM image, for e-ink badges and serial LCDs.
+func encodePreviewPBM(image *ansimage.ANSImage, w io.Writer) error {
+	b, err := image.Bitmap(0, ansimage.Mono, ansimage.FloydSteinberg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "P4\n%d %d\n", b.Width, b.Height); err != nil {
+		return err
+	}
+	pix := make([]byte, len(b.Pix))
+	for i, v := range b.Pix {
+		pix[i] = ^v // in PBM, 1 is black
+	}
+	_, err = w.Write(pix)
+	return err
+}
+
+// encodePreviewPGM writes the first frame of image in 4 grays as a binary PGM
+// image, for e-ink badges and serial LCDs.
+func encodePreviewPGM(image *ansimage.ANSImage, w io.Writer) error {
+	b, err := image.Bitmap(0, ansimage.Gray4, ansimage.FloydSteinberg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "P5\n%d %d\n3\n", b.Width, b.Height); err != nil {
+		return err
+	}
+	pix := make([]byte, 0, b.Width*b.Height)
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			pix = append(pix, b.Level(y, x))
+		}
+	}
+	_, err = w.Write(pix)
+	return err
+}
+
+// encodePreviewHTML writes image as a web page playing it with a CSS
+// animation, in text rather than as a picture.
+func encodePreviewHTML(image *ansimage.ANSImage, w io.Writer) error {
+	_, err := io.WriteString(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>giflive</title>\n"+
+		"<style>body { background: #000; font-family: monospace; }</style>\n</head>\n<body>\n")
+	if err != nil {
+		return err
+	}
+	if err := image.WriteHTMLAnimation(w, "giflive"); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// trustedProxies are the networks of the reverse proxies whose X-Forwarded-For
+// and X-Real-IP headers tell the client address, set with -trusted-proxies.
+var trustedProxies netList
+
+// netList is a list of networks, set from comma-separated CIDRs (or single
+// addresses). It implements flag.Value; the flag can be repeated.
+type netList []*net.IPNet
+
+func (l *netList) String() string {
+	cidrs := make([]string, len(*l))
+	for i, n := range *l {
+		cidrs[i] = n.String()
+	}
+	return strings.Join(cidrs, ",")
+}
+
+func (l *netList) Set(s string) error {
+	for _, cidr := range strings.Split(s, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		*l = append(*l, n)
+	}
+	return nil
+}
+
+// contains reports whether ip is in one of the networks of l.
+func (l netList) contains(ip net.IP) bool {
+	for _, n := range l {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipExtractor returns how the client address of a request is found: the peer
+// address, unless the peer is one of the proxies in l, in which case it is the
+// nearest untrusted address in X-Forwarded-For, or else X-Real-IP.
+func (l netList) ipExtractor() echo.IPExtractor {
+	if len(l) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	// only the configured networks are trusted, not echo's default private ranges
+	trust := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, n := range l {
+		trust = append(trust, echo.TrustIPRange(n))
+	}
+	fromXFF := echo.ExtractIPFromXFFHeader(trust...)
+	fromRealIP := echo.ExtractIPFromRealIPHeader(trust...)
+	return func(req *http.Request) string {
+		if len(req.Header[echo.HeaderXForwardedFor]) > 0 {
+			return fromXFF(req)
+		}
+		return fromRealIP(req)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordDir is the directory session recordings are written to (empty
+// disables recording).
+var recordDir string
+
+// MAX_RECORDING_SIZE is the size in bytes at which a recording is stopped,
+// as animations loop forever.
+const MAX_RECORDING_SIZE = 64 << 20
+
+// MAX_RECORDINGS is the number of sessions recorded at once.
+const MAX_RECORDINGS = 4
+
+// MAX_RECORD_DIR_SIZE is the size in bytes recordDir may reach, counting
+// MAX_RECORDING_SIZE for every recording going on.
+const MAX_RECORD_DIR_SIZE = 1 << 30
+
+var (
+	// errRecordingFull occurs when a recording reaches MAX_RECORDING_SIZE.
+	errRecordingFull = errors.New("size limit reached")
+
+	// errTooManyRecordings occurs when MAX_RECORDINGS sessions are already recorded.
+	errTooManyRecordings = fmt.Errorf("%d sessions are already recorded", MAX_RECORDINGS)
+
+	// errRecordDirFull occurs when a recording could take recordDir over MAX_RECORD_DIR_SIZE.
+	errRecordDirFull = fmt.Errorf("recordings would take more than %d bytes", MAX_RECORD_DIR_SIZE)
+)
+
+// recordings counts the recordings going on, against MAX_RECORDINGS.
+var recordings struct {
+	sync.Mutex
+	active int
+}
+
+// reserveRecording takes a place for a new recording, if the recordings
+// going on and the files of recordDir leave room for it.
+func reserveRecording() error {
+	recordings.Lock()
+	defer recordings.Unlock()
+	if recordings.active >= MAX_RECORDINGS {
+		return errTooManyRecordings
+	}
+	size, err := dirSize(recordDir)
+	if err != nil {
+		return err
+	}
+	if size+int64(recordings.active+1)*MAX_RECORDING_SIZE > MAX_RECORD_DIR_SIZE {
+		return errRecordDirFull
+	}
+	recordings.active++
+	return nil
+}
+
+// releaseRecording gives back the place of a recording that ended.
+func releaseRecording() {
+	recordings.Lock()
+	recordings.active--
+	recordings.Unlock()
+}
+
+// dirSize returns the total size of the files in dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// asciicastRecorder writes what is sent to a session as an asciicast v2 file
+// (https://docs.asciinema.org/manual/asciicast/v2/), to replay it with
+// `asciinema play` when a viewer reports that something looked wrong.
+type asciicastRecorder struct {
+	f     *os.File
+	w     *bufio.Writer
+	start time.Time
+	size  int
+}
+
+// asciicastHeader is the first line of an asciicast v2 file.
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// newAsciicastRecorder creates filename and writes the header of a recording
+// of image, titled title.
+func newAsciicastRecorder(filename string, image ansimage.Animation, title string) (*asciicastRecorder, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := &asciicastRecorder{f: f, w: bufio.NewWriter(f), start: time.Now()}
+	if err := writeAsciicastHeader(r.w, image, title, r.start); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// writeAsciicastHeader writes the header of an asciicast of image, titled
+// title, recorded at timestamp. The terminal size is the size of image.
+func writeAsciicastHeader(w io.Writer, image ansimage.Animation, title string, timestamp time.Time) error {
+	// frames end with a newline, leaving the cursor on the row below
+	rows := strings.Count(strings.TrimSuffix(image.RenderFiltered(0, false, nil), "\n"), "\n") + 2
+	header, _ := json.Marshal(asciicastHeader{
+		Version:   2,
+		Width:     image.Width(),
+		Height:    rows,
+		Timestamp: timestamp.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+	_, err := w.Write(append(header, '\n'))
+	return err
+}
+
+// asciicastEvent returns the line of an asciicast recording data as sent at
+// the time at. Line feeds are recorded as the "\r\n" a terminal's line
+// discipline turns them into, as asciinema players expect.
+func asciicastEvent(at time.Duration, data []byte) []byte {
+	data = bytes.Replace(data, []byte("\n"), []byte("\r\n"), -1)
+	event, _ := json.Marshal([]interface{}{at.Seconds(), "o", string(data)})
+	return append(event, '\n')
+}
+
+// output records data as sent now.
+func (r *asciicastRecorder) output(data []byte) error {
+	event := asciicastEvent(time.Since(r.start), data)
+	if r.size += len(event); r.size > MAX_RECORDING_SIZE {
+		return errRecordingFull
+	}
+	_, err := r.w.Write(event)
+	return err
+}
+
+// close flushes and closes the recording.
+func (r *asciicastRecorder) close() error {
+	err := r.w.Flush()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// record starts recording s in recordDir, returning the recording file name.
+func (s *session) record() (string, error) {
+	if recordDir == "" {
+		return "", errRecordingDisabled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recorder != nil {
+		return s.Recording, nil
+	}
+
+	if err := reserveRecording(); err != nil {
+		return "", err
+	}
+	filename := filepath.Join(recordDir, fmt.Sprintf("session-%d-%s.cast", s.ID, time.Now().Format("20060102-150405")))
+	r, err := newAsciicastRecorder(filename, s.image, fmt.Sprintf("%s %s (%s)", s.Frontend, s.GIF, s.Client))
+	if err != nil {
+		releaseRecording()
+		return "", err
+	}
+	s.recorder, s.Recording = r, filename
+	return filename, nil
+}
+
+// recordOutput adds data sent to s to its recording, if any. A failed
+// recording is stopped without disturbing the stream.
+func (s *session) recordOutput(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recorder == nil {
+		return
+	}
+	if err := s.recorder.output(data); err != nil {
+		log.Printf("Recording %s stopped: %s\n", s.Recording, err)
+		s.recorder.close()
+		s.recorder = nil
+		releaseRecording()
+	}
+}
+
+// stopRecording closes the recording of s, if any.
+func (s *session) stopRecording() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recorder == nil {
+		return
+	}
+	if err := s.recorder.close(); err != nil {
+		log.Printf("Recording %s failed: %s\n", s.Recording, err)
+	}
+	s.recorder = nil
+	releaseRecording()
+}
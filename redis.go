@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+// REDIS_TIMEOUT bounds a Redis command, so a slow Redis server doesn't hold
+// streams back: callers then do the work themselves.
+const REDIS_TIMEOUT = 2 * time.Second
+
+// REDIS_MAX_BULK is the size of the largest bulk string Redis replies with.
+const REDIS_MAX_BULK = 512 << 20
+
+// REDIS_POOL_SIZE is the number of idle Redis connections kept open.
+const REDIS_POOL_SIZE = 8
+
+// REDIS_FRAMES_TTL is how long decoded animations stay in Redis after they
+// were last stored.
+const REDIS_FRAMES_TTL = 24 * time.Hour
+
+// Redis keys.
+const (
+	REDIS_FRAMES_PREFIX = "giflive:frames:" // decoded animation by content hash
+	REDIS_VIEWS_KEY     = "giflive:views"   // hash of view counts by GIF name
+)
+
+// errRedisNil occurs when a Redis key doesn't exist.
+var errRedisNil = errors.New("redis: nil")
+
+// sharedCache is the Redis server instances behind a load balancer share
+// decoded animations and view counts through, nil when disabled.
+var sharedCache *redisClient
+
+// redisClient sends commands to a Redis server over a small pool of
+// connections, with the RESP protocol (https://redis.io/docs/reference/protocol-spec/).
+type redisClient struct {
+	addr string
+	idle chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newRedisClient(addr string) *redisClient {
+	return &redisClient{addr: addr, idle: make(chan *redisConn, REDIS_POOL_SIZE)}
+}
+
+// do sends the command args and returns its reply: a string, an int64, nil,
+// or a []interface{} of those. Error replies are returned as errors.
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	var rc *redisConn
+	select {
+	case rc = <-c.idle:
+	default:
+		conn, err := net.DialTimeout("tcp", c.addr, REDIS_TIMEOUT)
+		if err != nil {
+			return nil, err
+		}
+		rc = &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	}
+	rc.conn.SetDeadline(time.Now().Add(REDIS_TIMEOUT))
+
+	w := bufio.NewWriter(rc.conn)
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := w.Flush(); err != nil {
+		rc.conn.Close()
+		return nil, err
+	}
+	reply, err := readRedisReply(rc.r)
+	if _, ok := err.(redisError); err != nil && !ok {
+		rc.conn.Close() // out of sync
+		return nil, err
+	}
+
+	select {
+	case c.idle <- rc:
+	default:
+		rc.conn.Close()
+	}
+	return reply, err
+}
+
+// redisError is an error reply of the Redis server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readRedisReply reads a RESP reply from r.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: invalid reply")
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, redisError(line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		if n > REDIS_MAX_BULK {
+			return nil, errors.New("redis: invalid reply")
+		}
+		// grown as the data arrives, rather than allocated from n
+		var data bytes.Buffer
+		if _, err := io.CopyN(&data, r, int64(n)+2); err == io.EOF { // and "\r\n"
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+		return string(data.Bytes()[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := []interface{}{}
+		for i := 0; i < n; i++ {
+			item, err := readRedisReply(r)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, errors.New("redis: invalid reply")
+}
+
+// get returns the value of key, or errRedisNil if it doesn't exist.
+func (c *redisClient) get(key string) (string, error) {
+	reply, err := c.do("GET", key)
+	if err != nil {
+		return "", err
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return "", errRedisNil
+	}
+	return value, nil
+}
+
+// loadSharedGIF loads the GIF file filename with ro like loadGIF, from the
+// shared cache if another instance decoded it already. Animations decoded
+// here are added to the shared cache in the background. Instances sharing a
+// cache must run with the same flags.
+func loadSharedGIF(name, filename string, ro renderOptions) (*ansimage.ANSImage, error) {
+	if sharedCache == nil {
+		return loadGIF(name, filename, ro)
+	}
+	key, err := sharedFramesKey(name, filename, ro)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := sharedCache.get(key); err == nil {
+		image := new(ansimage.ANSImage)
+		if err = image.UnmarshalBinary([]byte(data)); err == nil {
+			image.SetRenderHook(frameMetrics.observe)
+			return image, nil
+		}
+		log.Printf("Shared cache entry of %s is invalid: %s\n", name, err)
+	} else if err != errRedisNil {
+		log.Printf("Shared cache unavailable: %s\n", err)
+	}
+
+	image, err := loadGIF(name, filename, ro)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		data, err := image.MarshalBinary()
+		if err == nil {
+			_, err = sharedCache.do("SET", key, string(data), "EX", strconv.Itoa(int(REDIS_FRAMES_TTL/time.Second)))
+		}
+		if err != nil {
+			log.Printf("%s not added to the shared cache: %s\n", name, err)
+		}
+	}()
+	return image, nil
+}
+
+// sharedFramesKey returns the Redis key of the animation decoded from the
+// GIF file filename with ro: a hash of everything the decoding depends on,
+// starting with the file contents, as modification times differ between
+// instances.
+func sharedFramesKey(name, filename string, ro renderOptions) (string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	cfg, err := loadRouteConfig(name)
+	if err != nil {
+		return "", err
+	}
+	route, _ := json.Marshal(cfg)
+
+	h := sha256.New()
+	h.Write(data)
+	fmt.Fprintf(h, "\n%s\n%+v\n%s %g %t %t", route, ro, minFrameDelay, maxFPS, autoCrop, logo != nil)
+	return REDIS_FRAMES_PREFIX + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// countSharedView adds a view of the GIF named gifName to the shared view
+// counts, if there is a shared cache.
+func countSharedView(gifName string) error {
+	if sharedCache == nil {
+		return nil
+	}
+	_, err := sharedCache.do("HINCRBY", REDIS_VIEWS_KEY, gifName, "1")
+	return err
+}
+
+// sharedViews returns the shared view counts by GIF name.
+func sharedViews() (map[string]int, error) {
+	reply, err := sharedCache.do("HGETALL", REDIS_VIEWS_KEY)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]interface{})
+	views := make(map[string]int, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		name, _ := items[i].(string)
+		count, _ := items[i+1].(string)
+		views[name], _ = strconv.Atoi(count)
+	}
+	return views, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRedisReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  interface{}
+		err   bool
+	}{
+		{"status", "+OK\r\n", "OK", false},
+		{"error", "-ERR unknown command\r\n", nil, true},
+		{"integer", ":42\r\n", int64(42), false},
+		{"negative integer", ":-7\r\n", int64(-7), false},
+		{"bulk", "$5\r\nhello\r\n", "hello", false},
+		{"binary bulk", "$4\r\na\r\nb\r\n", "a\r\nb", false},
+		{"empty bulk", "$0\r\n\r\n", "", false},
+		{"nil bulk", "$-1\r\n", nil, false},
+		{"array", "*2\r\n$3\r\nfoo\r\n:1\r\n", []interface{}{"foo", int64(1)}, false},
+		{"nested array", "*2\r\n*1\r\n+a\r\n*0\r\n", []interface{}{[]interface{}{"a"}, []interface{}{}}, false},
+		{"nil array", "*-1\r\n", nil, false},
+		{"no CR", "+OK\n", nil, true},
+		{"no line end", "+OK", nil, true},
+		{"empty line", "\r\n", nil, true},
+		{"unknown type", "!OK\r\n", nil, true},
+		{"bad integer", ":forty\r\n", nil, true},
+		{"bad bulk size", "$five\r\nhello\r\n", nil, true},
+		{"short bulk", "$10\r\nhello\r\n", nil, true},
+		{"huge bulk", fmt.Sprintf("$%d\r\nhello\r\n", REDIS_MAX_BULK+1), nil, true},
+		{"huge array", "*1000000000000\r\n:1\r\n", nil, true},
+		{"short array", "*3\r\n:1\r\n:2\r\n", nil, true},
+		{"error in array", "*2\r\n:1\r\n-ERR\r\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRedisReply(bufio.NewReader(strings.NewReader(tt.reply)))
+			if (err != nil) != tt.err {
+				t.Fatalf("readRedisReply() error = %v, want error %v", err, tt.err)
+			}
+			if !tt.err && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readRedisReply() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"giflive/ansimage"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MAX_COLS and MAX_ROWS bound the terminal size a client may request,
+// as decoding cost grows with it.
+const (
+	MAX_COLS = 400
+	MAX_ROWS = 200
+)
+
+// renderOptions are the settings an animation is decoded with. They are part
+// of the animation cache key, so each combination in use is decoded once.
+type renderOptions struct {
+	rows, cols int // terminal size, in cells
+	dithering  ansimage.DitheringMode
+	scaleMode  ansimage.ScaleMode
+	light      bool // light terminal background (see theme.go)
+}
+
+// defaultRenderOptions returns the render options of clients that ask for nothing else.
+func defaultRenderOptions() renderOptions {
+	return renderOptions{
+		rows:      VT100_HEIGHT,
+		cols:      VT100_WIDTH,
+		dithering: DITHERING_MODE,
+		scaleMode: SCALE_MODE,
+	}
+}
+
+// ditheringModes maps the names used in query parameters to dithering modes.
+var ditheringModes = map[string]ansimage.DitheringMode{
+	"none":      ansimage.NoDithering,
+	"chars":     ansimage.DitheringWithChars,
+	"blocks":    ansimage.DitheringWithBlocks,
+	"braille":   ansimage.DitheringWithBraille,
+	"quadrants": ansimage.DitheringWithQuadrants,
+	"sextants":  ansimage.DitheringWithSextants,
+	"edges":     ansimage.DitheringWithEdges,
+}
+
+// parseRenderOptions returns the default render options overridden by the
+// cols, rows, dither, scale and theme query parameters of the request.
+func parseRenderOptions(c echo.Context) (renderOptions, error) {
+	ro := defaultRenderOptions()
+
+	size := func(param string, max int, v *int) error {
+		if s := c.QueryParam(param); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > max {
+				return fmt.Errorf("Invalid %s %s", param, s)
+			}
+			*v = n
+		}
+		return nil
+	}
+	if err := size("cols", MAX_COLS, &ro.cols); err != nil {
+		return ro, err
+	}
+	if err := size("rows", MAX_ROWS, &ro.rows); err != nil {
+		return ro, err
+	}
+
+	if name := c.QueryParam("dither"); name != "" {
+		dm, ok := ditheringModes[name]
+		if !ok {
+			return ro, fmt.Errorf("Invalid dither mode %s", name)
+		}
+		ro.dithering = dm
+	}
+	if name := c.QueryParam("scale"); name != "" {
+		sm, ok := ansimage.LookupScaleMode(name)
+		if !ok {
+			return ro, fmt.Errorf("Invalid scale mode %s (available: %s)",
+				name, strings.Join(ansimage.ScaleModeNames(), ", "))
+		}
+		ro.scaleMode = sm
+	}
+	if name := c.QueryParam("theme"); name != "" {
+		light, err := parseTheme(name)
+		if err != nil {
+			return ro, err
+		}
+		ro.light = light
+	}
+	return ro, nil
+}
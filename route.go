@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// routeConfig holds per-GIF stream settings, read from an optional JSON file
+// next to the GIF (e.g. gifs/cat.json for gifs/cat.gif):
+//
+//	{"marquee": {"text": "Welcome!", "speed": 8}, "widgets": ["clock", "viewers"]}
+type routeConfig struct {
+	Marquee    *marqueeConfig    `json:"marquee"`
+	Widgets    []string          `json:"widgets"`
+	Credits    *creditsConfig    `json:"credits"`
+	Background *backgroundConfig `json:"background"`
+	ChromaKey  *chromaKeyConfig  `json:"chromakey"`
+	Denoise    int               `json:"denoise"`   // median filter radius, 0 disables
+	Smoothing  float64           `json:"smoothing"` // temporal smoothing strength, 0 to 1
+	Chars      string            `json:"chars"`     // character ramp of ?dither=chars, darkest first
+	BlockSize  *[2]int           `json:"blocksize"` // pixels per cell (rows, columns) of ?dither=blocks and chars
+	Adjust     *adjustConfig     `json:"adjust"`
+	Caption    string            `json:"caption"` // stamped on the bottom row, e.g. the artist
+	Inset      *insetConfig      `json:"inset"`
+	Transform  []string          `json:"transform"` // applied in order, see transforms
+	Zoom       *zoomConfig       `json:"zoom"`
+	Bells      []int             `json:"bells"` // frames (from 0) ringing the terminal bell
+	Link       *linkConfig       `json:"link"`
+
+	// Transparent leaves the transparent pixels undrawn instead of filling
+	// them with the background, so the GIF shows over the terminal background.
+	Transparent bool `json:"transparent"`
+
+	// AlphaThreshold is the opacity (0 to 255) below which the pixels of
+	// transparent GIFs are left undrawn, only fully transparent ones when 0.
+	AlphaThreshold uint8 `json:"alphathreshold"`
+
+	// WarmShift and BurnIn turn on ?warmshift=1 and ?burnin=1 for every
+	// stream of the GIF, including broadcasts, which ignore query options.
+	WarmShift bool `json:"warmshift"`
+	BurnIn    bool `json:"burnin"`
+
+	// Pipeline is run on the loaded GIF, one step per line (e.g. "crop 0 0 20
+	// 40", "filter sepia", "renderer xterm256"), see ansimage.ParsePipeline.
+	Pipeline []string `json:"pipeline"`
+}
+
+// marqueeConfig configures the text crawl along the bottom row.
+type marqueeConfig struct {
+	Text  string  `json:"text"`
+	Speed float64 `json:"speed"` // cells per second
+}
+
+// creditsConfig configures scrolling credits played after the GIF.
+type creditsConfig struct {
+	Text  string  `json:"text"`
+	Speed float64 `json:"speed"` // terminal rows per second
+}
+
+// linkConfig configures a hyperlink to the source or artist page of a GIF,
+// around the art or on a footer line (see hyperlink).
+type linkConfig struct {
+	URL    string `json:"url"`
+	Footer string `json:"footer"` // text of the footer line, empty to link the art
+}
+
+// backgroundConfig configures a two-colour gradient filling transparent areas
+// and letterbox bars.
+type backgroundConfig struct {
+	From       string `json:"from"` // "#rrggbb"
+	To         string `json:"to"`
+	Horizontal bool   `json:"horizontal"`
+}
+
+// gradient converts the configuration to an ansimage.Gradient.
+func (bc *backgroundConfig) gradient() (ansimage.Gradient, error) {
+	from, err := parseHexColour(bc.From)
+	if err != nil {
+		return ansimage.Gradient{}, err
+	}
+	to, err := parseHexColour(bc.To)
+	if err != nil {
+		return ansimage.Gradient{}, err
+	}
+	return ansimage.Gradient{From: from, To: to, Horizontal: bc.Horizontal}, nil
+}
+
+// chromaKeyConfig configures a colour treated as transparent, for GIFs
+// exported on a solid background.
+type chromaKeyConfig struct {
+	Colour    string `json:"colour"` // "#rrggbb"
+	Tolerance uint8  `json:"tolerance"`
+}
+
+// adjustConfig corrects the tone of a GIF, see ansimage.WithAdjustment.
+type adjustConfig struct {
+	Gamma      float64 `json:"gamma"`      // 1 (or 0) keeps the midtones
+	Brightness float64 `json:"brightness"` // percent, -100 to 100
+	Contrast   float64 `json:"contrast"`   // percent, -100 to 100
+}
+
+// insetConfig configures another GIF played in the top-right corner
+// (picture-in-picture), see ansimage.ANSImage.Composite.
+type insetConfig struct {
+	GIF   string `json:"gif"`
+	Rows  int    `json:"rows"`  // terminal rows, a third of the stream by default
+	Cols  int    `json:"cols"`  // terminal columns, a third of the stream by default
+	Blend string `json:"blend"` // see blendModes
+}
+
+// zoomConfig shows a part of a GIF, enlarged by Factor: the GIF is scaled to
+// Factor times the stream size, then cropped to the stream around Center.
+type zoomConfig struct {
+	Factor float64     `json:"factor"`
+	Center *[2]float64 `json:"center"` // (row, column), fractions of the GIF, its middle by default
+}
+
+// blendModes maps the blend names of insetConfig to blend modes.
+var blendModes = map[string]ansimage.BlendMode{
+	"":           ansimage.BlendNormal,
+	"normal":     ansimage.BlendNormal,
+	"multiply":   ansimage.BlendMultiply,
+	"screen":     ansimage.BlendScreen,
+	"lighten":    ansimage.BlendLighten,
+	"darken":     ansimage.BlendDarken,
+	"difference": ansimage.BlendDifference,
+}
+
+// transforms maps the names of the transform setting to geometric transforms.
+var transforms = map[string]ansimage.Transform{
+	"rotate90":  ansimage.TransformRotate90,
+	"rotate180": ansimage.TransformRotate180,
+	"rotate270": ansimage.TransformRotate270,
+	"fliph":     ansimage.TransformFlipH,
+	"flipv":     ansimage.TransformFlipV,
+}
+
+// errInvalidColour occurs when a colour isn't written as "#rrggbb".
+var errInvalidColour = errors.New("colour must look like #rrggbb")
+
+// parseHexColour parses a colour written as "#rrggbb".
+func parseHexColour(s string) (color.RGBA, error) {
+	var c color.RGBA
+	if len(s) != 7 || s[0] != '#' {
+		return c, errInvalidColour
+	}
+	if _, err := fmt.Sscanf(s[1:], "%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+		return c, errInvalidColour
+	}
+	c.A = 0xff
+	return c, nil
+}
+
+// loadRouteConfig reads the route configuration of the GIF named name.
+// A missing file yields the zero configuration.
+func loadRouteConfig(name string) (routeConfig, error) {
+	var cfg routeConfig
+	data, err := ioutil.ReadFile(filepath.Join(GIF_DIR, name+".json"))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(data, &cfg)
+	return cfg, err
+}
+
+// routeStamp returns the modification time, in nanoseconds since the Unix
+// epoch, and the size of the route configuration of the GIF named name, or
+// zeros without one.
+func routeStamp(name string) (int64, int64, error) {
+	info, err := os.Stat(filepath.Join(GIF_DIR, name+".json"))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	return info.ModTime().UnixNano(), info.Size(), nil
+}
+
+// applyRoute sets the route-specific options of the GIF named name.
+func (opts *playOptions) applyRoute(name string) error {
+	cfg, err := loadRouteConfig(name)
+	if err != nil {
+		return err
+	}
+	if cfg.Marquee != nil && cfg.Marquee.Text != "" {
+		opts.marquee = newMarquee(cfg.Marquee.Text, cfg.Marquee.Speed)
+	}
+	if len(cfg.Bells) > 0 {
+		opts.bells = make(map[int]bool, len(cfg.Bells))
+		for _, frame := range cfg.Bells {
+			opts.bells[frame] = true
+		}
+	}
+	if opts.link, err = newHyperlink(cfg.Link); err != nil {
+		return err
+	}
+	opts.transparent = cfg.Transparent
+	opts.warmShift, opts.burnIn = cfg.WarmShift, cfg.BurnIn
+	pipeline, err := ansimage.ParsePipeline(cfg.Pipeline)
+	if err != nil {
+		return err
+	}
+	if opts.renderer, opts.ansi16, err = parseFormat(pipeline.Renderer); err != nil {
+		return err
+	}
+	opts.widgets, err = parseWidgets(cfg.Widgets)
+	opts.broadcast = broadcastMode
+	opts.name = name
+	opts.renderSlots = animations.lane(name).render
+	return err
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"giflive/player"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// adminToken is the bearer token of the admin API (empty disables the API).
+var adminToken string
+
+// errSessionKilled occurs when an operator kills a session with the admin API.
+var errSessionKilled = errors.New("session killed")
+
+// errRecordingDisabled occurs when a recording is requested without a record directory.
+var errRecordingDisabled = errors.New("recording disabled")
+
+// session is an active stream, as listed by the admin API.
+type session struct {
+	ID       uint64    `json:"id"`
+	Frontend string    `json:"frontend"`
+	Client   string    `json:"client"`
+	GIF      string    `json:"gif"`
+	Start    time.Time `json:"start"`
+	Bytes    int64     `json:"bytes"` // frame bytes sent, updated atomically
+
+	// Recording is the file the session is recorded to (see record.go).
+	Recording string `json:"recording,omitempty"`
+
+	image  ansimage.Animation
+	cancel context.CancelFunc
+	killed int32
+
+	mu       sync.Mutex // guards recorder and Recording
+	recorder *asciicastRecorder
+}
+
+// sessionRegistry tracks the active streams of every frontend, so operators
+// can find and drop abusive or stuck ones without restarting the server.
+type sessionRegistry struct {
+	mu       sync.Mutex
+	lastID   uint64
+	sessions map[uint64]*session
+}
+
+// activeSessions is shared by every frontend, like animations.
+var activeSessions = &sessionRegistry{sessions: make(map[uint64]*session)}
+
+// playSession plays image to t like playAnimation, as a session of the
+// registry opened by frontend for client watching gif. It returns
+// errSessionKilled if the session was killed.
+func playSession(ctx context.Context, frontend, client, gif string, t player.Transport, image ansimage.Animation, opts playOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s := activeSessions.add(&session{
+		Frontend: frontend,
+		Client:   client,
+		GIF:      gif,
+		Start:    time.Now(),
+		image:    image,
+		cancel:   cancel,
+	})
+	defer activeSessions.remove(s)
+	defer s.stopRecording()
+	if err := countView(gif); err != nil {
+		log.Printf("View of %s not counted: %s\n", gif, err)
+	}
+	if err := countSharedView(gif); err != nil {
+		log.Printf("View of %s not shared: %s\n", gif, err)
+	}
+	if opts.record {
+		if filename, err := s.record(); err != nil {
+			log.Printf("Session %d not recorded: %s\n", s.ID, err)
+		} else {
+			log.Printf("Recording session %d to %s\n", s.ID, filename)
+		}
+	}
+
+	err := playAnimation(ctx, countingTransport{t, s}, image, opts)
+	if atomic.LoadInt32(&s.killed) == 1 {
+		return errSessionKilled
+	}
+	return err
+}
+
+// add registers s under a new ID.
+func (r *sessionRegistry) add(s *session) *session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastID++
+	s.ID = r.lastID
+	r.sessions[s.ID] = s
+	return s
+}
+
+func (r *sessionRegistry) remove(s *session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, s.ID)
+}
+
+// list returns a snapshot of the sessions, oldest first.
+func (r *sessionRegistry) list() []session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]session, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		s.mu.Lock()
+		recording := s.Recording
+		s.mu.Unlock()
+		list = append(list, session{
+			ID:        s.ID,
+			Frontend:  s.Frontend,
+			Client:    s.Client,
+			GIF:       s.GIF,
+			Start:     s.Start,
+			Bytes:     atomic.LoadInt64(&s.Bytes),
+			Recording: recording,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// count returns the number of active sessions.
+func (r *sessionRegistry) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sessions)
+}
+
+// get returns the session id, or nil if there is none.
+func (r *sessionRegistry) get(id uint64) *session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions[id]
+}
+
+// kill stops the session id, reporting false if there is none.
+func (r *sessionRegistry) kill(id uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	if !ok {
+		return false
+	}
+	atomic.StoreInt32(&s.killed, 1)
+	s.cancel()
+	return true
+}
+
+// countingTransport adds the frame bytes sent over a transport to its session.
+type countingTransport struct {
+	player.Transport
+	s *session
+}
+
+func (t countingTransport) WriteFrame(frame []byte, delay time.Duration) error {
+	err := t.Transport.WriteFrame(frame, delay)
+	if err == nil {
+		atomic.AddInt64(&t.s.Bytes, int64(len(frame)))
+		t.s.recordOutput(frame)
+	}
+	return err
+}
+
+// registerAdminRoutes adds the admin API to e if adminToken is set:
+//
+//	GET    /admin/sessions      list the active sessions as JSON
+//	DELETE /admin/sessions/:ID  kill a session
+//	POST   /admin/sessions/:ID/record  record a session (see record.go)
+//	GET    /admin/views         list the view counts by GIF (see store.go and redis.go)
+//	GET    /admin/uploads       list the upload records by GIF
+//	POST   /admin/keys?name=N   create an upload API key
+func registerAdminRoutes(e *echo.Echo) {
+	if adminToken == "" {
+		return
+	}
+	admin := e.Group("/admin", adminAuthMiddleware)
+	admin.GET("/sessions", listSessionsHandler)
+	admin.DELETE("/sessions/:ID", killSessionHandler)
+	admin.POST("/sessions/:ID/record", recordSessionHandler)
+	admin.GET("/views", listViewsHandler)
+	admin.GET("/uploads", storeHandler(listUploadsHandler))
+	admin.POST("/keys", storeHandler(createAPIKeyHandler))
+}
+
+// adminAuthMiddleware refuses requests without the admin token as bearer token.
+func adminAuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !isAdminRequest(c) {
+			return c.String(http.StatusUnauthorized, "Unauthorized.\n")
+		}
+		return next(c)
+	}
+}
+
+// isAdminRequest reports whether c carries the admin token.
+func isAdminRequest(c echo.Context) bool {
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return adminToken != "" && token != auth &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+func listSessionsHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, activeSessions.list())
+}
+
+func killSessionHandler(c echo.Context) error {
+	param := c.Param("ID")
+	id, err := strconv.ParseUint(param, 10, 64)
+	if err != nil || !activeSessions.kill(id) {
+		return c.String(http.StatusNotFound, fmt.Sprintf("Session %s not found.\n", ansimage.SanitizeText(param)))
+	}
+	log.Printf("Session %d killed by %s\n", id, c.RealIP())
+	return c.NoContent(http.StatusNoContent)
+}
+
+func recordSessionHandler(c echo.Context) error {
+	param := c.Param("ID")
+	id, err := strconv.ParseUint(param, 10, 64)
+	s := activeSessions.get(id)
+	if err != nil || s == nil {
+		return c.String(http.StatusNotFound, fmt.Sprintf("Session %s not found.\n", ansimage.SanitizeText(param)))
+	}
+	filename, err := s.record()
+	if err == errRecordingDisabled {
+		return c.String(http.StatusNotImplemented, "Recording is disabled.\n")
+	} else if err == errTooManyRecordings || err == errRecordDirFull {
+		return c.String(http.StatusServiceUnavailable, fmt.Sprintf("Recording error: %s.\n", err.Error()))
+	} else if err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("Recording error: %s.\n", err.Error()))
+	}
+	log.Printf("Recording session %d to %s for %s\n", id, filename, c.RealIP())
+	return c.String(http.StatusOK, filename+"\n")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIME_EVENT_STREAM is the content type of server-sent events.
+const MIME_EVENT_STREAM = "text/event-stream"
+
+// wantsEventStream reports whether the client asks for server-sent events,
+// like the EventSource of browsers.
+func wantsEventStream(c echo.Context) bool {
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), MIME_EVENT_STREAM)
+}
+
+// sseTransport sends frames as server-sent events, for web pages feeding a
+// terminal emulator like xterm.js. Each frame is a "frame" event whose id is
+// the frame count and whose data lines are the lines of the frame:
+//
+//	id: 3
+//	event: frame
+//	data: <line>
+//	data: <line>
+//
+// The stream ends with a "close" event carrying the reason, if any, so the
+// page can stop EventSource from reconnecting.
+type sseTransport struct {
+	w      io.Writer
+	frames int
+}
+
+func (t *sseTransport) WriteFrame(frame []byte, delay time.Duration) error {
+	t.frames++
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "id: %d\nevent: frame\n", t.frames)
+	writeEventData(&b, frame)
+	return t.send(b.Bytes())
+}
+
+func (t *sseTransport) Close(reason string) error {
+	var b bytes.Buffer
+	fmt.Fprint(&b, "event: close\n")
+	writeEventData(&b, []byte(reason))
+	return t.send(b.Bytes())
+}
+
+// send writes an event in a single write, then flushes it.
+func (t *sseTransport) send(event []byte) error {
+	if _, err := t.w.Write(event); err != nil {
+		return err
+	}
+	if flusher, ok := t.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// writeEventData writes data as the data lines of an event, then the blank
+// line ending it. A trailing newline doesn't make an empty last line.
+func writeEventData(b *bytes.Buffer, data []byte) {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+}
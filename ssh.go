@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"giflive/ansimage"
+	"giflive/player"
+	"io/ioutil"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH_HANDSHAKE_TIMEOUT bounds how long SSH clients take to log in.
+const SSH_HANDSHAKE_TIMEOUT = 10 * time.Second
+
+// sshFrontend streams animations over SSH. The login name selects the GIF,
+// e.g. `ssh -p 2222 cat@localhost`. No authentication is required.
+type sshFrontend struct {
+	addr     string
+	config   *ssh.ServerConfig
+	ln       net.Listener
+	sessions sessionGroup
+}
+
+// newSSHFrontend creates an SSH frontend using the private key in hostKeyFile,
+// or a freshly generated key when hostKeyFile is empty.
+func newSSHFrontend(addr, hostKeyFile string) (*sshFrontend, error) {
+	var signer ssh.Signer
+	if hostKeyFile != "" {
+		pem, err := ioutil.ReadFile(hostKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if signer, err = ssh.ParsePrivateKey(pem); err != nil {
+			return nil, err
+		}
+	} else {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		if signer, err = ssh.NewSignerFromKey(key); err != nil {
+			return nil, err
+		}
+		log.Println("SSH frontend uses an ephemeral host key")
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+	return &sshFrontend{addr: addr, config: config}, nil
+}
+
+func (s *sshFrontend) Name() string {
+	return "SSH"
+}
+
+func (s *sshFrontend) Listen() error {
+	var err error
+	s.ln, err = upgrades.listen(s.addr)
+	return err
+}
+
+func (s *sshFrontend) Serve(ctx context.Context) error {
+	ln := s.ln
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.sessions.wait()
+				return nil
+			}
+			return err
+		}
+		go s.handle(ctx, conn)
+	}
+}
+
+func (s *sshFrontend) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	if blocklist.blocked(conn.RemoteAddr().String()) {
+		return
+	}
+
+	conn.SetDeadline(time.Now().Add(SSH_HANDSHAKE_TIMEOUT))
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		if !s.sessions.add() {
+			channel.Close()
+			continue
+		}
+		go func() {
+			defer s.sessions.done()
+			s.session(ctx, conn, ansimage.SanitizeText(sconn.User()), channel, requests)
+		}()
+	}
+}
+
+// session plays the GIF named gifName once the client asks for a shell or command.
+func (s *sshFrontend) session(ctx context.Context, conn net.Conn, gifName string, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	// The session ends when the client closes its side of the channel.
+	server := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	started := make(chan bool, 1) // whether the client asked for a pty
+	go func() {
+		defer cancel()
+		pty, once := false, sync.Once{}
+		for req := range requests {
+			switch req.Type {
+			case "shell", "exec":
+				req.Reply(true, nil)
+				once.Do(func() { started <- pty })
+			case "pty-req":
+				pty = true
+				req.Reply(true, nil)
+			case "env", "window-change":
+				req.Reply(true, nil)
+			default:
+				req.Reply(false, nil)
+			}
+		}
+	}()
+
+	var pty bool
+	select {
+	case pty = <-started:
+	case <-ctx.Done():
+		return
+	}
+
+	w := crlfWriter{channel}
+	status := struct{ Status uint32 }{0}
+	defer func() {
+		channel.SendRequest("exit-status", false, ssh.Marshal(&status))
+	}()
+
+	// Terminals answer an OSC 11 query with their background colour, to
+	// adapt the animation to light ones.
+	ro := defaultRenderOptions()
+	if pty {
+		ro.light, _ = queryLightBackground(channel)
+	}
+
+	image, err := animations.Get(gifName, ro)
+	if err == errGIFNotFound {
+		fmt.Fprintf(w, "GIF image %s not found.\n", gifName)
+		status.Status = 1
+		return
+	} else if err == errGIFBroken {
+		fmt.Fprintf(w, "GIF image %s is broken.\n", gifName)
+		status.Status = 1
+		return
+	} else if err != nil {
+		fmt.Fprintf(w, "GIF image load error: %s.\n", err.Error())
+		status.Status = 1
+		return
+	}
+
+	var opts playOptions
+	if err := opts.applyRoute(gifName); err != nil {
+		fmt.Fprintf(w, "Route config error: %s.\n", err.Error())
+		status.Status = 1
+		return
+	}
+
+	// Channel writes can block on the SSH window as well as on TCP, so a
+	// stalled session is cut by closing the whole connection.
+	deadline := &closeOnDeadline{conn: conn}
+	defer deadline.SetWriteDeadline(time.Time{})
+	stream := player.StreamTransport{W: crlfWriter{deadlineWriter{channel, deadline, writeTimeout}}}
+
+	err = playSession(ctx, "ssh", conn.RemoteAddr().String(), gifName, stream, image, opts)
+	if deadline.Expired() {
+		log.Println("SSH client stalled, disconnecting")
+		return // the connection is closed
+	}
+	log.Println("SSH client stopped listening")
+	closeStream(stream, server, err)
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store buckets.
+const (
+	VIEWS_BUCKET    = "views"    // view count by GIF name
+	UPLOADS_BUCKET  = "uploads"  // uploadRecord by GIF name
+	API_KEYS_BUCKET = "api_keys" // apiKey by SHA-256 of the key
+)
+
+// storeBuckets are created when a store is opened.
+var storeBuckets = []string{VIEWS_BUCKET, UPLOADS_BUCKET, API_KEYS_BUCKET}
+
+// Store persists small records, like view counters, upload records and API
+// keys, as values stored by key in a few buckets.
+type Store interface {
+	// Get returns the value of key in bucket, or errNotStored if there is none.
+	Get(bucket, key string) ([]byte, error)
+
+	// Put sets the value of key in bucket.
+	Put(bucket, key string, value []byte) error
+
+	// List returns the values of bucket by key.
+	List(bucket string) (map[string][]byte, error)
+
+	Close() error
+}
+
+// errNotStored occurs when a key isn't in a store.
+var errNotStored = errors.New("not stored")
+
+// errUnknownStore occurs when a -store flag names no known store.
+var errUnknownStore = errors.New("unknown store (available: bolt:FILE, sqlite:FILE)")
+
+// errInvalidAPIKey occurs when an upload lacks a valid API key.
+var errInvalidAPIKey = errors.New("invalid API key")
+
+// store is the store of the server, nil when persistence is disabled.
+var store Store
+
+// openStore opens the store described by spec, like "bolt:giflive.db" or
+// "sqlite:giflive.sqlite".
+func openStore(spec string) (Store, error) {
+	i := strings.IndexByte(spec, ':')
+	if i < 0 || i == len(spec)-1 {
+		return nil, errUnknownStore
+	}
+	switch kind, filename := spec[:i], spec[i+1:]; kind {
+	case "bolt":
+		return openBoltStore(filename)
+	case "sqlite":
+		return openSQLiteStore(filename)
+	}
+	return nil, errUnknownStore
+}
+
+// boltStore is a Store in a bbolt file, one bolt bucket per bucket.
+type boltStore struct {
+	db *bolt.DB
+}
+
+func openBoltStore(filename string) (*boltStore, error) {
+	db, err := bolt.Open(filename, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range storeBuckets {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Get(bucket, key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(bucket)).Get([]byte(key))
+		if v == nil {
+			return errNotStored
+		}
+		value = append([]byte(nil), v...) // only valid during the transaction
+		return nil
+	})
+	return value, err
+}
+
+func (s *boltStore) Put(bucket, key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Put([]byte(key), value)
+	})
+}
+
+func (s *boltStore) List(bucket string) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).ForEach(func(k, v []byte) error {
+			values[string(k)] = append([]byte(nil), v...)
+			return nil
+		})
+	})
+	return values, err
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+// viewsMu serializes view counter increments, which read then write.
+var viewsMu sync.Mutex
+
+// countView adds a view of the GIF named gifName to the store, if any.
+func countView(gifName string) error {
+	if store == nil {
+		return nil
+	}
+	viewsMu.Lock()
+	defer viewsMu.Unlock()
+	views := 0
+	if value, err := store.Get(VIEWS_BUCKET, gifName); err == nil {
+		views, _ = strconv.Atoi(string(value))
+	} else if err != errNotStored {
+		return err
+	}
+	return store.Put(VIEWS_BUCKET, gifName, []byte(strconv.Itoa(views+1)))
+}
+
+// uploadRecord describes an uploaded GIF.
+type uploadRecord struct {
+	Client   string    `json:"client"`
+	Uploader string    `json:"uploader,omitempty"` // name of the API key used
+	Time     time.Time `json:"time"`
+	Size     int       `json:"size"`
+	Pending  bool      `json:"pending"` // held for review
+}
+
+// uploadedBytes returns the size of the GIFs uploaded with the API key named
+// uploader, from the upload records.
+func uploadedBytes(uploader string) (int64, error) {
+	if store == nil {
+		return 0, nil
+	}
+	records, err := store.List(UPLOADS_BUCKET)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, value := range records {
+		var record uploadRecord
+		if json.Unmarshal(value, &record) == nil && record.Uploader == uploader {
+			size += int64(record.Size)
+		}
+	}
+	return size, nil
+}
+
+// recordUpload stores the record of the upload of the GIF named gifName, if
+// there is a store.
+func recordUpload(gifName string, record uploadRecord) error {
+	if store == nil {
+		return nil
+	}
+	value, _ := json.Marshal(record)
+	return store.Put(UPLOADS_BUCKET, gifName, value)
+}
+
+// apiKey describes an API key allowed to upload GIFs. Keys are stored by
+// their SHA-256 only.
+type apiKey struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+// apiKeyID returns the store key of the API key key.
+func apiKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// uploadKeyName checks the bearer token of an upload request, returning the
+// name of its API key, or an empty name for the admin token. Without either,
+// nobody may upload.
+func uploadKeyName(c echo.Context) (string, error) {
+	if isAdminRequest(c) {
+		return "", nil
+	}
+	if store == nil {
+		return "", errInvalidAPIKey
+	}
+	keys, err := store.List(API_KEYS_BUCKET)
+	if err != nil {
+		return "", err
+	}
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	token := strings.TrimPrefix(auth, "Bearer ")
+	value, ok := keys[apiKeyID(token)]
+	if token == auth || !ok {
+		return "", errInvalidAPIKey
+	}
+	var key apiKey
+	if err := json.Unmarshal(value, &key); err != nil {
+		return "", err
+	}
+	return key.Name, nil
+}
+
+// storeHandler returns an admin API handler calling h if there is a store.
+func storeHandler(h echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if store == nil {
+			return c.String(http.StatusNotImplemented, "Persistence is disabled.\n")
+		}
+		return h(c)
+	}
+}
+
+// listViewsHandler lists the view counts of all instances if they share a
+// cache, or else the ones of the store.
+func listViewsHandler(c echo.Context) error {
+	if sharedCache != nil {
+		views, err := sharedViews()
+		if err != nil {
+			return c.String(http.StatusBadGateway, fmt.Sprintf("Shared cache error: %s.\n", err.Error()))
+		}
+		return c.JSON(http.StatusOK, views)
+	}
+	if store == nil {
+		return c.String(http.StatusNotImplemented, "Persistence is disabled.\n")
+	}
+	values, err := store.List(VIEWS_BUCKET)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("Store error: %s.\n", err.Error()))
+	}
+	views := make(map[string]int, len(values))
+	for name, value := range values {
+		views[name], _ = strconv.Atoi(string(value))
+	}
+	return c.JSON(http.StatusOK, views)
+}
+
+func listUploadsHandler(c echo.Context) error {
+	values, err := store.List(UPLOADS_BUCKET)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("Store error: %s.\n", err.Error()))
+	}
+	uploads := make(map[string]uploadRecord, len(values))
+	for name, value := range values {
+		var record uploadRecord
+		if err := json.Unmarshal(value, &record); err == nil {
+			uploads[name] = record
+		}
+	}
+	return c.JSON(http.StatusOK, uploads)
+}
+
+// createAPIKeyHandler creates an upload API key named by the name query
+// parameter, and returns it. Only its SHA-256 is stored.
+func createAPIKeyHandler(c echo.Context) error {
+	name := ansimage.SanitizeText(c.QueryParam("name"))
+	if name == "" {
+		return c.String(http.StatusBadRequest, "Missing API key name.\n")
+	}
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("API key error: %s.\n", err.Error()))
+	}
+	key := hex.EncodeToString(secret)
+	value, _ := json.Marshal(apiKey{Name: name, Created: time.Now()})
+	if err := store.Put(API_KEYS_BUCKET, apiKeyID(key), value); err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("Store error: %s.\n", err.Error()))
+	}
+	log.Printf("API key %s created by %s\n", name, c.RealIP())
+	return c.String(http.StatusCreated, key+"\n")
+}
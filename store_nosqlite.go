@@ -0,0 +1,14 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+import "errors"
+
+// errNoSQLite occurs when the sqlite: store is asked of a build without cgo,
+// which the SQLite driver needs.
+var errNoSQLite = errors.New("the sqlite store needs a build with cgo (CGO_ENABLED=1)")
+
+func openSQLiteStore(filename string) (Store, error) {
+	return nil, errNoSQLite
+}
@@ -0,0 +1,76 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3" // initialize driver
+)
+
+// sqliteStore is a Store in an SQLite database, one table per bucket.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLiteStore(filename string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range storeBuckets {
+		if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, value BLOB NOT NULL)", bucket)); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// table returns the table of bucket. Buckets are constants, never user input.
+func (s *sqliteStore) table(bucket string) string {
+	for _, b := range storeBuckets {
+		if b == bucket {
+			return b
+		}
+	}
+	panic("unknown bucket " + bucket)
+}
+
+func (s *sqliteStore) Get(bucket, key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRow("SELECT value FROM "+s.table(bucket)+" WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, errNotStored
+	}
+	return value, err
+}
+
+func (s *sqliteStore) Put(bucket, key string, value []byte) error {
+	_, err := s.db.Exec("INSERT OR REPLACE INTO "+s.table(bucket)+" (key, value) VALUES (?, ?)", key, value)
+	return err
+}
+
+func (s *sqliteStore) List(bucket string) (map[string][]byte, error) {
+	rows, err := s.db.Query("SELECT key, value FROM " + s.table(bucket))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := make(map[string][]byte)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, rows.Err()
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"giflive/player"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// PROMPT_TIMEOUT bounds how long telnet clients take to send the GIF name.
+const PROMPT_TIMEOUT = 30 * time.Second
+
+// telnetFrontend streams animations over raw TCP connections.
+// Clients type the GIF name at the prompt, e.g. `telnet localhost 2323`.
+type telnetFrontend struct {
+	addr     string
+	ln       net.Listener
+	sessions sessionGroup
+}
+
+func newTelnetFrontend(addr string) *telnetFrontend {
+	return &telnetFrontend{addr: addr}
+}
+
+func (t *telnetFrontend) Name() string {
+	return "telnet"
+}
+
+func (t *telnetFrontend) Listen() error {
+	var err error
+	t.ln, err = upgrades.listen(t.addr)
+	return err
+}
+
+func (t *telnetFrontend) Serve(ctx context.Context) error {
+	ln := t.ln
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				t.sessions.wait()
+				return nil
+			}
+			return err
+		}
+		if !t.sessions.add() {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer t.sessions.done()
+			t.handle(ctx, conn)
+		}()
+	}
+}
+
+func (t *telnetFrontend) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	if blocklist.blocked(conn.RemoteAddr().String()) {
+		return
+	}
+	w := crlfWriter{conn}
+
+	// The name fits the buffer with its line end, or isn't a GIF name.
+	conn.SetReadDeadline(time.Now().Add(PROMPT_TIMEOUT))
+	fmt.Fprint(w, "GIF name: ")
+	reader := bufio.NewReaderSize(conn, 64+2)
+	line, err := reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		fmt.Fprint(w, "GIF name too long.\n")
+		return
+	} else if err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	gifName := strings.TrimFunc(string(line), func(r rune) bool {
+		return r < '!' || r > '~' // whitespace and telnet negotiation bytes
+	})
+
+	image, err := animations.Get(gifName, defaultRenderOptions())
+	if err == errGIFNotFound {
+		fmt.Fprintf(w, "GIF image %s not found.\n", gifName)
+		return
+	} else if err == errGIFBroken {
+		fmt.Fprintf(w, "GIF image %s is broken.\n", gifName)
+		return
+	} else if err != nil {
+		fmt.Fprintf(w, "GIF image load error: %s.\n", err.Error())
+		return
+	}
+
+	var opts playOptions
+	if err := opts.applyRoute(gifName); err != nil {
+		fmt.Fprintf(w, "Route config error: %s.\n", err.Error())
+		return
+	}
+
+	// The client sends nothing more; a finished read means it hung up.
+	server := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		io.Copy(ioutil.Discard, reader)
+		cancel()
+	}()
+
+	stream := player.StreamTransport{W: crlfWriter{deadlineWriter{conn, conn, writeTimeout}}}
+	err = playSession(ctx, "telnet", conn.RemoteAddr().String(), gifName, stream, image, opts)
+	if isTimeout(err) {
+		log.Println("Telnet client stalled, disconnecting")
+	} else {
+		log.Println("Telnet client stopped listening")
+	}
+	closeStream(stream, server, err)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LIGHT_BACKGROUND_COLOUR replaces BACKGROUND_COLOUR for light terminals, so
+// transparent areas blend in instead of showing as a black box.
+var LIGHT_BACKGROUND_COLOUR = color.White
+
+// LIGHT_CHAR_RAMP is the default character ramp of chars dithering, reversed
+// for a light background: bright pixels get sparse characters.
+const LIGHT_CHAR_RAMP = "#&$Xx=+;:. "
+
+// OSC11_TIMEOUT is how long to wait for a terminal to report its background
+// colour. Terminals that don't support the query never answer.
+const OSC11_TIMEOUT = 500 * time.Millisecond
+
+// parseTheme returns whether the terminal theme named name is light.
+func parseTheme(name string) (bool, error) {
+	switch name {
+	case "dark":
+		return false, nil
+	case "light":
+		return true, nil
+	}
+	return false, fmt.Errorf("Invalid theme %s", name)
+}
+
+// queryLightBackground asks the terminal on rw for its background colour
+// with an OSC 11 query, and reports whether it is light. ok is false if the
+// terminal didn't answer within OSC11_TIMEOUT. rw is read from until it
+// answers or fails, even after the timeout.
+func queryLightBackground(rw io.ReadWriter) (light, ok bool) {
+	if _, err := io.WriteString(rw, "\033]11;?\033\\"); err != nil {
+		return false, false
+	}
+
+	reply := make(chan color.Color, 1)
+	go func() {
+		var buf []byte
+		b := make([]byte, 64)
+		for len(buf) < 256 {
+			n, err := rw.Read(b)
+			buf = append(buf, b[:n]...)
+			if c, ok := parseOSC11Reply(buf); ok {
+				reply <- c
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case c := <-reply:
+		return isLight(c), true
+	case <-time.After(OSC11_TIMEOUT):
+		return false, false
+	}
+}
+
+// parseOSC11Reply parses the answer to an OSC 11 query found in buf, like
+// "\033]11;rgb:ffff/ffff/dddd\033\\" (or terminated by BEL).
+func parseOSC11Reply(buf []byte) (color.Color, bool) {
+	start := bytes.Index(buf, []byte("\033]11;rgb:"))
+	if start < 0 {
+		return nil, false
+	}
+	spec := buf[start+len("\033]11;rgb:"):]
+	end := bytes.IndexAny(spec, "\a\033")
+	if end < 0 {
+		return nil, false
+	}
+
+	parts := strings.Split(string(spec[:end]), "/")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	var rgb [3]uint16
+	for i, part := range parts {
+		// 1 to 4 hex digits, scaled to 16 bits
+		if len(part) < 1 || len(part) > 4 {
+			return nil, false
+		}
+		v, err := strconv.ParseUint(part, 16, 16)
+		if err != nil {
+			return nil, false
+		}
+		max := uint64(1)<<(4*uint(len(part))) - 1
+		rgb[i] = uint16(v * 0xffff / max)
+	}
+	return color.RGBA64{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xffff}, true
+}
+
+// isLight reports whether c is a light background colour, by its relative
+// luminance.
+func isLight(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return 0.2126*float64(r)+0.7152*float64(g)+0.0722*float64(b) > 0xffff/2
+}
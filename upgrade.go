@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UPGRADE_ENV tells a process started by an upgrade which inherited file
+// descriptors are listening sockets: their addresses, comma-separated, for
+// the descriptors from 3 on. The next descriptor is a pipe to the old
+// process, written to once the new process serves.
+const UPGRADE_ENV = "GIFLIVE_LISTENERS"
+
+// UPGRADE_TIMEOUT bounds how long the old process waits for the new one to
+// start serving before giving up the upgrade.
+const UPGRADE_TIMEOUT = 30 * time.Second
+
+// upgradeDrain is how long the streams of an upgraded process may go on
+// before being asked to reconnect.
+var upgradeDrain = 10 * time.Minute
+
+// pidFile is the file the process writes its PID to once it serves, for
+// supervisors to follow upgrades (empty to disable).
+var pidFile string
+
+var (
+	// errUpgraded occurs when a process that handed its listeners off is upgraded again.
+	errUpgraded = errors.New("already upgraded")
+
+	// errUpgradeBolt occurs when upgrading a server with a bolt store, which
+	// only one process may open at a time.
+	errUpgradeBolt = errors.New("a bolt store can't be shared with the new process, use an SQLite store")
+
+	// errUpgradeFailed occurs when the new process exited before serving.
+	errUpgradeFailed = errors.New("the new process failed to start")
+
+	// errUpgradeTimeout occurs when the new process didn't serve within UPGRADE_TIMEOUT.
+	errUpgradeTimeout = errors.New("the new process didn't start in time")
+)
+
+// upgrader hands the listening sockets of the frontends off to a new process
+// running the binary again, usually a new version of it, for restarts without
+// refusing connections (like github.com/cloudflare/tableflip). The old
+// process stops accepting connections, but its streams play on until they
+// end or upgradeDrain passes.
+type upgrader struct {
+	mu        sync.Mutex
+	listeners []*handoffListener
+	inherited map[string]*os.File // listening sockets of the old process, by address
+	ready     *os.File            // pipe to the old process
+	upgraded  chan struct{}       // closed once the listeners were handed off
+}
+
+// upgrades is the upgrader of the process, set up from UPGRADE_ENV.
+var upgrades = newUpgrader()
+
+func newUpgrader() *upgrader {
+	u := &upgrader{inherited: make(map[string]*os.File), upgraded: make(chan struct{})}
+	env := os.Getenv(UPGRADE_ENV)
+	if env == "" {
+		return u
+	}
+	os.Unsetenv(UPGRADE_ENV)
+	addrs := strings.Split(env, ",")
+	for i, addr := range addrs {
+		u.inherited[addr] = os.NewFile(uintptr(3+i), "listener "+addr)
+	}
+	u.ready = os.NewFile(uintptr(3+len(addrs)), "upgrade pipe")
+	return u
+}
+
+// listen returns a listener on the TCP address addr, inherited from the old
+// process if there is one.
+func (u *upgrader) listen(addr string) (net.Listener, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var ln net.Listener
+	var err error
+	if f, ok := u.inherited[addr]; ok {
+		delete(u.inherited, addr)
+		ln, err = net.FileListener(f)
+		f.Close() // ln has its own descriptor
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	hl := &handoffListener{Listener: ln, addr: addr, handedOff: make(chan struct{}), closed: make(chan struct{})}
+	u.listeners = append(u.listeners, hl)
+	return hl, nil
+}
+
+// serving tells the old process, if any, that this process serves, and
+// writes pidFile. It is called once every frontend listens, so a new process
+// failing to start leaves the old one serving; connections wait in the
+// backlog of the sockets until the frontends accept them.
+func (u *upgrader) serving() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if pidFile != "" {
+		if err := ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+			log.Printf("PID file not written: %s\n", err)
+		}
+	}
+	if u.ready != nil {
+		u.ready.Write([]byte{1})
+		u.ready.Close()
+		u.ready = nil
+	}
+}
+
+// upgrade starts the binary again with the same arguments and the listening
+// sockets, and hands them off once it serves. Upgrading is safe to retry:
+// on failure this process goes on as before.
+func (u *upgrader) upgrade() error {
+	if _, ok := store.(*boltStore); ok {
+		return errUpgradeBolt
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	select {
+	case <-u.upgraded:
+		return errUpgraded
+	default:
+	}
+
+	addrs := make([]string, len(u.listeners))
+	files := make([]*os.File, len(u.listeners))
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	for i, l := range u.listeners {
+		fl, ok := l.Listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %s can't be handed off", l.addr)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		addrs[i], files[i] = l.addr, f
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	cmd := exec.Command(os.Args[0], os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), UPGRADE_ENV+"="+strings.Join(addrs, ","))
+	cmd.ExtraFiles = append(files, w)
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return err
+	}
+
+	// The pipe is closed without a byte if the new process exits.
+	result := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		if _, err := r.Read(b[:]); err != nil {
+			result <- errUpgradeFailed
+			return
+		}
+		result <- nil
+	}()
+	select {
+	case err = <-result:
+	case <-time.After(UPGRADE_TIMEOUT):
+		err = errUpgradeTimeout
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		go cmd.Wait()
+		return err
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+
+	close(u.upgraded)
+	for _, l := range u.listeners {
+		l.handOff()
+	}
+	log.Printf("Upgraded: process %d accepts the new connections\n", pid)
+	return nil
+}
+
+// isUpgraded reports whether the listeners were handed off to a new process.
+func (u *upgrader) isUpgraded() bool {
+	select {
+	case <-u.upgraded:
+		return true
+	default:
+		return false
+	}
+}
+
+// drain waits until the sessions of an upgraded process end, at most upgradeDrain.
+func (u *upgrader) drain() {
+	deadline := time.Now().Add(upgradeDrain)
+	for activeSessions.count() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+	}
+}
+
+// handoffListener is a listener that can stop accepting connections while
+// its frontend goes on, once another process accepts them.
+type handoffListener struct {
+	net.Listener
+	addr      string
+	handedOff chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Accept waits for a connection. Once the listener was handed off, it waits
+// until the listener is closed instead, as the frontend would stop.
+func (l *handoffListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		select {
+		case <-l.handedOff:
+			<-l.closed
+		default:
+		}
+	}
+	return conn, err
+}
+
+// handOff stops accepting connections, leaving them to the new process.
+func (l *handoffListener) handOff() {
+	close(l.handedOff)
+	l.Listener.Close() // the new process has its own descriptor
+}
+
+func (l *handoffListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MAX_UPLOAD_SIZE is the largest GIF file accepted by the upload route, in bytes.
+const MAX_UPLOAD_SIZE = 8 << 20
+
+// uploadQuota is the size, in bytes, of the GIFs every API key may upload.
+// Uploads with the admin token have no quota.
+var uploadQuota int64 = 256 << 20
+
+// PENDING_DIR holds uploads waiting for manual approval. Files in it are not streamable.
+const PENDING_DIR = "./gifs/pending"
+
+// PublishHook inspects an uploaded GIF before it becomes streamable.
+// Returning a non-nil error rejects the upload; returning ErrHeldForReview
+// parks the upload in PENDING_DIR until an operator moves it into GIF_DIR.
+type PublishHook func(ctx context.Context, gifBytes []byte) error
+
+// ErrHeldForReview is returned by a PublishHook to queue an upload for manual approval.
+var ErrHeldForReview = errors.New("upload held for review")
+
+// errUploadExists occurs when an upload is published under the name of an existing file.
+var errUploadExists = errors.New("file exists")
+
+// uploadsMu serializes the quota checks and records of uploads, so concurrent
+// uploads with the same API key can't go over its quota together.
+var uploadsMu sync.Mutex
+
+// publishHooks run in order on every upload. Wire NSFW/abuse scanners or
+// approval queues in here.
+var publishHooks = []PublishHook{
+	validGIFHook,
+}
+
+// validGIFHook rejects uploads that are not GIF files decoding as a whole
+// (see validateImage).
+func validGIFHook(ctx context.Context, gifBytes []byte) error {
+	if err := validateImage(gifBytes, true); err != nil {
+		return fmt.Errorf("not a GIF image: %s", err.Error())
+	}
+	return nil
+}
+
+// runPublishHooks runs every registered PublishHook, stopping at the first error.
+func runPublishHooks(ctx context.Context, gifBytes []byte) error {
+	for _, hook := range publishHooks {
+		if err := hook(ctx, gifBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadHandler stores the request body as a new GIF after it passes the publish hooks.
+func uploadHandler(c echo.Context) error {
+	uploader, err := uploadKeyName(c)
+	if err == errInvalidAPIKey {
+		return c.String(http.StatusUnauthorized, "Unauthorized.\n")
+	} else if err != nil {
+		return c.String(http.StatusInternalServerError,
+			fmt.Sprintf("Store error: %s.\n", err.Error()))
+	}
+
+	gifName := c.Param("GIFNAME")
+	if !gifNamePattern.MatchString(gifName) {
+		return c.String(http.StatusBadRequest,
+			fmt.Sprintf("Invalid GIF name %s.\n", ansimage.SanitizeText(gifName)))
+	}
+	if animationPath(gifName) != "" {
+		return c.String(http.StatusConflict,
+			fmt.Sprintf("GIF image %s already exists.\n", gifName))
+	}
+
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, MAX_UPLOAD_SIZE)
+	gifBytes, err := ioutil.ReadAll(body)
+	if err != nil {
+		return c.String(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("GIF image upload error: %s.\n", err.Error()))
+	}
+
+	uploadsMu.Lock()
+	defer uploadsMu.Unlock()
+	if uploader != "" {
+		used, err := uploadedBytes(uploader)
+		if err != nil {
+			return c.String(http.StatusInternalServerError,
+				fmt.Sprintf("Store error: %s.\n", err.Error()))
+		}
+		if used+int64(len(gifBytes)) > uploadQuota {
+			return c.String(http.StatusForbidden,
+				fmt.Sprintf("Upload quota of %d MB exceeded.\n", uploadQuota>>20))
+		}
+	}
+
+	dir := GIF_DIR
+	status := http.StatusCreated
+	if err := runPublishHooks(c.Request().Context(), gifBytes); err == ErrHeldForReview {
+		dir = PENDING_DIR
+		status = http.StatusAccepted
+	} else if err != nil {
+		return c.String(http.StatusUnprocessableEntity,
+			fmt.Sprintf("GIF image rejected: %s.\n", err.Error()))
+	}
+
+	if err := publishFile(dir, gifName+".gif", gifBytes); err == errUploadExists && dir == PENDING_DIR {
+		return c.String(http.StatusConflict,
+			fmt.Sprintf("GIF image %s is already waiting for approval.\n", gifName))
+	} else if err == errUploadExists {
+		return c.String(http.StatusConflict,
+			fmt.Sprintf("GIF image %s already exists.\n", gifName))
+	} else if err != nil {
+		return c.String(http.StatusInternalServerError,
+			fmt.Sprintf("GIF image save error: %s.\n", err.Error()))
+	}
+
+	err = recordUpload(gifName, uploadRecord{
+		Client:   c.RealIP(),
+		Uploader: uploader,
+		Time:     time.Now(),
+		Size:     len(gifBytes),
+		Pending:  status == http.StatusAccepted,
+	})
+	if err != nil {
+		log.Printf("Upload of %s not recorded: %s\n", gifName, err)
+	}
+
+	if status == http.StatusAccepted {
+		log.Printf("GIF image %s from %s held for review\n", gifName, c.RealIP())
+		return c.String(status, fmt.Sprintf("GIF image %s is waiting for approval.\n", gifName))
+	}
+	log.Printf("GIF image %s uploaded by %s\n", gifName, c.RealIP())
+	return c.String(status, fmt.Sprintf("GIF image %s uploaded.\n", gifName))
+}
+
+// publishFile writes data as the file name in dir, unless a file of that name
+// exists. The data is written to a temporary file first and linked into
+// place, so concurrent uploads of the same name can't replace each other and
+// viewers never see a partial file.
+func publishFile(dir, name string, data []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, ".upload-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Link(tmp.Name(), filepath.Join(dir, name)); os.IsExist(err) {
+		return errUploadExists
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
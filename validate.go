@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QUARANTINE_DIR holds the image files of GIF_DIR that failed validation.
+// Files in it are not streamable; fix or delete them, then move them back.
+const QUARANTINE_DIR = "./gifs/quarantine"
+
+// VALIDATE_TIMEOUT bounds the full decode of an image file during validation.
+const VALIDATE_TIMEOUT = 30 * time.Second
+
+// MAX_IMAGE_PIXELS is the largest canvas (width × height) of a valid image
+// file, so a small file can't claim a huge canvas.
+const MAX_IMAGE_PIXELS = 4096 * 4096
+
+var (
+	// errGIFBroken occurs when a requested GIF name maps to a quarantined file.
+	errGIFBroken = errors.New("GIF image broken")
+
+	// errImageTooLarge occurs when an image file claims a canvas above MAX_IMAGE_PIXELS.
+	errImageTooLarge = fmt.Errorf("canvas larger than %d pixels", MAX_IMAGE_PIXELS)
+
+	// errValidateTimeout occurs when an image file takes longer than VALIDATE_TIMEOUT to decode.
+	errValidateTimeout = errors.New("decoding took too long")
+)
+
+// validateImage checks that data is an image file that decodes as a whole:
+// every frame of a GIF, in a worker bounded by VALIDATE_TIMEOUT. Its header
+// is checked first, so the full decode isn't attempted for huge canvases.
+func validateImage(data []byte, isGIF bool) error {
+	var cfg image.Config
+	var err error
+	if isGIF {
+		cfg, err = gif.DecodeConfig(bytes.NewReader(data))
+	} else {
+		cfg, _, err = image.DecodeConfig(bytes.NewReader(data))
+	}
+	if err != nil {
+		return err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MAX_IMAGE_PIXELS {
+		return errImageTooLarge
+	}
+
+	// a timed-out decode runs on in the background, but its result is ignored
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		if isGIF {
+			_, err = gif.DecodeAll(bytes.NewReader(data))
+		} else {
+			_, _, err = image.Decode(bytes.NewReader(data))
+		}
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		return err
+	case <-time.After(VALIDATE_TIMEOUT):
+		return errValidateTimeout
+	}
+}
+
+// validateFile validates the image file filename, see validateImage.
+func validateFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return validateImage(data, strings.EqualFold(filepath.Ext(filename), ".gif"))
+}
+
+// quarantine moves the image file filename out of GIF_DIR into QUARANTINE_DIR
+// after it failed validation with err.
+func quarantine(filename string, err error) {
+	log.Printf("GIF image %s is broken (%s), moving it to %s\n", filename, err, QUARANTINE_DIR)
+	if err := os.MkdirAll(QUARANTINE_DIR, 0755); err != nil {
+		log.Printf("Quarantine error: %s\n", err)
+		return
+	}
+	if err := os.Rename(filename, filepath.Join(QUARANTINE_DIR, filepath.Base(filename))); err != nil {
+		log.Printf("Quarantine error: %s\n", err)
+	}
+}
+
+// refusal is a limit of validation (canvas size, decoding time) an image file
+// failed, as it was when validated.
+type refusal struct {
+	modTime int64 // in nanoseconds since the Unix epoch
+	size    int64
+	err     error
+}
+
+// refusedImages holds the image files of GIF_DIR that aren't broken but failed
+// a limit of validation, by file name. They stay in place for the operator,
+// but aren't served until they are replaced.
+var refusedImages = struct {
+	sync.Mutex
+	files map[string]refusal
+}{files: make(map[string]refusal)}
+
+// isBroken reports whether the validation error err means the image file
+// doesn't decode, rather than it failed a limit or couldn't be read.
+func isBroken(err error) bool {
+	if _, ok := err.(*os.PathError); ok || err == nil {
+		return false
+	}
+	return err != errImageTooLarge && err != errValidateTimeout
+}
+
+// handleInvalid quarantines the image file filename if it failed validation
+// with err because it's broken, or refuses to serve it if it failed a limit.
+// It returns errGIFBroken or the limit error, or nil if filename can be served.
+func handleInvalid(filename string, err error) error {
+	switch {
+	case isBroken(err):
+		quarantine(filename, err)
+		return errGIFBroken
+	case err == errImageTooLarge || err == errValidateTimeout:
+		info, statErr := os.Stat(filename)
+		if statErr != nil {
+			return nil
+		}
+		log.Printf("GIF image %s is not served: %s\n", filename, err)
+		refusedImages.Lock()
+		refusedImages.files[filename] = refusal{info.ModTime().UnixNano(), info.Size(), err}
+		refusedImages.Unlock()
+		return err
+	}
+	return nil
+}
+
+// revalidate validates the image file filename after it failed to load,
+// returning errGIFBroken if it was quarantined, the limit error if it is
+// refused, or nil if it looks valid (misconfigured, say).
+func revalidate(filename string) error {
+	return handleInvalid(filename, validateFile(filename))
+}
+
+// refused returns the limit error of the image file filename, with info, if
+// it is refused and wasn't replaced since.
+func refused(filename string, info os.FileInfo) error {
+	refusedImages.Lock()
+	defer refusedImages.Unlock()
+	r, ok := refusedImages.files[filename]
+	if !ok {
+		return nil
+	}
+	if r.modTime != info.ModTime().UnixNano() || r.size != info.Size() {
+		delete(refusedImages.files, filename)
+		return nil
+	}
+	return r.err
+}
+
+// isQuarantined reports whether the GIF (or static image) named name was quarantined.
+func isQuarantined(name string) bool {
+	if !gifNamePattern.MatchString(name) {
+		return false
+	}
+	for _, ext := range IMAGE_EXTENSIONS {
+		if _, err := os.Stat(filepath.Join(QUARANTINE_DIR, name+ext)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGIFDir validates every image file in GIF_DIR, one at a time,
+// quarantines the broken ones and refuses those over a limit, so viewers get
+// a clean error instead of a failed load. It runs in the background at startup; files added later are
+// validated on upload, or when they fail to load.
+func validateGIFDir() {
+	entries, err := ioutil.ReadDir(GIF_DIR)
+	if err != nil {
+		log.Printf("GIF directory not validated: %s\n", err)
+		return
+	}
+	checked, broken, skipped := 0, 0, 0
+	for _, entry := range entries {
+		if entry.IsDir() || !isImageFile(entry.Name()) {
+			continue
+		}
+		filename := filepath.Join(GIF_DIR, entry.Name())
+		checked++
+		switch err := handleInvalid(filename, validateFile(filename)); {
+		case err == errGIFBroken:
+			broken++
+		case err != nil:
+			skipped++
+		}
+	}
+	log.Printf("%d image files validated, %d quarantined, %d not served\n", checked, broken, skipped)
+}
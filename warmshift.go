@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"giflive/ansimage"
+	"math"
+	"strings"
+	"time"
+)
+
+// errInvalidSchedule occurs when a warm-shift schedule cannot be parsed.
+var errInvalidSchedule = errors.New("schedule must look like 22:00-06:00")
+
+// warmShiftSchedule warms colours (like redshift) during a daily time window,
+// for displays that keep playing overnight.
+type warmShiftSchedule struct {
+	start, end  time.Duration // offsets from midnight, local time
+	temperature float64       // colour temperature in Kelvin while active
+}
+
+// warmShift is the server-wide schedule used by streams requesting ?warmshift=1.
+var warmShift = warmShiftSchedule{
+	start:       22 * time.Hour,
+	end:         6 * time.Hour,
+	temperature: 3400,
+}
+
+// Set parses a window like "22:00-06:00". It implements flag.Value.
+func (s *warmShiftSchedule) Set(value string) error {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return errInvalidSchedule
+	}
+	start, err := time.Parse("15:04", parts[0])
+	if err != nil {
+		return errInvalidSchedule
+	}
+	end, err := time.Parse("15:04", parts[1])
+	if err != nil {
+		return errInvalidSchedule
+	}
+	s.start = time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+	s.end = time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute
+	return nil
+}
+
+func (s *warmShiftSchedule) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d",
+		int(s.start.Hours()), int(s.start.Minutes())%60,
+		int(s.end.Hours()), int(s.end.Minutes())%60)
+}
+
+// active reports whether now falls inside the schedule window, which may wrap past midnight.
+func (s *warmShiftSchedule) active(now time.Time) bool {
+	tod := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	if s.start <= s.end {
+		return tod >= s.start && tod < s.end
+	}
+	return tod >= s.start || tod < s.end
+}
+
+// ColorFunc returns the warming filter for now, or nil outside the window.
+func (s *warmShiftSchedule) ColorFunc(now time.Time) ansimage.ColorFunc {
+	if !s.active(now) {
+		return nil
+	}
+	mr, mg, mb := whitePoint(s.temperature)
+	return func(r, g, b uint8) (uint8, uint8, uint8) {
+		return uint8(float64(r) * mr), uint8(float64(g) * mg), uint8(float64(b) * mb)
+	}
+}
+
+// whitePoint returns the RGB multipliers of a black body at kelvin degrees
+// (Tanner Helland's approximation), 6600K being neutral.
+func whitePoint(kelvin float64) (r, g, b float64) {
+	t := kelvin / 100
+
+	r, g, b = 255, 255, 255
+	if t > 66 {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	} else {
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+		if t <= 19 {
+			b = 0
+		} else {
+			b = 138.5177312231*math.Log(t-10) - 305.0447927307
+		}
+	}
+
+	clamp := func(v float64) float64 {
+		return math.Max(0, math.Min(255, v)) / 255
+	}
+	return clamp(r), clamp(g), clamp(b)
+}
+
+// chainColorFuncs applies each non-nil ColorFunc in order, or returns nil if there are none.
+func chainColorFuncs(funcs ...ansimage.ColorFunc) ansimage.ColorFunc {
+	var chain []ansimage.ColorFunc
+	for _, f := range funcs {
+		if f != nil {
+			chain = append(chain, f)
+		}
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+	return func(r, g, b uint8) (uint8, uint8, uint8) {
+		for _, f := range chain {
+			r, g, b = f(r, g, b)
+		}
+		return r, g, b
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// memoryLimit is the heap size, in bytes, above which new streams are
+// degraded and the animation cache is shed (0 disables the watchdog).
+var memoryLimit uint64
+
+// MEMORY_CHECK_INTERVAL is how often the watchdog measures the heap.
+const MEMORY_CHECK_INTERVAL = 5 * time.Second
+
+// MEMORY_RECOVERY is the fraction of memoryLimit the heap must fall below
+// before new streams get their full quality back.
+const MEMORY_RECOVERY = 0.8
+
+// memoryPressure is 1 while the heap is above memoryLimit.
+var memoryPressure int32
+
+// underMemoryPressure reports whether new streams should be degraded.
+func underMemoryPressure() bool {
+	return atomic.LoadInt32(&memoryPressure) == 1
+}
+
+// watchMemory measures the heap every MEMORY_CHECK_INTERVAL until ctx is
+// cancelled, so the server sheds memory before being killed for it.
+func watchMemory(ctx context.Context) {
+	ticker := time.NewTicker(MEMORY_CHECK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkMemory()
+		}
+	}
+}
+
+// checkMemory enters or leaves the memory pressure state from the heap size.
+// While above the limit, the cache is shed at every check.
+func checkMemory() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if ms.HeapAlloc > memoryLimit {
+		if atomic.CompareAndSwapInt32(&memoryPressure, 0, 1) {
+			log.Printf("Heap at %d MB, over the memory limit: degrading new streams\n", ms.HeapAlloc>>20)
+		}
+		animations.shed()
+		debug.FreeOSMemory()
+	} else if float64(ms.HeapAlloc) < MEMORY_RECOVERY*float64(memoryLimit) {
+		if atomic.CompareAndSwapInt32(&memoryPressure, 1, 0) {
+			log.Printf("Heap at %d MB, back under the memory limit\n", ms.HeapAlloc>>20)
+		}
+	}
+}
+
+// degraded returns ro reduced to use less memory: no larger than the default
+// terminal size.
+func (ro renderOptions) degraded() renderOptions {
+	def := defaultRenderOptions()
+	if ro.rows > def.rows {
+		ro.rows = def.rows
+	}
+	if ro.cols > def.cols {
+		ro.cols = def.cols
+	}
+	return ro
+}
+
+// shed drops the cached animations other than the default variant of each
+// file. Streams keep playing the ones they hold.
+func (c *animationCache) shed() {
+	def := defaultRenderOptions()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.images {
+		if k.render != def && k.render != (renderOptions{}) {
+			c.drop(k)
+		}
+	}
+}
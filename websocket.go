@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// WEBSOCKET_GUID is appended to the key of the client to compute the accept
+// key of the handshake (RFC 6455, section 1.3).
+const WEBSOCKET_GUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes (RFC 6455, section 5.2).
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+)
+
+// WebSocket close status codes (RFC 6455, section 7.4.1).
+const (
+	wsNormalClosure = 1000
+	wsGoingAway     = 1001
+)
+
+// wsMaxCloseReason is the longest reason fitting in a close message.
+const wsMaxCloseReason = 123
+
+// errBadWebSocketHandshake occurs when an upgrade request lacks a key or asks
+// for another version of the protocol.
+var errBadWebSocketHandshake = errors.New("Bad WebSocket handshake")
+
+// isWebSocketUpgrade reports whether r asks to switch to the WebSocket protocol.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get(echo.HeaderUpgrade), "websocket")
+}
+
+// wsTransport sends frames as WebSocket text messages, for browser terminals
+// like xterm.js. The frames are sent as they would be to curl, one message
+// each, with "\r\n" line endings if crlf is set.
+type wsTransport struct {
+	conn net.Conn
+	r    *bufio.Reader // the client messages, after the handshake
+	w    io.Writer
+	crlf bool
+}
+
+// upgradeWebSocket answers the WebSocket handshake of c and takes over its
+// connection. The transport closes the connection when closed.
+func upgradeWebSocket(c echo.Context) (*wsTransport, error) {
+	key := c.Request().Header.Get("Sec-WebSocket-Key")
+	if key == "" || c.Request().Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, errBadWebSocketHandshake
+	}
+	accept := sha1.Sum([]byte(key + WEBSOCKET_GUID))
+
+	conn, rw, err := c.Response().Hijack()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(accept[:]))
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsTransport{conn: conn, r: rw.Reader, w: deadlineWriter{conn, conn, writeTimeout}}, nil
+}
+
+func (t *wsTransport) WriteFrame(frame []byte, delay time.Duration) error {
+	if t.crlf {
+		frame = bytes.Replace(frame, []byte("\n"), []byte("\r\n"), -1)
+	}
+	return t.writeMessage(wsText, frame)
+}
+
+// Close sends a close message with the reason, if any, then closes the
+// connection without waiting for the client to answer.
+func (t *wsTransport) Close(reason string) error {
+	code := wsNormalClosure
+	if reason != "" {
+		code = wsGoingAway
+	}
+	if len(reason) > wsMaxCloseReason {
+		reason = reason[:wsMaxCloseReason]
+	}
+	err := t.writeMessage(wsClose, append([]byte{byte(code >> 8), byte(code)}, reason...))
+	t.conn.Close()
+	return err
+}
+
+// writeMessage writes payload as a single unmasked message of opcode op.
+func (t *wsTransport) writeMessage(op byte, payload []byte) error {
+	header := []byte{0x80 | op} // FIN
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		for shift := 56; shift >= 0; shift -= 8 {
+			header = append(header, byte(uint64(n)>>uint(shift)))
+		}
+	}
+	_, err := t.w.Write(append(header, payload...))
+	return err
+}
+
+// readUntilClose discards the messages of the client until it sends a close
+// message or hangs up. Viewers have nothing to say but goodbye.
+func (t *wsTransport) readUntilClose() {
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(t.r, header[:]); err != nil {
+			return
+		}
+		if header[0]&0x0f == wsClose {
+			return
+		}
+
+		n := uint64(header[1] & 0x7f)
+		switch n {
+		case 126, 127:
+			ext := make([]byte, 2+6*(n-126))
+			if _, err := io.ReadFull(t.r, ext); err != nil {
+				return
+			}
+			n = 0
+			for _, b := range ext {
+				n = n<<8 | uint64(b)
+			}
+		}
+		if header[1]&0x80 != 0 {
+			n += 4 // masking key
+		}
+		if _, err := io.CopyN(ioutil.Discard, t.r, int64(n)); err != nil {
+			return
+		}
+	}
+}
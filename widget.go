@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// errUnknownWidget occurs when an overlay widget name is invalid.
+var errUnknownWidget = errors.New("unknown widget")
+
+// startTime is when the server started, for the uptime widget.
+var startTime = time.Now()
+
+// viewerCount is the number of streams currently playing, for the viewers widget.
+var viewerCount int64
+
+// widget renders an info field shown in the top row, refreshed on every frame.
+type widget func(now time.Time) string
+
+// widgets lists the built-in overlay widgets by name.
+var widgets = map[string]widget{
+	"clock": func(now time.Time) string {
+		return now.Format("15:04:05")
+	},
+	"uptime": func(now time.Time) string {
+		return "up " + now.Sub(startTime).Truncate(time.Second).String()
+	},
+	"viewers": func(now time.Time) string {
+		n := atomic.LoadInt64(&viewerCount)
+		if n == 1 {
+			return "1 viewer"
+		}
+		return fmt.Sprintf("%d viewers", n)
+	},
+}
+
+// parseWidgets looks up the widgets named in names.
+func parseWidgets(names []string) ([]widget, error) {
+	var list []widget
+	for _, name := range names {
+		w, ok := widgets[strings.TrimSpace(name)]
+		if !ok {
+			return nil, errUnknownWidget
+		}
+		list = append(list, w)
+	}
+	return list, nil
+}
+
+// widgetLine renders ws right-aligned in a line width cells wide.
+func widgetLine(ws []widget, now time.Time, width int) string {
+	fields := make([]string, len(ws))
+	for i, w := range ws {
+		fields[i] = w(now)
+	}
+	line := textCells(strings.Join(fields, " | "))
+	if len(line) > width {
+		line = line[len(line)-width:]
+	}
+	return strings.Repeat(" ", width-len(line)) + cellsString(line)
+}